@@ -0,0 +1,40 @@
+package sensorpb
+
+// JSONPayload bentuk JSON SensorData untuk payload MQTT: ditulis MicroA dan
+// dibaca subscriber MicroB. Nama field sama dengan di sensor.proto. File ini
+// ditulis manual, bukan hasil protoc.
+type JSONPayload struct {
+	SensorValue float64 `json:"sensor_value"`
+	SensorType  string  `json:"sensor_type"`
+	ID1         string  `json:"id1"`
+	ID2         int32   `json:"id2"`
+	Timestamp   string  `json:"timestamp"`
+	Seq         uint64  `json:"seq"`
+	ProducerID  string  `json:"producer_id"`
+}
+
+// NewJSONPayload payload JSON dari pesan proto
+func NewJSONPayload(data *SensorData) JSONPayload {
+	return JSONPayload{
+		SensorValue: data.GetSensorValue(),
+		SensorType:  data.GetSensorType(),
+		ID1:         data.GetId1(),
+		ID2:         data.GetId2(),
+		Timestamp:   data.GetTimestamp(),
+		Seq:         data.GetSeq(),
+		ProducerID:  data.GetProducerId(),
+	}
+}
+
+// Proto kebalikan NewJSONPayload
+func (p JSONPayload) Proto() *SensorData {
+	return &SensorData{
+		SensorValue: p.SensorValue,
+		SensorType:  p.SensorType,
+		Id1:         p.ID1,
+		Id2:         p.ID2,
+		Timestamp:   p.Timestamp,
+		Seq:         p.Seq,
+		ProducerId:  p.ProducerID,
+	}
+}
@@ -0,0 +1,37 @@
+package sensorpb
+
+import (
+	"encoding/json"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// format ini dipakai producer MQTT di luar MicroA, jadi jangan diubah
+func TestJSONPayload(t *testing.T) {
+	data := &SensorData{
+		SensorValue: 21.5,
+		SensorType:  "temperature",
+		Id1:         "A",
+		Id2:         1,
+		Timestamp:   "2026-01-02T03:04:05Z",
+		Seq:         7,
+		ProducerId:  "gw-1",
+	}
+	b, err := json.Marshal(NewJSONPayload(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"sensor_value":21.5,"sensor_type":"temperature","id1":"A","id2":1,"timestamp":"2026-01-02T03:04:05Z","seq":7,"producer_id":"gw-1"}`
+	if string(b) != want {
+		t.Fatalf("got  %s\nwant %s", b, want)
+	}
+
+	var p JSONPayload
+	if err := json.Unmarshal(b, &p); err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(p.Proto(), data) {
+		t.Fatalf("round trip: %v, want %v", p.Proto(), data)
+	}
+}
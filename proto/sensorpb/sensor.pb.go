@@ -29,6 +29,8 @@ type SensorData struct {
 	Id1           string                 `protobuf:"bytes,3,opt,name=id1,proto3" json:"id1,omitempty"`                                      // huruf kapital
 	Id2           int32                  `protobuf:"varint,4,opt,name=id2,proto3" json:"id2,omitempty"`                                     // angka integer
 	Timestamp     string                 `protobuf:"bytes,5,opt,name=timestamp,proto3" json:"timestamp,omitempty"`                          // format ISO8601 (atau RFC3339)
+	Seq           uint64                 `protobuf:"varint,6,opt,name=seq,proto3" json:"seq,omitempty"`                                     // nomor urut dari producer, dipakai untuk ack
+	ProducerId    string                 `protobuf:"bytes,7,opt,name=producer_id,json=producerId,proto3" json:"producer_id,omitempty"`      // opsional, id producer; (producer_id, seq) dipakai untuk dedup
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -98,6 +100,20 @@ func (x *SensorData) GetTimestamp() string {
 	return ""
 }
 
+func (x *SensorData) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *SensorData) GetProducerId() string {
+	if x != nil {
+		return x.ProducerId
+	}
+	return ""
+}
+
 // Request untuk stream data (dari MicroA ke MicroB)
 type StreamRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -196,11 +212,307 @@ func (x *StreamResponse) GetMessage() string {
 	return ""
 }
 
+// Ack per pesan dari MicroB untuk StreamDataWithAck
+type StreamAck struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Seq           uint64                 `protobuf:"varint,1,opt,name=seq,proto3" json:"seq,omitempty"`        // seq dari SensorData yang di-ack
+	Status        string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`   // "ok", "error" (kirim ulang) atau "rejected" (tidak lolos validasi, jangan kirim ulang)
+	Message       string                 `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"` // alasan kalau error atau rejected
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamAck) Reset() {
+	*x = StreamAck{}
+	mi := &file_proto_sensor_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamAck) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamAck) ProtoMessage() {}
+
+func (x *StreamAck) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_sensor_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamAck.ProtoReflect.Descriptor instead.
+func (*StreamAck) Descriptor() ([]byte, []int) {
+	return file_proto_sensor_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *StreamAck) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *StreamAck) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *StreamAck) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+// Request IngestBatch: sekumpulan data yang disimpan dalam satu transaksi
+type IngestBatchRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Data          []*SensorData          `protobuf:"bytes,1,rep,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IngestBatchRequest) Reset() {
+	*x = IngestBatchRequest{}
+	mi := &file_proto_sensor_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IngestBatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IngestBatchRequest) ProtoMessage() {}
+
+func (x *IngestBatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_sensor_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IngestBatchRequest.ProtoReflect.Descriptor instead.
+func (*IngestBatchRequest) Descriptor() ([]byte, []int) {
+	return file_proto_sensor_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *IngestBatchRequest) GetData() []*SensorData {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+// Status per item IngestBatch, urutannya sama dengan IngestBatchRequest.data
+type IngestItemStatus struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Seq           uint64                 `protobuf:"varint,1,opt,name=seq,proto3" json:"seq,omitempty"`        // seq dari SensorData
+	Status        string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`   // "ok" atau "rejected" (tidak lolos validasi, jangan kirim ulang)
+	Message       string                 `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"` // "stored", "duplicate" atau alasan rejected
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IngestItemStatus) Reset() {
+	*x = IngestItemStatus{}
+	mi := &file_proto_sensor_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IngestItemStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IngestItemStatus) ProtoMessage() {}
+
+func (x *IngestItemStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_sensor_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IngestItemStatus.ProtoReflect.Descriptor instead.
+func (*IngestItemStatus) Descriptor() ([]byte, []int) {
+	return file_proto_sensor_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *IngestItemStatus) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *IngestItemStatus) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *IngestItemStatus) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type IngestBatchResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Stored        uint32                 `protobuf:"varint,1,opt,name=stored,proto3" json:"stored,omitempty"`         // jumlah data yang baru disimpan
+	Duplicates    uint32                 `protobuf:"varint,2,opt,name=duplicates,proto3" json:"duplicates,omitempty"` // sudah pernah disimpan sebelumnya
+	Rejected      uint32                 `protobuf:"varint,3,opt,name=rejected,proto3" json:"rejected,omitempty"`
+	Items         []*IngestItemStatus    `protobuf:"bytes,4,rep,name=items,proto3" json:"items,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IngestBatchResponse) Reset() {
+	*x = IngestBatchResponse{}
+	mi := &file_proto_sensor_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IngestBatchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IngestBatchResponse) ProtoMessage() {}
+
+func (x *IngestBatchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_sensor_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IngestBatchResponse.ProtoReflect.Descriptor instead.
+func (*IngestBatchResponse) Descriptor() ([]byte, []int) {
+	return file_proto_sensor_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *IngestBatchResponse) GetStored() uint32 {
+	if x != nil {
+		return x.Stored
+	}
+	return 0
+}
+
+func (x *IngestBatchResponse) GetDuplicates() uint32 {
+	if x != nil {
+		return x.Duplicates
+	}
+	return 0
+}
+
+func (x *IngestBatchResponse) GetRejected() uint32 {
+	if x != nil {
+		return x.Rejected
+	}
+	return 0
+}
+
+func (x *IngestBatchResponse) GetItems() []*IngestItemStatus {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+// Filter Subscribe; field kosong (id2 0) artinya tidak difilter
+type SubscribeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id1           string                 `protobuf:"bytes,1,opt,name=id1,proto3" json:"id1,omitempty"`
+	Id2           int32                  `protobuf:"varint,2,opt,name=id2,proto3" json:"id2,omitempty"`
+	SensorTypes   []string               `protobuf:"bytes,3,rep,name=sensor_types,json=sensorTypes,proto3" json:"sensor_types,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubscribeRequest) Reset() {
+	*x = SubscribeRequest{}
+	mi := &file_proto_sensor_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubscribeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubscribeRequest) ProtoMessage() {}
+
+func (x *SubscribeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_sensor_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubscribeRequest.ProtoReflect.Descriptor instead.
+func (*SubscribeRequest) Descriptor() ([]byte, []int) {
+	return file_proto_sensor_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *SubscribeRequest) GetId1() string {
+	if x != nil {
+		return x.Id1
+	}
+	return ""
+}
+
+func (x *SubscribeRequest) GetId2() int32 {
+	if x != nil {
+		return x.Id2
+	}
+	return 0
+}
+
+func (x *SubscribeRequest) GetSensorTypes() []string {
+	if x != nil {
+		return x.SensorTypes
+	}
+	return nil
+}
+
 var File_proto_sensor_proto protoreflect.FileDescriptor
 
 const file_proto_sensor_proto_rawDesc = "" +
 	"\n" +
-	"\x12proto/sensor.proto\x12\x06sensor\"\x92\x01\n" +
+	"\x12proto/sensor.proto\x12\x06sensor\"\xc5\x01\n" +
 	"\n" +
 	"SensorData\x12!\n" +
 	"\fsensor_value\x18\x01 \x01(\x01R\vsensorValue\x12\x1f\n" +
@@ -208,15 +520,42 @@ const file_proto_sensor_proto_rawDesc = "" +
 	"sensorType\x12\x10\n" +
 	"\x03id1\x18\x03 \x01(\tR\x03id1\x12\x10\n" +
 	"\x03id2\x18\x04 \x01(\x05R\x03id2\x12\x1c\n" +
-	"\ttimestamp\x18\x05 \x01(\tR\ttimestamp\"7\n" +
+	"\ttimestamp\x18\x05 \x01(\tR\ttimestamp\x12\x10\n" +
+	"\x03seq\x18\x06 \x01(\x04R\x03seq\x12\x1f\n" +
+	"\vproducer_id\x18\a \x01(\tR\n" +
+	"producerId\"7\n" +
 	"\rStreamRequest\x12&\n" +
 	"\x04data\x18\x01 \x01(\v2\x12.sensor.SensorDataR\x04data\"B\n" +
 	"\x0eStreamResponse\x12\x16\n" +
 	"\x06status\x18\x01 \x01(\tR\x06status\x12\x18\n" +
-	"\amessage\x18\x02 \x01(\tR\amessage2N\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\"O\n" +
+	"\tStreamAck\x12\x10\n" +
+	"\x03seq\x18\x01 \x01(\x04R\x03seq\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\x12\x18\n" +
+	"\amessage\x18\x03 \x01(\tR\amessage\"<\n" +
+	"\x12IngestBatchRequest\x12&\n" +
+	"\x04data\x18\x01 \x03(\v2\x12.sensor.SensorDataR\x04data\"V\n" +
+	"\x10IngestItemStatus\x12\x10\n" +
+	"\x03seq\x18\x01 \x01(\x04R\x03seq\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\x12\x18\n" +
+	"\amessage\x18\x03 \x01(\tR\amessage\"\x99\x01\n" +
+	"\x13IngestBatchResponse\x12\x16\n" +
+	"\x06stored\x18\x01 \x01(\rR\x06stored\x12\x1e\n" +
+	"\n" +
+	"duplicates\x18\x02 \x01(\rR\n" +
+	"duplicates\x12\x1a\n" +
+	"\brejected\x18\x03 \x01(\rR\brejected\x12.\n" +
+	"\x05items\x18\x04 \x03(\v2\x18.sensor.IngestItemStatusR\x05items\"Y\n" +
+	"\x10SubscribeRequest\x12\x10\n" +
+	"\x03id1\x18\x01 \x01(\tR\x03id1\x12\x10\n" +
+	"\x03id2\x18\x02 \x01(\x05R\x03id2\x12!\n" +
+	"\fsensor_types\x18\x03 \x03(\tR\vsensorTypes2\x96\x02\n" +
 	"\rSensorService\x12=\n" +
 	"\n" +
-	"StreamData\x12\x15.sensor.StreamRequest\x1a\x16.sensor.StreamResponse(\x01B\x10Z\x0eproto/sensorpbb\x06proto3"
+	"StreamData\x12\x15.sensor.StreamRequest\x1a\x16.sensor.StreamResponse(\x01\x12A\n" +
+	"\x11StreamDataWithAck\x12\x15.sensor.StreamRequest\x1a\x11.sensor.StreamAck(\x010\x01\x12F\n" +
+	"\vIngestBatch\x12\x1a.sensor.IngestBatchRequest\x1a\x1b.sensor.IngestBatchResponse\x12;\n" +
+	"\tSubscribe\x12\x18.sensor.SubscribeRequest\x1a\x12.sensor.SensorData0\x01B\x10Z\x0eproto/sensorpbb\x06proto3"
 
 var (
 	file_proto_sensor_proto_rawDescOnce sync.Once
@@ -230,21 +569,34 @@ func file_proto_sensor_proto_rawDescGZIP() []byte {
 	return file_proto_sensor_proto_rawDescData
 }
 
-var file_proto_sensor_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_proto_sensor_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
 var file_proto_sensor_proto_goTypes = []any{
-	(*SensorData)(nil),     // 0: sensor.SensorData
-	(*StreamRequest)(nil),  // 1: sensor.StreamRequest
-	(*StreamResponse)(nil), // 2: sensor.StreamResponse
+	(*SensorData)(nil),          // 0: sensor.SensorData
+	(*StreamRequest)(nil),       // 1: sensor.StreamRequest
+	(*StreamResponse)(nil),      // 2: sensor.StreamResponse
+	(*StreamAck)(nil),           // 3: sensor.StreamAck
+	(*IngestBatchRequest)(nil),  // 4: sensor.IngestBatchRequest
+	(*IngestItemStatus)(nil),    // 5: sensor.IngestItemStatus
+	(*IngestBatchResponse)(nil), // 6: sensor.IngestBatchResponse
+	(*SubscribeRequest)(nil),    // 7: sensor.SubscribeRequest
 }
 var file_proto_sensor_proto_depIdxs = []int32{
 	0, // 0: sensor.StreamRequest.data:type_name -> sensor.SensorData
-	1, // 1: sensor.SensorService.StreamData:input_type -> sensor.StreamRequest
-	2, // 2: sensor.SensorService.StreamData:output_type -> sensor.StreamResponse
-	2, // [2:3] is the sub-list for method output_type
-	1, // [1:2] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+	0, // 1: sensor.IngestBatchRequest.data:type_name -> sensor.SensorData
+	5, // 2: sensor.IngestBatchResponse.items:type_name -> sensor.IngestItemStatus
+	1, // 3: sensor.SensorService.StreamData:input_type -> sensor.StreamRequest
+	1, // 4: sensor.SensorService.StreamDataWithAck:input_type -> sensor.StreamRequest
+	4, // 5: sensor.SensorService.IngestBatch:input_type -> sensor.IngestBatchRequest
+	7, // 6: sensor.SensorService.Subscribe:input_type -> sensor.SubscribeRequest
+	2, // 7: sensor.SensorService.StreamData:output_type -> sensor.StreamResponse
+	3, // 8: sensor.SensorService.StreamDataWithAck:output_type -> sensor.StreamAck
+	6, // 9: sensor.SensorService.IngestBatch:output_type -> sensor.IngestBatchResponse
+	0, // 10: sensor.SensorService.Subscribe:output_type -> sensor.SensorData
+	7, // [7:11] is the sub-list for method output_type
+	3, // [3:7] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
 }
 
 func init() { file_proto_sensor_proto_init() }
@@ -258,7 +610,7 @@ func file_proto_sensor_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_sensor_proto_rawDesc), len(file_proto_sensor_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   3,
+			NumMessages:   8,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
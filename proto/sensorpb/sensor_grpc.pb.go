@@ -19,7 +19,10 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	SensorService_StreamData_FullMethodName = "/sensor.SensorService/StreamData"
+	SensorService_StreamData_FullMethodName        = "/sensor.SensorService/StreamData"
+	SensorService_StreamDataWithAck_FullMethodName = "/sensor.SensorService/StreamDataWithAck"
+	SensorService_IngestBatch_FullMethodName       = "/sensor.SensorService/IngestBatch"
+	SensorService_Subscribe_FullMethodName         = "/sensor.SensorService/Subscribe"
 )
 
 // SensorServiceClient is the client API for SensorService service.
@@ -31,6 +34,17 @@ type SensorServiceClient interface {
 	// Stream satu arah (client → server)
 	// MicroA akan stream data ke MicroB secara terus-menerus
 	StreamData(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[StreamRequest, StreamResponse], error)
+	// Stream dua arah: MicroB mengirim ack (atau nack) per pesan
+	// setelah batch yang memuat pesan tersebut di-commit ke database
+	StreamDataWithAck(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[StreamRequest, StreamAck], error)
+	// Unary untuk producer yang mengirim data sesekali dalam jumlah banyak.
+	// Semua data yang lolos validasi disimpan dalam satu transaksi; kalau
+	// transaksi gagal seluruh request dibalas error dan boleh dikirim ulang
+	IngestBatch(ctx context.Context, in *IngestBatchRequest, opts ...grpc.CallOption) (*IngestBatchResponse, error)
+	// Stream dari server: data yang cocok dengan filter dikirim begitu
+	// ter-commit di MicroB. Subscriber yang terlalu lambat kehilangan data atau
+	// diputus (RESOURCE_EXHAUSTED), tergantung konfigurasi MicroB
+	Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[SensorData], error)
 }
 
 type sensorServiceClient struct {
@@ -54,6 +68,48 @@ func (c *sensorServiceClient) StreamData(ctx context.Context, opts ...grpc.CallO
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type SensorService_StreamDataClient = grpc.ClientStreamingClient[StreamRequest, StreamResponse]
 
+func (c *sensorServiceClient) StreamDataWithAck(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[StreamRequest, StreamAck], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &SensorService_ServiceDesc.Streams[1], SensorService_StreamDataWithAck_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamRequest, StreamAck]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SensorService_StreamDataWithAckClient = grpc.BidiStreamingClient[StreamRequest, StreamAck]
+
+func (c *sensorServiceClient) IngestBatch(ctx context.Context, in *IngestBatchRequest, opts ...grpc.CallOption) (*IngestBatchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(IngestBatchResponse)
+	err := c.cc.Invoke(ctx, SensorService_IngestBatch_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sensorServiceClient) Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[SensorData], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &SensorService_ServiceDesc.Streams[2], SensorService_Subscribe_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SubscribeRequest, SensorData]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SensorService_SubscribeClient = grpc.ServerStreamingClient[SensorData]
+
 // SensorServiceServer is the server API for SensorService service.
 // All implementations must embed UnimplementedSensorServiceServer
 // for forward compatibility.
@@ -63,6 +119,17 @@ type SensorServiceServer interface {
 	// Stream satu arah (client → server)
 	// MicroA akan stream data ke MicroB secara terus-menerus
 	StreamData(grpc.ClientStreamingServer[StreamRequest, StreamResponse]) error
+	// Stream dua arah: MicroB mengirim ack (atau nack) per pesan
+	// setelah batch yang memuat pesan tersebut di-commit ke database
+	StreamDataWithAck(grpc.BidiStreamingServer[StreamRequest, StreamAck]) error
+	// Unary untuk producer yang mengirim data sesekali dalam jumlah banyak.
+	// Semua data yang lolos validasi disimpan dalam satu transaksi; kalau
+	// transaksi gagal seluruh request dibalas error dan boleh dikirim ulang
+	IngestBatch(context.Context, *IngestBatchRequest) (*IngestBatchResponse, error)
+	// Stream dari server: data yang cocok dengan filter dikirim begitu
+	// ter-commit di MicroB. Subscriber yang terlalu lambat kehilangan data atau
+	// diputus (RESOURCE_EXHAUSTED), tergantung konfigurasi MicroB
+	Subscribe(*SubscribeRequest, grpc.ServerStreamingServer[SensorData]) error
 	mustEmbedUnimplementedSensorServiceServer()
 }
 
@@ -76,6 +143,15 @@ type UnimplementedSensorServiceServer struct{}
 func (UnimplementedSensorServiceServer) StreamData(grpc.ClientStreamingServer[StreamRequest, StreamResponse]) error {
 	return status.Errorf(codes.Unimplemented, "method StreamData not implemented")
 }
+func (UnimplementedSensorServiceServer) StreamDataWithAck(grpc.BidiStreamingServer[StreamRequest, StreamAck]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamDataWithAck not implemented")
+}
+func (UnimplementedSensorServiceServer) IngestBatch(context.Context, *IngestBatchRequest) (*IngestBatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method IngestBatch not implemented")
+}
+func (UnimplementedSensorServiceServer) Subscribe(*SubscribeRequest, grpc.ServerStreamingServer[SensorData]) error {
+	return status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
+}
 func (UnimplementedSensorServiceServer) mustEmbedUnimplementedSensorServiceServer() {}
 func (UnimplementedSensorServiceServer) testEmbeddedByValue()                       {}
 
@@ -104,19 +180,71 @@ func _SensorService_StreamData_Handler(srv interface{}, stream grpc.ServerStream
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type SensorService_StreamDataServer = grpc.ClientStreamingServer[StreamRequest, StreamResponse]
 
+func _SensorService_StreamDataWithAck_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(SensorServiceServer).StreamDataWithAck(&grpc.GenericServerStream[StreamRequest, StreamAck]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SensorService_StreamDataWithAckServer = grpc.BidiStreamingServer[StreamRequest, StreamAck]
+
+func _SensorService_IngestBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IngestBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SensorServiceServer).IngestBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SensorService_IngestBatch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SensorServiceServer).IngestBatch(ctx, req.(*IngestBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SensorService_Subscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SensorServiceServer).Subscribe(m, &grpc.GenericServerStream[SubscribeRequest, SensorData]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SensorService_SubscribeServer = grpc.ServerStreamingServer[SensorData]
+
 // SensorService_ServiceDesc is the grpc.ServiceDesc for SensorService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
 var SensorService_ServiceDesc = grpc.ServiceDesc{
 	ServiceName: "sensor.SensorService",
 	HandlerType: (*SensorServiceServer)(nil),
-	Methods:     []grpc.MethodDesc{},
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "IngestBatch",
+			Handler:    _SensorService_IngestBatch_Handler,
+		},
+	},
 	Streams: []grpc.StreamDesc{
 		{
 			StreamName:    "StreamData",
 			Handler:       _SensorService_StreamData_Handler,
 			ClientStreams: true,
 		},
+		{
+			StreamName:    "StreamDataWithAck",
+			Handler:       _SensorService_StreamDataWithAck_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Subscribe",
+			Handler:       _SensorService_Subscribe_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "proto/sensor.proto",
 }
@@ -0,0 +1,33 @@
+#!/usr/bin/env sh
+# Generate self-signed CA, MicroB server cert and MicroA client cert for
+# trying TLS / mTLS locally. Not for production. The Go TLS tests build the
+# same CA/server/client layout in memory (services/internal/testpki).
+#
+#   ./scripts/gen-dev-certs.sh [out_dir] [producer_cn]
+set -eu
+
+OUT=${1:-certs}
+PRODUCER_CN=${2:-microa-1}
+DAYS=365
+
+mkdir -p "$OUT"
+cd "$OUT"
+
+# CA
+openssl req -x509 -newkey rsa:2048 -nodes -days "$DAYS" \
+  -keyout ca.key -out ca.crt -subj "/CN=datastream-dev-ca"
+
+# MicroB server (SAN: microb untuk docker-compose, localhost untuk lokal)
+openssl req -newkey rsa:2048 -nodes -keyout server.key -out server.csr -subj "/CN=microb"
+printf "subjectAltName=DNS:microb,DNS:localhost,IP:127.0.0.1\nextendedKeyUsage=serverAuth\n" > server.ext
+openssl x509 -req -in server.csr -CA ca.crt -CAkey ca.key -CAcreateserial \
+  -days "$DAYS" -out server.crt -extfile server.ext
+
+# MicroA client, CN = identitas producer di MicroB
+openssl req -newkey rsa:2048 -nodes -keyout client.key -out client.csr -subj "/CN=$PRODUCER_CN"
+printf "extendedKeyUsage=clientAuth\n" > client.ext
+openssl x509 -req -in client.csr -CA ca.crt -CAkey ca.key -CAcreateserial \
+  -days "$DAYS" -out client.crt -extfile client.ext
+
+rm -f server.csr client.csr server.ext client.ext ca.srl
+echo "certificates written to $OUT/"
@@ -0,0 +1,115 @@
+// Package probe endpoint /healthz dan /readyz yang dipakai MicroA dan MicroB
+// untuk load balancer dan healthcheck docker-compose.
+//
+// Anotasi swag di sini ikut ke docs MicroB, jadi generate dengan
+// `swag init -d ./,../internal/probe -g cmd/microb/main.go -o docs` dari
+// services/microB.
+package probe
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Check mengecek satu dependency; nil artinya sehat. Error yang membungkus
+// ErrDegraded tidak membuat service unavailable.
+type Check func(ctx context.Context) error
+
+// ErrDegraded dependency bermasalah tapi service tetap bisa menerima data
+var ErrDegraded = errors.New("degraded")
+
+// DependencyStatus hasil cek satu dependency
+type DependencyStatus struct {
+	Status    string  `json:"status"` // "ok", "degraded" atau "fail"
+	Error     string  `json:"error,omitempty"`
+	LatencyMs float64 `json:"latency_ms"`
+}
+
+// Report respons /readyz
+type Report struct {
+	Status string                      `json:"status"` // "ok", "degraded" atau "unavailable"
+	Checks map[string]DependencyStatus `json:"checks"`
+}
+
+type Handler struct {
+	checks  map[string]Check
+	timeout time.Duration
+}
+
+// Register mendaftarkan /healthz dan /readyz tanpa autentikasi
+func Register(e *echo.Echo, checks map[string]Check) {
+	handler := &Handler{checks: checks, timeout: 2 * time.Second}
+
+	e.GET("/healthz", handler.Liveness)
+	e.GET("/readyz", handler.Readiness)
+}
+
+// Liveness godoc
+// @Summary Liveness probe
+// @Description Returns 200 while the process is running
+// @Tags health
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Router /healthz [get]
+func (h *Handler) Liveness(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Readiness godoc
+// @Summary Readiness probe
+// @Description Checks every dependency; 503 if any dependency fails, 200 with status degraded if a dependency is down but ingest still works
+// @Tags health
+// @Produce json
+// @Success 200 {object} probe.Report
+// @Failure 503 {object} probe.Report
+// @Router /readyz [get]
+func (h *Handler) Readiness(c echo.Context) error {
+	report := Run(c.Request().Context(), h.checks, h.timeout)
+	code := http.StatusOK
+	if report.Status == "unavailable" {
+		code = http.StatusServiceUnavailable
+	}
+	return c.JSON(code, report)
+}
+
+// Run menjalankan semua cek secara paralel dengan batas waktu
+func Run(ctx context.Context, checks map[string]Check, timeout time.Duration) Report {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	report := Report{Status: "ok", Checks: make(map[string]DependencyStatus, len(checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			err := check(ctx)
+			st := DependencyStatus{Status: "ok", LatencyMs: float64(time.Since(start)) / float64(time.Millisecond)}
+			switch {
+			case errors.Is(err, ErrDegraded):
+				st.Status, st.Error = "degraded", err.Error()
+			case err != nil:
+				st.Status, st.Error = "fail", err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = st
+			switch {
+			case st.Status == "fail":
+				report.Status = "unavailable"
+			case st.Status == "degraded" && report.Status == "ok":
+				report.Status = "degraded"
+			}
+		}()
+	}
+	wg.Wait()
+	return report
+}
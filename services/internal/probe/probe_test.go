@@ -0,0 +1,39 @@
+package probe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	ok := func(context.Context) error { return nil }
+	fail := func(context.Context) error { return errors.New("connection refused") }
+	degraded := func(context.Context) error { return fmt.Errorf("%w: buffering in WAL", ErrDegraded) }
+	slow := func(ctx context.Context) error { <-ctx.Done(); return ctx.Err() }
+
+	tests := []struct {
+		name   string
+		checks map[string]Check
+		want   string
+		wantDB string
+	}{
+		{"all ok", map[string]Check{"database": ok, "grpc": ok}, "ok", "ok"},
+		{"degraded", map[string]Check{"database": degraded, "grpc": ok}, "degraded", "degraded"},
+		{"fail wins over degraded", map[string]Check{"database": degraded, "grpc": fail}, "unavailable", "degraded"},
+		{"timeout", map[string]Check{"database": slow, "grpc": ok}, "unavailable", "fail"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Run(context.Background(), tt.checks, 50*time.Millisecond)
+			if report.Status != tt.want {
+				t.Fatalf("status %q, want %q", report.Status, tt.want)
+			}
+			if got := report.Checks["database"].Status; got != tt.wantDB {
+				t.Fatalf("database %q, want %q", got, tt.wantDB)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+// Package testpki membuat CA dan sertifikat di memori untuk test TLS/mTLS,
+// setara dengan scripts/gen-dev-certs.sh tapi tanpa openssl. Hanya untuk test.
+package testpki
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// CA certificate authority yang menandatangani sertifikat server dan client
+type CA struct {
+	Name string
+	// File path PEM sertifikat CA
+	File string
+
+	dir  string
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// Pair path PEM sertifikat dan private key
+type Pair struct {
+	CertFile string
+	KeyFile  string
+}
+
+// NewCA membuat CA baru; semua file ditulis ke dir
+func NewCA(t testing.TB, dir, name string) *CA {
+	t.Helper()
+	key := newKey(t)
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial(t),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create CA: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse CA: %v", err)
+	}
+	ca := &CA{Name: name, dir: dir, cert: cert, key: key}
+	ca.File = writePEM(t, filepath.Join(dir, name+".crt"), "CERTIFICATE", der)
+	return ca
+}
+
+// Server sertifikat server untuk localhost dan 127.0.0.1
+func (ca *CA) Server(t testing.TB, cn string) Pair {
+	t.Helper()
+	return ca.issue(t, cn, x509.ExtKeyUsageServerAuth)
+}
+
+// Client sertifikat client; cn jadi identitas producer di MicroB
+func (ca *CA) Client(t testing.TB, cn string) Pair {
+	t.Helper()
+	return ca.issue(t, cn, x509.ExtKeyUsageClientAuth)
+}
+
+func (ca *CA) issue(t testing.TB, cn string, usage x509.ExtKeyUsage) Pair {
+	key := newKey(t)
+	tmpl := &x509.Certificate{
+		SerialNumber: serial(t),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	if usage == x509.ExtKeyUsageServerAuth {
+		tmpl.DNSNames = []string{"localhost"}
+		tmpl.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("issue %s: %v", cn, err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key %s: %v", cn, err)
+	}
+	base := filepath.Join(ca.dir, ca.Name+"-"+cn)
+	return Pair{
+		CertFile: writePEM(t, base+".crt", "CERTIFICATE", der),
+		KeyFile:  writePEM(t, base+".key", "EC PRIVATE KEY", keyDER),
+	}
+}
+
+func newKey(t testing.TB) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return key
+}
+
+func serial(t testing.TB) *big.Int {
+	n, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 62))
+	if err != nil {
+		t.Fatalf("serial: %v", err)
+	}
+	return n
+}
+
+func writePEM(t testing.TB, path, typ string, der []byte) string {
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+	return path
+}
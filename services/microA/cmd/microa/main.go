@@ -2,17 +2,45 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log"
+	nethttp "net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
 
 	sensorpb "github.com/thomasdarmawan9/datastream-backend/proto/sensorpb"
+	"github.com/thomasdarmawan9/datastream-backend/services/internal/probe"
+	"github.com/thomasdarmawan9/datastream-backend/services/microA/internal/domain"
+	"github.com/thomasdarmawan9/datastream-backend/services/microA/internal/infrastructure/queue"
+	grpcClient "github.com/thomasdarmawan9/datastream-backend/services/microA/internal/interfaces/grpc"
+	mqttClient "github.com/thomasdarmawan9/datastream-backend/services/microA/internal/interfaces/mqtt"
 	"github.com/thomasdarmawan9/datastream-backend/services/microA/internal/usecase"
 
+	"github.com/labstack/echo/v4"
+	echomw "github.com/labstack/echo/v4/middleware"
 	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 func main() {
+	healthcheck := flag.Bool("healthcheck", false, "query /readyz of the running service and exit 0 if ready (for docker healthcheck)")
+	flag.Parse()
+
+	// port HTTP untuk health probe
+	httpPort := os.Getenv("HTTP_PORT")
+	if httpPort == "" {
+		httpPort = "8081"
+	}
+	if *healthcheck {
+		os.Exit(runHealthcheck(httpPort))
+	}
+
 	// --- Load ENV ---
 	microBAddr := os.Getenv("MICROB_GRPC_ADDR") // contoh: "localhost:50051"
 	if microBAddr == "" {
@@ -27,41 +55,275 @@ func main() {
 		}
 	}
 
-	// --- gRPC Dial ke MicroB ---
-	conn, err := grpc.Dial(microBAddr, grpc.WithInsecure())
-	if err != nil {
-		log.Fatalf("failed to connect MicroB: %v", err)
+	// id producer, dipakai MicroB untuk dedup (producer_id, seq)
+	producerID := os.Getenv("PRODUCER_ID")
+	if producerID == "" {
+		producerID, _ = os.Hostname()
 	}
-	defer conn.Close()
 
-	client := sensorpb.NewSensorServiceClient(conn)
+	// backlog data yang belum di-ack MicroB
+	queueDir := os.Getenv("QUEUE_DIR")
+	if queueDir == "" {
+		queueDir = "data/queue"
+	}
+	maxBacklog := envInt("MAX_BACKLOG", 100000)
+	dropPolicy := queue.DropPolicy(os.Getenv("BACKLOG_DROP_POLICY"))
+	if dropPolicy == "" {
+		dropPolicy = queue.DropOldest
+	}
+	// SIGINT/SIGTERM: berhenti generate, tutup stream setelah ack terakhir diterima
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// Open stream
-	stream, err := client.StreamData(context.Background())
+	q, err := queue.Open(queueDir, maxBacklog, dropPolicy, 16<<20)
 	if err != nil {
-		log.Fatalf("failed to open stream: %v", err)
+		log.Fatalf("failed to open backlog queue: %v", err)
+	}
+	defer q.Close()
+
+	// --- Transport ke MicroB: gRPC (default) atau MQTT ---
+	transportMode := os.Getenv("TRANSPORT")
+	if transportMode == "" {
+		transportMode = "grpc"
+	}
+	var (
+		sender      interface{ Run(ctx context.Context) }
+		senderCheck probe.Check
+		checkName   string
+		target      string
+	)
+	switch transportMode {
+	case "grpc":
+		forwarder, conn := newGRPCForwarder(microBAddr, q, grpcClient.ForwarderConfig{
+			MinBackoff:   envDuration("RECONNECT_MIN_BACKOFF", 500*time.Millisecond),
+			MaxBackoff:   envDuration("RECONNECT_MAX_BACKOFF", 30*time.Second),
+			DrainTimeout: envDuration("SHUTDOWN_TIMEOUT", 10*time.Second),
+		})
+		defer conn.Close()
+		sender, target, checkName = forwarder, microBAddr, "microb"
+		senderCheck = func(ctx context.Context) error {
+			if !forwarder.Connected() {
+				return fmt.Errorf("stream to MicroB not open (connection %s)", conn.GetState())
+			}
+			return nil
+		}
+	case "mqtt":
+		publisher, broker := newMQTTPublisher(producerID, q)
+		sender, target, checkName = publisher, broker, "mqtt"
+		senderCheck = func(ctx context.Context) error {
+			if !publisher.Connected() {
+				return errors.New("not connected to MQTT broker")
+			}
+			return nil
+		}
+	default:
+		log.Fatalf("unknown TRANSPORT %q (grpc or mqtt)", transportMode)
 	}
+	senderDone := make(chan struct{})
+	go func() {
+		defer close(senderDone)
+		sender.Run(ctx)
+	}()
 
-	log.Printf("MicroA started. Sending smart-building sensor data every %v → %s", freq, microBAddr)
+	// --- HTTP Server (health probe) ---
+	e := echo.New()
+	e.HideBanner = true
+	e.Use(echomw.Recover())
+	probe.Register(e, map[string]probe.Check{
+		// "microb" (stream gRPC terbuka) atau "mqtt" (terhubung ke broker)
+		checkName: senderCheck,
+		"backlog": func(ctx context.Context) error {
+			if n := q.Len(); n >= maxBacklog {
+				return fmt.Errorf("backlog full (%d readings), new readings are dropped", n)
+			}
+			return nil
+		},
+	})
+	go func() {
+		log.Println("MicroA HTTP server running at :" + httpPort)
+		if err := e.Start(":" + httpPort); err != nil && !errors.Is(err, nethttp.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	log.Printf("MicroA started. Sending smart-building sensor data every %v → %s (%s)", freq, target, transportMode)
 
 	// Usecase generator (multi-sensor)
 	gen := usecase.NewSensorGenerator(freq)
 
-	// Loop generate & kirim
-	for data := range gen.Generate() {
-		req := &sensorpb.StreamRequest{
-			Data: &sensorpb.SensorData{
-				SensorValue: data.SensorValue,
-				SensorType:  data.SensorType,
-				Id1:         data.ID1,
-				Id2:         int32(data.ID2),
-				Timestamp:   data.TS.Format(time.RFC3339Nano),
-			},
+	// Loop generate & simpan ke backlog
+	// seq mulai dari waktu start supaya tetap naik walaupun MicroA restart,
+	// jadi (producer_id, seq) tidak bentrok dengan data sebelumnya
+	seq := uint64(time.Now().UnixNano())
+	readings := gen.Generate()
+	for ctx.Err() == nil {
+		var data *domain.SensorData
+		select {
+		case data = <-readings:
+		case <-ctx.Done():
+			continue
+		}
+		seq++
+		err := q.Push(&sensorpb.SensorData{
+			SensorValue: data.SensorValue,
+			SensorType:  data.SensorType,
+			Id1:         data.ID1,
+			Id2:         int32(data.ID2),
+			Timestamp:   data.TS.Format(time.RFC3339Nano),
+			Seq:         seq,
+			ProducerId:  producerID,
+		})
+		if errors.Is(err, queue.ErrBacklogFull) {
+			log.Printf("Backlog full (%d readings), dropped: %+v", maxBacklog, data)
+			continue
+		}
+		if err != nil {
+			log.Fatalf("failed to queue reading: %v", err)
 		}
+		log.Printf("Queued: %+v", data)
+	}
+
+	stop()
+	log.Println("Shutting down, waiting for in-flight readings to be acked...")
+	<-senderDone
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := e.Shutdown(shutdownCtx); err != nil {
+		log.Printf("HTTP shutdown: %v", err)
+	}
+	log.Printf("Shutdown complete, %d readings left in backlog for next start", q.Len())
+}
 
-		if err := stream.Send(req); err != nil {
-			log.Fatalf("failed to send: %v", err)
+// newGRPCForwarder menyiapkan koneksi gRPC ke MicroB (TLS dan kredensial
+// producer dari env) dan Forwarder yang mengirim isi backlog
+func newGRPCForwarder(microBAddr string, q *queue.DiskQueue, cfg grpcClient.ForwarderConfig) (*grpcClient.Forwarder, *grpc.ClientConn) {
+	// TLS ke MicroB; dengan sertifikat client (mTLS) CN sertifikat jadi identitas producer
+	tlsCfg := grpcClient.TLSConfig{
+		Enabled:    envBool("MICROB_TLS", false),
+		CAFile:     os.Getenv("MICROB_TLS_CA"),
+		CertFile:   os.Getenv("MICROB_TLS_CERT"),
+		KeyFile:    os.Getenv("MICROB_TLS_KEY"),
+		ServerName: os.Getenv("MICROB_TLS_SERVER_NAME"),
+	}
+	transport, err := grpcClient.TransportOption(tlsCfg)
+	if err != nil {
+		log.Fatalf("invalid TLS config: %v", err)
+	}
+
+	// kredensial producer untuk MicroB (API key atau JWT role producer)
+	creds := grpcClient.ProducerCredentials{
+		APIKey:        os.Getenv("PRODUCER_API_KEY"),
+		Token:         os.Getenv("PRODUCER_TOKEN"),
+		AllowInsecure: envBool("MICROB_ALLOW_INSECURE_CREDENTIALS", false),
+	}
+	dialOpts := []grpc.DialOption{transport}
+	if !creds.Empty() {
+		if !tlsCfg.Enabled {
+			if !creds.AllowInsecure {
+				log.Fatal("PRODUCER_API_KEY/PRODUCER_TOKEN requires MICROB_TLS=true; set MICROB_ALLOW_INSECURE_CREDENTIALS=true to send them over plaintext")
+			}
+			log.Println("WARNING: producer credentials sent to MicroB over plaintext")
 		}
-		log.Printf("Sent: %+v", data)
+		dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(creds))
+	} else if tlsCfg.CertFile == "" {
+		log.Println("No PRODUCER_API_KEY/PRODUCER_TOKEN or client certificate set, MicroB may reject the stream")
+	}
+
+	// --- gRPC Dial ke MicroB ---
+	// Dial tidak menunggu koneksi, jadi MicroA tetap jalan walau MicroB belum up
+	conn, err := grpc.Dial(microBAddr, dialOpts...)
+	if err != nil {
+		log.Fatalf("failed to connect MicroB: %v", err)
+	}
+
+	client := sensorpb.NewSensorServiceClient(conn)
+
+	// Forwarder kirim isi backlog lewat stream dua arah (ack per pesan),
+	// cek health MicroB dulu dan reconnect sendiri kalau MicroB tidak bisa dihubungi
+	return grpcClient.NewForwarder(client, healthpb.NewHealthClient(conn), q, cfg), conn
+}
+
+// newMQTTPublisher menyiapkan Publisher untuk mode MQTT dari env; env backoff
+// dan SHUTDOWN_TIMEOUT sama dengan mode gRPC
+func newMQTTPublisher(producerID string, q *queue.DiskQueue) (*mqttClient.Publisher, string) {
+	pubCfg := mqttClient.PublisherConfig{
+		BrokerURL:    os.Getenv("MQTT_BROKER"),
+		ClientID:     os.Getenv("MQTT_CLIENT_ID"),
+		Username:     os.Getenv("MQTT_USERNAME"),
+		Password:     os.Getenv("MQTT_PASSWORD"),
+		Topic:        os.Getenv("MQTT_TOPIC"),
+		QoS:          byte(envInt("MQTT_QOS", 1)),
+		Retain:       envBool("MQTT_RETAIN", false),
+		Payload:      os.Getenv("MQTT_PAYLOAD"),
+		StatusTopic:  os.Getenv("MQTT_STATUS_TOPIC"),
+		ProducerID:   producerID,
+		MinBackoff:   envDuration("RECONNECT_MIN_BACKOFF", 500*time.Millisecond),
+		MaxBackoff:   envDuration("RECONNECT_MAX_BACKOFF", 30*time.Second),
+		AckTimeout:   envDuration("MQTT_ACK_TIMEOUT", 10*time.Second),
+		MaxInflight:  envInt("MQTT_MAX_INFLIGHT", 100),
+		DrainTimeout: envDuration("SHUTDOWN_TIMEOUT", 10*time.Second),
+	}
+	if pubCfg.BrokerURL == "" {
+		pubCfg.BrokerURL = "tcp://localhost:1883"
+	}
+	if pubCfg.ClientID == "" {
+		pubCfg.ClientID = "microa-" + producerID
+	}
+	if pubCfg.Topic == "" {
+		pubCfg.Topic = "sensors/{id1}/{id2}/{type}"
+	}
+	if pubCfg.Payload == "" {
+		pubCfg.Payload = mqttClient.PayloadJSON
+	}
+	// MQTT_STATUS_TOPIC= (kosong) mematikan status online/offline dan last will
+	if _, ok := os.LookupEnv("MQTT_STATUS_TOPIC"); !ok {
+		pubCfg.StatusTopic = "producers/{producer}/status"
+	}
+	publisher, err := mqttClient.NewPublisher(pubCfg, q)
+	if err != nil {
+		log.Fatalf("invalid MQTT config: %v", err)
+	}
+	return publisher, pubCfg.BrokerURL
+}
+
+// runHealthcheck dipakai healthcheck docker: cek /readyz proses yang sedang
+// jalan di container yang sama
+func runHealthcheck(port string) int {
+	client := nethttp.Client{Timeout: 3 * time.Second}
+	resp, err := client.Get("http://127.0.0.1:" + port + "/readyz")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "healthcheck:", err)
+		return 1
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != nethttp.StatusOK {
+		fmt.Fprintf(os.Stderr, "healthcheck: %s %s\n", resp.Status, body)
+		return 1
+	}
+	return 0
+}
+
+// envInt membaca env integer, pakai def kalau kosong atau tidak valid
+func envInt(key string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return v
+	}
+	return def
+}
+
+// envBool membaca env boolean ("true", "1", ...), pakai def kalau kosong atau tidak valid
+func envBool(key string, def bool) bool {
+	if v, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return v
+	}
+	return def
+}
+
+// envDuration membaca env durasi (contoh "5s", "500ms"), pakai def kalau kosong atau tidak valid
+func envDuration(key string, def time.Duration) time.Duration {
+	if v, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return v
 	}
+	return def
 }
@@ -0,0 +1,478 @@
+package queue
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/thomasdarmawan9/datastream-backend/proto/sensorpb"
+	"google.golang.org/protobuf/proto"
+)
+
+// DropPolicy apa yang dibuang kalau backlog sudah penuh
+type DropPolicy string
+
+const (
+	DropOldest DropPolicy = "drop_oldest" // buang data paling lama, data baru tetap masuk
+	DropNewest DropPolicy = "drop_newest" // tolak data baru
+)
+
+var ErrBacklogFull = errors.New("backlog full, reading dropped")
+
+const (
+	segmentSuffix = ".q"
+	ackSuffix     = ".ack"
+	headerSize    = 8 // panjang payload (4 byte) + crc32 payload (4 byte)
+)
+
+type entry struct {
+	data    *sensorpb.SensorData
+	segment uint64
+	index   uint32 // urutan record di segment
+	acked   bool
+}
+
+// DiskQueue antrian SensorData yang belum di-ack MicroB. Setiap Push ditulis
+// ke segment file (dan di-fsync) supaya backlog tetap ada walau MicroA
+// restart; segment dihapus setelah semua isinya di-ack. Isi yang belum di-ack
+// juga disimpan di memori, jadi MaxBacklog sekaligus membatasi memori.
+//
+// Record yang sudah keluar dari antrian (di-ack atau dibuang karena backlog
+// penuh) tapi segment-nya belum bisa dihapus dicatat di file <segment>.ack,
+// jadi tidak dimuat lagi setelah restart. File .ack tidak di-fsync: kalau
+// mesin mati mendadak, record yang ack-nya belum sampai disk dikirim ulang
+// dan MicroB membuangnya lewat dedup (producer_id, seq).
+type DiskQueue struct {
+	dir          string
+	maxBacklog   int
+	policy       DropPolicy
+	segmentBytes int64
+
+	mu          sync.Mutex
+	entries     []*entry // urut sesuai Push; entries[0] data tertua yang belum di-ack
+	bySeq       map[uint64]*entry
+	cursor      int // index entry berikutnya yang akan dikirim
+	outstanding map[uint64]int
+	active      *os.File
+	activeID    uint64
+	activeSize  int64
+	activeCount uint32
+	ackFiles    map[uint64]*os.File
+	dropped     uint64
+
+	signal chan struct{}
+}
+
+func Open(dir string, maxBacklog int, policy DropPolicy, segmentBytes int64) (*DiskQueue, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if policy != DropOldest && policy != DropNewest {
+		return nil, fmt.Errorf("unknown drop policy %q", policy)
+	}
+
+	q := &DiskQueue{
+		dir:          dir,
+		maxBacklog:   maxBacklog,
+		policy:       policy,
+		segmentBytes: segmentBytes,
+		bySeq:        map[uint64]*entry{},
+		outstanding:  map[uint64]int{},
+		ackFiles:     map[uint64]*os.File{},
+		signal:       make(chan struct{}, 1),
+	}
+
+	// muat backlog dari run sebelumnya
+	ids, err := listSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := q.removeOrphanAcks(ids); err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if err := q.load(id); err != nil {
+			return nil, err
+		}
+	}
+	// backlog lama lebih besar dari batas sekarang
+	if trimmed := q.trim(); trimmed > 0 {
+		log.Printf("Queue backlog over limit, dropped %d readings (%s)", trimmed, q.policy)
+	}
+	next := uint64(1)
+	if len(ids) > 0 {
+		next = ids[len(ids)-1] + 1
+	}
+	if err := q.openSegment(next); err != nil {
+		return nil, err
+	}
+	if len(q.entries) > 0 {
+		log.Printf("Loaded %d unsent readings from %s", len(q.entries), dir)
+	}
+	return q, nil
+}
+
+func listSegments(dir string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var ids []uint64
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), segmentSuffix)
+		if !ok {
+			continue
+		}
+		if id, err := strconv.ParseUint(name, 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+func (q *DiskQueue) segmentPath(id uint64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%020d%s", id, segmentSuffix))
+}
+
+func (q *DiskQueue) ackPath(id uint64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%020d%s", id, ackSuffix))
+}
+
+// removeOrphanAcks menghapus file .ack yang segment-nya sudah tidak ada
+// (proses mati di antara hapus segment dan hapus .ack)
+func (q *DiskQueue) removeOrphanAcks(ids []uint64) error {
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ackSuffix)
+		if !ok {
+			continue
+		}
+		if id, err := strconv.ParseUint(name, 10, 64); err == nil && !slices.Contains(ids, id) {
+			if err := os.Remove(filepath.Join(q.dir, e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// loadAcks index record di segment id yang sudah di-ack atau dibuang
+func (q *DiskQueue) loadAcks(id uint64) (map[uint32]bool, error) {
+	b, err := os.ReadFile(q.ackPath(id))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	done := map[uint32]bool{}
+	// sisa byte di akhir berarti tulisan terakhir terpotong, diabaikan
+	for i := 0; i+4 <= len(b); i += 4 {
+		done[binary.LittleEndian.Uint32(b[i:])] = true
+	}
+	return done, nil
+}
+
+func (q *DiskQueue) load(id uint64) error {
+	done, err := q.loadAcks(id)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(q.segmentPath(id))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	header := make([]byte, headerSize)
+	for index := uint32(0); ; index++ {
+		if _, err := io.ReadFull(r, header); err != nil {
+			break // EOF atau record terakhir terpotong
+		}
+		payload := make([]byte, binary.LittleEndian.Uint32(header[0:4]))
+		if _, err := io.ReadFull(r, payload); err != nil || crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(header[4:8]) {
+			log.Printf("Queue segment %d has a torn record, skipping rest", id)
+			break
+		}
+		if done[index] {
+			continue
+		}
+		var d sensorpb.SensorData
+		if err := proto.Unmarshal(payload, &d); err != nil {
+			continue
+		}
+		q.appendEntry(&entry{data: &d, segment: id, index: index})
+	}
+
+	if q.outstanding[id] == 0 {
+		return q.removeSegment(id)
+	}
+	return nil
+}
+
+// trim membuang data sampai backlog tidak melebihi maxBacklog sesuai policy:
+// DropOldest dari depan, DropNewest dari belakang. mu harus terkunci (atau
+// dipanggil dari Open).
+func (q *DiskQueue) trim() int {
+	n := 0
+	for len(q.entries) > q.maxBacklog {
+		if q.policy == DropNewest {
+			q.popTail()
+		} else {
+			q.popHead(true)
+		}
+		q.dropped++
+		n++
+	}
+	return n
+}
+
+func (q *DiskQueue) openSegment(id uint64) error {
+	f, err := os.OpenFile(q.segmentPath(id), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	// .ack dari segment lama dengan id yang sama tidak berlaku lagi
+	if err := os.Remove(q.ackPath(id)); err != nil && !os.IsNotExist(err) {
+		f.Close()
+		return err
+	}
+	q.active, q.activeID, q.activeSize, q.activeCount = f, id, 0, 0
+	return nil
+}
+
+func (q *DiskQueue) appendEntry(e *entry) {
+	q.entries = append(q.entries, e)
+	q.bySeq[e.data.Seq] = e
+	q.outstanding[e.segment]++
+}
+
+// Push menyimpan data ke disk lalu ke antrian kirim
+func (q *DiskQueue) Push(d *sensorpb.SensorData) error {
+	payload, err := proto.Marshal(d)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, headerSize+len(payload))
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(payload))
+	copy(buf[headerSize:], payload)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.entries) >= q.maxBacklog {
+		q.dropped++
+		if q.policy == DropNewest {
+			return ErrBacklogFull
+		}
+		q.popHead(true)
+	}
+
+	if q.activeSize > 0 && q.activeSize+int64(len(buf)) > q.segmentBytes {
+		if err := q.rotate(); err != nil {
+			return err
+		}
+	}
+	if _, err := q.active.Write(buf); err != nil {
+		return err
+	}
+	if err := q.active.Sync(); err != nil {
+		return err
+	}
+	q.activeSize += int64(len(buf))
+	q.appendEntry(&entry{data: d, segment: q.activeID, index: q.activeCount})
+	q.activeCount++
+
+	select {
+	case q.signal <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Next mengembalikan data berikutnya yang belum dikirim, menunggu kalau
+// antrian kosong
+func (q *DiskQueue) Next(ctx context.Context) (*sensorpb.SensorData, error) {
+	for {
+		q.mu.Lock()
+		for q.cursor < len(q.entries) {
+			e := q.entries[q.cursor]
+			q.cursor++
+			if !e.acked {
+				q.mu.Unlock()
+				return e.data, nil
+			}
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-q.signal:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Ack menandai data sudah diterima MicroB. Ack boleh datang tidak berurutan;
+// data dibuang dari depan antrian begitu semua data sebelumnya juga sudah di-ack.
+func (q *DiskQueue) Ack(seq uint64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	e, ok := q.bySeq[seq]
+	if !ok {
+		return
+	}
+	if e.acked {
+		return
+	}
+	e.acked = true
+	q.markDone(e)
+	for len(q.entries) > 0 && q.entries[0].acked {
+		q.popHead(false)
+	}
+}
+
+// Rewind mengulang pengiriman dari data tertua yang belum di-ack, dipakai
+// setelah reconnect atau nack
+func (q *DiskQueue) Rewind() {
+	q.mu.Lock()
+	q.cursor = 0
+	q.mu.Unlock()
+	select {
+	case q.signal <- struct{}{}:
+	default:
+	}
+}
+
+// Len jumlah data yang belum di-ack
+func (q *DiskQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.entries)
+}
+
+// Dropped jumlah data yang dibuang karena backlog penuh
+func (q *DiskQueue) Dropped() uint64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.dropped
+}
+
+// popHead membuang data tertua; dropped true kalau data dibuang karena
+// backlog penuh (belum di-ack), jadi perlu dicatat supaya tidak dimuat lagi.
+// Harus dipanggil dengan mu terkunci.
+func (q *DiskQueue) popHead(dropped bool) {
+	e := q.entries[0]
+	q.entries[0] = nil
+	q.entries = q.entries[1:]
+	if q.cursor > 0 {
+		q.cursor--
+	}
+	q.release(e, dropped)
+}
+
+// popTail membuang data terbaru, harus dipanggil dengan mu terkunci
+func (q *DiskQueue) popTail() {
+	last := len(q.entries) - 1
+	e := q.entries[last]
+	q.entries[last] = nil
+	q.entries = q.entries[:last]
+	q.cursor = min(q.cursor, len(q.entries))
+	q.release(e, true)
+}
+
+func (q *DiskQueue) release(e *entry, dropped bool) {
+	delete(q.bySeq, e.data.Seq)
+	q.outstanding[e.segment]--
+	if q.outstanding[e.segment] > 0 || e.segment == q.activeID {
+		if dropped {
+			q.markDone(e)
+		}
+		return
+	}
+	if err := q.removeSegment(e.segment); err != nil {
+		log.Printf("failed to remove queue segment %d: %v", e.segment, err)
+	}
+}
+
+// markDone mencatat record e di file .ack segment-nya
+func (q *DiskQueue) markDone(e *entry) {
+	f, ok := q.ackFiles[e.segment]
+	if !ok {
+		var err error
+		if f, err = os.OpenFile(q.ackPath(e.segment), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644); err != nil {
+			log.Printf("failed to record ack in queue segment %d: %v", e.segment, err)
+			return
+		}
+		q.ackFiles[e.segment] = f
+	}
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], e.index)
+	if _, err := f.Write(buf[:]); err != nil {
+		log.Printf("failed to record ack in queue segment %d: %v", e.segment, err)
+	}
+}
+
+// removeSegment menghapus segment beserta file .ack-nya
+func (q *DiskQueue) removeSegment(id uint64) error {
+	delete(q.outstanding, id)
+	if f, ok := q.ackFiles[id]; ok {
+		f.Close()
+		delete(q.ackFiles, id)
+	}
+	if err := os.Remove(q.segmentPath(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Remove(q.ackPath(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (q *DiskQueue) rotate() error {
+	if err := q.active.Close(); err != nil {
+		return err
+	}
+	oldID := q.activeID
+	if q.outstanding[oldID] == 0 {
+		if err := q.removeSegment(oldID); err != nil {
+			return err
+		}
+	}
+	return q.openSegment(oldID + 1)
+}
+
+func (q *DiskQueue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for id, f := range q.ackFiles {
+		f.Close()
+		delete(q.ackFiles, id)
+	}
+	if err := q.active.Close(); err != nil {
+		return err
+	}
+	if q.outstanding[q.activeID] == 0 {
+		return q.removeSegment(q.activeID)
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package queue
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/thomasdarmawan9/datastream-backend/proto/sensorpb"
+)
+
+func openQueue(t *testing.T, dir string, maxBacklog int, policy DropPolicy) *DiskQueue {
+	t.Helper()
+	q, err := Open(dir, maxBacklog, policy, 1<<20)
+	if err != nil {
+		t.Fatalf("open queue: %v", err)
+	}
+	return q
+}
+
+func push(t *testing.T, q *DiskQueue, seqs ...uint64) {
+	t.Helper()
+	for _, seq := range seqs {
+		if err := q.Push(&sensorpb.SensorData{Seq: seq, SensorType: "temperature", SensorValue: float64(seq)}); err != nil {
+			t.Fatalf("push %d: %v", seq, err)
+		}
+	}
+}
+
+// drain isi antrian yang belum di-ack, sesuai urutan kirim
+func drain(t *testing.T, q *DiskQueue) []uint64 {
+	t.Helper()
+	var seqs []uint64
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		d, err := q.Next(ctx)
+		cancel()
+		if err != nil {
+			return seqs
+		}
+		seqs = append(seqs, d.Seq)
+	}
+}
+
+// simulasi crash: file ditutup tanpa Close, jadi segment aktif tetap ada
+func crash(q *DiskQueue) {
+	q.active.Close()
+	for _, f := range q.ackFiles {
+		f.Close()
+	}
+}
+
+func TestDiskQueueRestartSkipsAcked(t *testing.T) {
+	dir := t.TempDir()
+	q := openQueue(t, dir, 100, DropOldest)
+	push(t, q, 1, 2, 3, 4, 5)
+	// ack tidak berurutan, 1 belum di-ack jadi segment belum bisa dihapus
+	q.Ack(2)
+	q.Ack(4)
+	crash(q)
+
+	q = openQueue(t, dir, 100, DropOldest)
+	defer q.Close()
+	if got, want := drain(t, q), []uint64{1, 3, 5}; !slices.Equal(got, want) {
+		t.Fatalf("reloaded %v, want %v", got, want)
+	}
+}
+
+func TestDiskQueueReloadTwice(t *testing.T) {
+	dir := t.TempDir()
+	q := openQueue(t, dir, 100, DropOldest)
+	push(t, q, 1, 2, 3)
+	q.Ack(3)
+	crash(q)
+
+	// ack setelah reload harus tetap tercatat di segment lama
+	q = openQueue(t, dir, 100, DropOldest)
+	push(t, q, 4)
+	q.Ack(2)
+	crash(q)
+
+	q = openQueue(t, dir, 100, DropOldest)
+	if got, want := drain(t, q), []uint64{1, 4}; !slices.Equal(got, want) {
+		t.Fatalf("reloaded %v, want %v", got, want)
+	}
+	q.Ack(1)
+	q.Ack(4)
+	if err := q.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	files, _ := os.ReadDir(dir)
+	if len(files) != 0 {
+		var names []string
+		for _, f := range files {
+			names = append(names, f.Name())
+		}
+		t.Fatalf("files left after everything acked: %v", names)
+	}
+}
+
+func TestDiskQueueCloseKeepsUnacked(t *testing.T) {
+	dir := t.TempDir()
+	q := openQueue(t, dir, 100, DropOldest)
+	push(t, q, 1, 2)
+	q.Ack(1)
+	if err := q.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	q = openQueue(t, dir, 100, DropOldest)
+	defer q.Close()
+	if got, want := drain(t, q), []uint64{2}; !slices.Equal(got, want) {
+		t.Fatalf("reloaded %v, want %v", got, want)
+	}
+}
+
+func TestDiskQueueTrimOnReload(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy DropPolicy
+		want   []uint64
+	}{
+		{"drop oldest", DropOldest, []uint64{4, 5, 6}},
+		{"drop newest", DropNewest, []uint64{1, 3, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			q := openQueue(t, dir, 100, tt.policy)
+			push(t, q, 1, 2, 3, 4, 5, 6)
+			// 2 sudah di-ack, tidak ikut dihitung ke backlog
+			q.Ack(2)
+			crash(q)
+
+			q = openQueue(t, dir, 3, tt.policy)
+			if got := drain(t, q); !slices.Equal(got, tt.want) {
+				t.Fatalf("reloaded %v, want %v", got, tt.want)
+			}
+			if got := q.Dropped(); got != 2 {
+				t.Fatalf("dropped %d, want 2", got)
+			}
+			crash(q)
+
+			// data yang dibuang saat trim tidak muncul lagi
+			q = openQueue(t, dir, 100, tt.policy)
+			defer q.Close()
+			if got := drain(t, q); !slices.Equal(got, tt.want) {
+				t.Fatalf("reloaded again %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiskQueuePushFull(t *testing.T) {
+	dir := t.TempDir()
+	q := openQueue(t, dir, 2, DropNewest)
+	push(t, q, 1, 2)
+	if err := q.Push(&sensorpb.SensorData{Seq: 3}); err != ErrBacklogFull {
+		t.Fatalf("push on full backlog: %v, want ErrBacklogFull", err)
+	}
+	q.Close()
+
+	q = openQueue(t, dir, 2, DropOldest)
+	push(t, q, 3)
+	crash(q)
+
+	q = openQueue(t, dir, 2, DropOldest)
+	defer q.Close()
+	if got, want := drain(t, q), []uint64{2, 3}; !slices.Equal(got, want) {
+		t.Fatalf("reloaded %v, want %v", got, want)
+	}
+}
+
+func TestDiskQueueTornAckFile(t *testing.T) {
+	dir := t.TempDir()
+	q := openQueue(t, dir, 100, DropOldest)
+	push(t, q, 1, 2, 3)
+	q.Ack(2)
+	crash(q)
+
+	// tulisan ack terakhir terpotong
+	path := filepath.Join(dir, "00000000000000000001"+ackSuffix)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("open ack file: %v", err)
+	}
+	f.Write([]byte{2, 0})
+	f.Close()
+
+	q = openQueue(t, dir, 100, DropOldest)
+	defer q.Close()
+	if got, want := drain(t, q), []uint64{1, 3}; !slices.Equal(got, want) {
+		t.Fatalf("reloaded %v, want %v", got, want)
+	}
+}
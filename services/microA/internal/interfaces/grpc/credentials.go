@@ -0,0 +1,39 @@
+package grpc
+
+import (
+	"context"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// ProducerCredentials kredensial per-RPC untuk MicroB: API key dikirim lewat
+// metadata "x-api-key", token JWT lewat "authorization: Bearer ..."
+type ProducerCredentials struct {
+	APIKey string
+	Token  string
+	// AllowInsecure kredensial boleh dikirim lewat koneksi tanpa TLS, di mana
+	// kredensial terkirim apa adanya; hanya untuk development
+	AllowInsecure bool
+}
+
+var _ credentials.PerRPCCredentials = ProducerCredentials{}
+
+func (c ProducerCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	md := map[string]string{}
+	if c.APIKey != "" {
+		md["x-api-key"] = c.APIKey
+	}
+	if c.Token != "" {
+		md["authorization"] = "Bearer " + c.Token
+	}
+	return md, nil
+}
+
+func (c ProducerCredentials) RequireTransportSecurity() bool {
+	return !c.AllowInsecure
+}
+
+// Empty true kalau tidak ada kredensial yang dikonfigurasi
+func (c ProducerCredentials) Empty() bool {
+	return c.APIKey == "" && c.Token == ""
+}
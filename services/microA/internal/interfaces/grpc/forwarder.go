@@ -0,0 +1,191 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/thomasdarmawan9/datastream-backend/proto/sensorpb"
+	"github.com/thomasdarmawan9/datastream-backend/services/microA/internal/infrastructure/queue"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// Forwarder mengirim isi DiskQueue ke MicroB lewat StreamDataWithAck. Data
+// baru dibuang dari antrian setelah di-ack; kalau stream putus, Forwarder
+// reconnect dengan exponential backoff + jitter lalu mengirim ulang semua
+// data yang belum di-ack secara berurutan. Sebelum membuka stream, Forwarder
+// menanyakan health service MicroB dan menunggu sampai SERVING.
+type Forwarder struct {
+	client sensorpb.SensorServiceClient
+	health healthpb.HealthClient
+	queue  *queue.DiskQueue
+	cfg    ForwarderConfig
+
+	connected atomic.Bool
+}
+
+type ForwarderConfig struct {
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// DrainTimeout batas menunggu ack terakhir saat shutdown sebelum stream diputus
+	DrainTimeout time.Duration
+}
+
+// health boleh nil kalau health check tidak dipakai
+func NewForwarder(client sensorpb.SensorServiceClient, health healthpb.HealthClient, q *queue.DiskQueue, cfg ForwarderConfig) *Forwarder {
+	return &Forwarder{client: client, health: health, queue: q, cfg: cfg}
+}
+
+// Connected true selama stream ke MicroB terbuka
+func (f *Forwarder) Connected() bool {
+	return f.connected.Load()
+}
+
+// Run berjalan sampai ctx selesai. Saat ctx selesai stream yang terbuka
+// ditutup baik-baik: sisi kirim ditutup lalu ack data yang sudah terkirim
+// ditunggu (maksimal DrainTimeout), sisanya tetap di antrian untuk start berikutnya.
+func (f *Forwarder) Run(ctx context.Context) {
+	backoff := f.cfg.MinBackoff
+	for ctx.Err() == nil {
+		progressed, err := f.session(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if progressed {
+			backoff = f.cfg.MinBackoff
+		}
+
+		// full jitter: tunggu acak antara backoff/2 dan backoff
+		wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		log.Printf("MicroB stream closed: %v; reconnecting in %v (backlog: %d)", err, wait, f.queue.Len())
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return
+		}
+		backoff = min(backoff*2, f.cfg.MaxBackoff)
+	}
+}
+
+// session membuka satu stream dan mengirim antrian sampai stream putus.
+// progressed true kalau minimal satu ack diterima.
+func (f *Forwarder) session(ctx context.Context) (progressed bool, err error) {
+	if err := f.checkHealth(ctx); err != nil {
+		return false, err
+	}
+
+	// stream tidak ikut ctx supaya saat shutdown ack terakhir masih bisa
+	// diterima; pengiriman data baru (nctx) berhenti begitu ctx selesai
+	sctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	nctx, stopSending := context.WithCancel(sctx)
+	defer stopSending()
+	defer context.AfterFunc(ctx, stopSending)()
+
+	stream, err := f.client.StreamDataWithAck(sctx)
+	if err != nil {
+		return false, err
+	}
+	f.connected.Store(true)
+	defer f.connected.Store(false)
+
+	// kirim ulang dari data tertua yang belum di-ack
+	f.queue.Rewind()
+	if n := f.queue.Len(); n > 0 {
+		log.Printf("Connected to MicroB, draining backlog of %d readings", n)
+	}
+
+	var acked, nacked atomic.Bool
+	recvErr := make(chan error, 1)
+	go func() {
+		defer cancel()
+		for {
+			ack, err := stream.Recv()
+			if err != nil {
+				recvErr <- err
+				return
+			}
+			switch ack.Status {
+			case "ok":
+				f.queue.Ack(ack.Seq)
+				acked.Store(true)
+				continue
+			case "rejected":
+				// tidak lolos validasi MicroB, percuma dikirim ulang
+				log.Printf("Rejected seq=%d: %s", ack.Seq, ack.Message)
+				f.queue.Ack(ack.Seq)
+				acked.Store(true)
+				continue
+			}
+			// nack: MicroB gagal menyimpan, nanti dikirim ulang
+			log.Printf("Nack seq=%d: %s", ack.Seq, ack.Message)
+			nacked.Store(true)
+		}
+	}()
+
+	for {
+		if nacked.Swap(false) {
+			// beri jeda supaya MicroB yang sedang bermasalah tidak dibanjiri
+			// kiriman ulang, lalu ulang dari data tertua yang belum di-ack
+			select {
+			case <-time.After(f.cfg.MinBackoff):
+			case <-nctx.Done():
+			}
+			f.queue.Rewind()
+		}
+		data, err := f.queue.Next(nctx)
+		if err != nil {
+			break
+		}
+		if err := stream.Send(&sensorpb.StreamRequest{Data: data}); err != nil {
+			break
+		}
+	}
+
+	if ctx.Err() != nil && sctx.Err() == nil {
+		// shutdown: tutup sisi kirim, MicroB menyelesaikan data yang sudah
+		// diterima, mengirim ack-nya lalu menutup stream
+		_ = stream.CloseSend()
+		select {
+		case err = <-recvErr:
+		case <-time.After(f.cfg.DrainTimeout):
+			log.Printf("Timed out waiting for final acks from MicroB after %v", f.cfg.DrainTimeout)
+			cancel()
+			err = <-recvErr
+		}
+	} else {
+		cancel()
+		err = <-recvErr
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	return acked.Load(), err
+}
+
+// checkHealth nil kalau MicroB SERVING. MicroB versi lama tanpa health
+// service (Unimplemented) dianggap siap.
+func (f *Forwarder) checkHealth(ctx context.Context) error {
+	if f.health == nil {
+		return nil
+	}
+	hctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	resp, err := f.health.Check(hctx, &healthpb.HealthCheckRequest{Service: sensorpb.SensorService_ServiceDesc.ServiceName})
+	if status.Code(err) == codes.Unimplemented {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("health check: %w", err)
+	}
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("MicroB not ready (%s)", resp.Status)
+	}
+	return nil
+}
@@ -14,8 +14,13 @@ type MicroBClient struct {
 	conn   *grpc.ClientConn
 }
 
-func NewMicroBClient(address string) (*MicroBClient, error) {
-	conn, err := grpc.Dial(address, grpc.WithInsecure())
+// opts tambahan, misalnya grpc.WithPerRPCCredentials(ProducerCredentials{...})
+func NewMicroBClient(address string, tlsCfg TLSConfig, opts ...grpc.DialOption) (*MicroBClient, error) {
+	transport, err := TransportOption(tlsCfg)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := grpc.Dial(address, append([]grpc.DialOption{transport}, opts...)...)
 	if err != nil {
 		return nil, err
 	}
@@ -56,3 +61,24 @@ func (c *MicroBClient) StreamSensorData(data []*sensorpb.SensorData) error {
 	log.Printf("MicroB response: %s - %s", res.Status, res.Message)
 	return nil
 }
+
+// SendBatch alternatif StreamSensorData untuk producer yang mengirim data
+// sesekali: semua data dikirim dalam satu request IngestBatch dan disimpan
+// MicroB dalam satu transaksi. Data yang ditolak validasi dilaporkan lewat
+// status per item; error berarti tidak ada data yang tersimpan.
+func (c *MicroBClient) SendBatch(data []*sensorpb.SensorData) (*sensorpb.IngestBatchResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	res, err := c.client.IngestBatch(ctx, &sensorpb.IngestBatchRequest{Data: data})
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range res.Items {
+		if item.Status != "ok" {
+			log.Printf("MicroB %s seq=%d: %s", item.Status, item.Seq, item.Message)
+		}
+	}
+	log.Printf("MicroB batch response: %d stored, %d duplicates, %d rejected", res.Stored, res.Duplicates, res.Rejected)
+	return res, nil
+}
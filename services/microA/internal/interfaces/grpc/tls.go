@@ -0,0 +1,55 @@
+package grpc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// TLSConfig pengaturan TLS koneksi ke MicroB
+type TLSConfig struct {
+	Enabled bool
+	// CAFile CA untuk memverifikasi sertifikat MicroB; kosong artinya pakai CA sistem
+	CAFile string
+	// CertFile/KeyFile sertifikat client untuk mTLS; CN-nya jadi identitas producer
+	CertFile   string
+	KeyFile    string
+	ServerName string // override nama host yang dicek di sertifikat MicroB
+}
+
+// TransportOption mengembalikan DialOption sesuai cfg; tanpa TLS koneksi plaintext
+func TransportOption(cfg TLSConfig) (grpc.DialOption, error) {
+	if !cfg.Enabled {
+		return grpc.WithTransportCredentials(insecure.NewCredentials()), nil
+	}
+
+	tlsCfg := &tls.Config{
+		ServerName: cfg.ServerName,
+		MinVersion: tls.VersionTLS12,
+	}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("CA file contains no certificates")
+		}
+		tlsCfg.RootCAs = pool
+	}
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+	return grpc.WithTransportCredentials(credentials.NewTLS(tlsCfg)), nil
+}
@@ -0,0 +1,156 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/thomasdarmawan9/datastream-backend/services/internal/testpki"
+)
+
+// startServer gRPC server TLS seperti MicroB; clientCA kosong artinya tanpa
+// sertifikat client
+func startServer(t *testing.T, server testpki.Pair, clientCA string) string {
+	t.Helper()
+	cert, err := tls.LoadX509KeyPair(server.CertFile, server.KeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tlsCfg := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if clientCA != "" {
+		pem, err := os.ReadFile(clientCA)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tlsCfg.ClientCAs = x509.NewCertPool()
+		tlsCfg.ClientCAs.AppendCertsFromPEM(pem)
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	srv := grpc.NewServer(grpc.Creds(credentials.NewTLS(tlsCfg)))
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	return lis.Addr().String()
+}
+
+func dial(t *testing.T, addr string, cfg TLSConfig) error {
+	t.Helper()
+	opt, err := TransportOption(cfg)
+	if err != nil {
+		t.Fatalf("transport option: %v", err)
+	}
+	conn, err := grpc.NewClient(addr, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	return err
+}
+
+func TestTransportOption(t *testing.T) {
+	dir := t.TempDir()
+	ca := testpki.NewCA(t, dir, "ca")
+	otherCA := testpki.NewCA(t, dir, "other-ca")
+	server := ca.Server(t, "microb")
+	client := ca.Client(t, "microa-1")
+	stranger := otherCA.Client(t, "microa-1")
+
+	tests := []struct {
+		name     string
+		clientCA string // kosong: server tanpa mTLS
+		cfg      TLSConfig
+		wantErr  bool
+	}{
+		{
+			name:     "mtls",
+			clientCA: ca.File,
+			cfg:      TLSConfig{Enabled: true, CAFile: ca.File, CertFile: client.CertFile, KeyFile: client.KeyFile},
+		},
+		{
+			name:     "mtls without client cert",
+			clientCA: ca.File,
+			cfg:      TLSConfig{Enabled: true, CAFile: ca.File},
+			wantErr:  true,
+		},
+		{
+			name:     "mtls with client cert from other CA",
+			clientCA: ca.File,
+			cfg:      TLSConfig{Enabled: true, CAFile: ca.File, CertFile: stranger.CertFile, KeyFile: stranger.KeyFile},
+			wantErr:  true,
+		},
+		{
+			name: "server only",
+			cfg:  TLSConfig{Enabled: true, CAFile: ca.File},
+		},
+		{
+			name:    "server cert from untrusted CA",
+			cfg:     TLSConfig{Enabled: true, CAFile: otherCA.File},
+			wantErr: true,
+		},
+		{
+			name:    "server name mismatch",
+			cfg:     TLSConfig{Enabled: true, CAFile: ca.File, ServerName: "microb.example"},
+			wantErr: true,
+		},
+		{
+			name:    "plaintext to TLS server",
+			cfg:     TLSConfig{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := startServer(t, server, tt.clientCA)
+			err := dial(t, addr, tt.cfg)
+			if tt.wantErr && err == nil {
+				t.Fatal("call succeeded, want rejection")
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("call failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestTransportOptionErrors(t *testing.T) {
+	dir := t.TempDir()
+	ca := testpki.NewCA(t, dir, "ca")
+	client := ca.Client(t, "microa-1")
+	empty := dir + "/empty.pem"
+	if err := os.WriteFile(empty, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		cfg  TLSConfig
+	}{
+		{"missing CA", TLSConfig{Enabled: true, CAFile: dir + "/missing.crt"}},
+		{"CA without certificates", TLSConfig{Enabled: true, CAFile: empty}},
+		{"missing client key", TLSConfig{Enabled: true, CAFile: ca.File, CertFile: client.CertFile, KeyFile: dir + "/missing.key"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := TransportOption(tt.cfg); err == nil {
+				t.Fatal("want error")
+			}
+		})
+	}
+}
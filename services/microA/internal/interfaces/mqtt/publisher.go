@@ -0,0 +1,293 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/thomasdarmawan9/datastream-backend/proto/sensorpb"
+	"github.com/thomasdarmawan9/datastream-backend/services/microA/internal/infrastructure/queue"
+)
+
+// format payload yang dikirim
+const (
+	PayloadJSON  = "json"
+	PayloadProto = "proto"
+)
+
+// PublisherConfig pengaturan mode publish MQTT
+type PublisherConfig struct {
+	BrokerURL string
+	ClientID  string
+	Username  string
+	Password  string
+	// Topic template, placeholder {id1}, {id2}, {type} dan {producer}
+	Topic   string
+	QoS     byte
+	Retain  bool   // retained message: subscriber baru langsung dapat nilai terakhir per topic
+	Payload string // PayloadJSON atau PayloadProto
+	// StatusTopic topic status producer ("online"/"offline", retained); juga
+	// dipakai untuk last will kalau koneksi putus tanpa disconnect. Kosong
+	// artinya tidak dipakai.
+	StatusTopic string
+	ProducerID  string
+
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+	AckTimeout  time.Duration // batas menunggu PUBACK sebelum data dikirim ulang
+	MaxInflight int           // data yang boleh menunggu PUBACK bersamaan
+	// DrainTimeout batas menunggu PUBACK terakhir saat shutdown
+	DrainTimeout time.Duration
+}
+
+var errConnectionLost = errors.New("connection to broker lost")
+
+// Publisher pengganti Forwarder untuk mode MQTT: isi DiskQueue di-publish ke
+// broker dan baru dibuang dari antrian setelah broker membalas PUBACK (QoS 1
+// ke atas). Kalau koneksi putus atau PUBACK tidak datang, Publisher menunggu
+// dengan exponential backoff + jitter lalu mengirim ulang semua data yang
+// belum di-ack secara berurutan. Reconnect ke broker ditangani client paho.
+type Publisher struct {
+	cfg    PublisherConfig
+	queue  *queue.DiskQueue
+	client paho.Client
+
+	connected atomic.Bool
+	onConnect chan struct{}
+	onLost    chan struct{}
+}
+
+func NewPublisher(cfg PublisherConfig, q *queue.DiskQueue) (*Publisher, error) {
+	if cfg.QoS > 2 {
+		return nil, fmt.Errorf("invalid QoS %d", cfg.QoS)
+	}
+	if cfg.Payload != PayloadJSON && cfg.Payload != PayloadProto {
+		return nil, fmt.Errorf("unknown payload format %q", cfg.Payload)
+	}
+	if cfg.MaxInflight <= 0 {
+		cfg.MaxInflight = 1
+	}
+	p := &Publisher{
+		cfg:       cfg,
+		queue:     q,
+		onConnect: make(chan struct{}, 1),
+		onLost:    make(chan struct{}, 1),
+	}
+
+	opts := paho.NewClientOptions().
+		AddBroker(cfg.BrokerURL).
+		SetClientID(cfg.ClientID).
+		SetUsername(cfg.Username).
+		SetPassword(cfg.Password).
+		// data yang belum di-ack sudah aman di DiskQueue, sesi broker tidak perlu disimpan
+		SetCleanSession(true).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(cfg.MinBackoff).
+		SetMaxReconnectInterval(cfg.MaxBackoff).
+		SetOnConnectHandler(p.handleConnect).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			log.Printf("MQTT connection lost: %v (backlog: %d)", err, q.Len())
+			p.connected.Store(false)
+			notify(p.onLost)
+		})
+	if topic := p.statusTopic(); topic != "" {
+		opts.SetWill(topic, "offline", cfg.QoS, true)
+	}
+	p.client = paho.NewClient(opts)
+	return p, nil
+}
+
+// Connected true selama koneksi ke broker terbuka
+func (p *Publisher) Connected() bool {
+	return p.connected.Load()
+}
+
+// Run berjalan sampai ctx selesai. Saat ctx selesai PUBACK data yang sudah
+// terkirim ditunggu (maksimal DrainTimeout), status "offline" dikirim lalu
+// koneksi ditutup; sisanya tetap di antrian untuk start berikutnya.
+func (p *Publisher) Run(ctx context.Context) {
+	log.Printf("Connecting to MQTT broker %s", p.cfg.BrokerURL)
+	p.client.Connect()
+	defer p.disconnect()
+
+	backoff := p.cfg.MinBackoff
+	for p.waitConnected(ctx) {
+		// kirim ulang dari data tertua yang belum di-ack
+		p.queue.Rewind()
+		if n := p.queue.Len(); n > 0 {
+			log.Printf("Connected to MQTT broker, draining backlog of %d readings", n)
+		}
+		progressed, err := p.session(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if progressed {
+			backoff = p.cfg.MinBackoff
+		}
+
+		wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		log.Printf("MQTT publish interrupted: %v; retrying in %v (backlog: %d)", err, wait, p.queue.Len())
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return
+		}
+		backoff = min(backoff*2, p.cfg.MaxBackoff)
+	}
+}
+
+// waitConnected false kalau ctx selesai sebelum terhubung
+func (p *Publisher) waitConnected(ctx context.Context) bool {
+	for !p.connected.Load() {
+		select {
+		case <-p.onConnect:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return ctx.Err() == nil
+}
+
+type inflight struct {
+	seq   uint64
+	token paho.Token
+}
+
+// session publish isi antrian sampai koneksi putus, PUBACK gagal, atau ctx
+// selesai. progressed true kalau minimal satu data di-ack.
+func (p *Publisher) session(ctx context.Context) (progressed bool, err error) {
+	sctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	// koneksi putus di tengah session
+	select {
+	case <-p.onLost:
+	default:
+	}
+	go func() {
+		select {
+		case <-p.onLost:
+			cancel(errConnectionLost)
+		case <-sctx.Done():
+		}
+	}()
+
+	// PUBACK ditunggu berurutan di goroutine sendiri supaya publish tidak
+	// tertahan satu per satu
+	pending := make(chan inflight, p.cfg.MaxInflight)
+	var acked atomic.Bool
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for m := range pending {
+			if sctx.Err() != nil && context.Cause(sctx) != ctx.Err() {
+				// session sudah gagal: sisa PUBACK tidak ditunggu, yang belum
+				// selesai dikirim ulang dari antrian di session berikutnya.
+				// Saat shutdown tetap ditunggu sampai DrainTimeout.
+				select {
+				case <-m.token.Done():
+				default:
+					continue
+				}
+			} else if !m.token.WaitTimeout(p.cfg.AckTimeout) {
+				cancel(fmt.Errorf("no PUBACK for seq=%d after %v", m.seq, p.cfg.AckTimeout))
+				continue
+			}
+			if err := m.token.Error(); err != nil {
+				cancel(fmt.Errorf("publish seq=%d: %w", m.seq, err))
+				continue
+			}
+			p.queue.Ack(m.seq)
+			acked.Store(true)
+		}
+	}()
+
+	for {
+		data, err := p.queue.Next(sctx)
+		if err != nil {
+			break
+		}
+		payload, err := p.encode(data)
+		if err != nil {
+			// tidak akan berhasil walau diulang
+			log.Printf("Dropping reading seq=%d: %v", data.Seq, err)
+			p.queue.Ack(data.Seq)
+			continue
+		}
+		token := p.client.Publish(p.topic(data), p.cfg.QoS, p.cfg.Retain, payload)
+		select {
+		case pending <- inflight{seq: data.Seq, token: token}:
+		case <-sctx.Done():
+		}
+	}
+	close(pending)
+
+	if ctx.Err() != nil && context.Cause(sctx) == ctx.Err() {
+		// shutdown: tunggu PUBACK data yang sudah terkirim
+		select {
+		case <-done:
+		case <-time.After(p.cfg.DrainTimeout):
+			log.Printf("Timed out waiting for final PUBACKs after %v", p.cfg.DrainTimeout)
+		}
+		return acked.Load(), nil
+	}
+	<-done
+	return acked.Load(), context.Cause(sctx)
+}
+
+func (p *Publisher) handleConnect(c paho.Client) {
+	log.Printf("Connected to MQTT broker %s", p.cfg.BrokerURL)
+	if topic := p.statusTopic(); topic != "" {
+		c.Publish(topic, p.cfg.QoS, true, "online")
+	}
+	p.connected.Store(true)
+	notify(p.onConnect)
+}
+
+// disconnect mengirim status offline (last will hanya dikirim broker kalau
+// koneksi putus tidak normal) lalu menutup koneksi
+func (p *Publisher) disconnect() {
+	if topic := p.statusTopic(); topic != "" && p.client.IsConnectionOpen() {
+		p.client.Publish(topic, p.cfg.QoS, true, "offline").WaitTimeout(2 * time.Second)
+	}
+	p.client.Disconnect(250)
+	p.connected.Store(false)
+}
+
+func (p *Publisher) topic(data *sensorpb.SensorData) string {
+	return strings.NewReplacer(
+		"{id1}", data.Id1,
+		"{id2}", strconv.Itoa(int(data.Id2)),
+		"{type}", data.SensorType,
+		"{producer}", p.cfg.ProducerID,
+	).Replace(p.cfg.Topic)
+}
+
+func (p *Publisher) statusTopic() string {
+	return strings.ReplaceAll(p.cfg.StatusTopic, "{producer}", p.cfg.ProducerID)
+}
+
+func (p *Publisher) encode(data *sensorpb.SensorData) ([]byte, error) {
+	if p.cfg.Payload == PayloadProto {
+		return proto.Marshal(data)
+	}
+	return json.Marshal(sensorpb.NewJSONPayload(data))
+}
+
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
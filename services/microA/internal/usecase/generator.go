@@ -33,7 +33,7 @@ func (g *SensorGenerator) Generate() <-chan *domain.SensorData {
 			ch <- &domain.SensorData{
 				SensorValue: 18 + rand.Float64()*12,
 				SensorType:  "temperature",
-				ID1:         "room-101",
+				ID1:         "ROOM-101",
 				ID2:         rand.Intn(3) + 1,
 				TS:          now,
 				CreatedAt:   now,
@@ -43,7 +43,7 @@ func (g *SensorGenerator) Generate() <-chan *domain.SensorData {
 			ch <- &domain.SensorData{
 				SensorValue: 30 + rand.Float64()*40,
 				SensorType:  "humidity",
-				ID1:         "room-102",
+				ID1:         "ROOM-102",
 				ID2:         rand.Intn(2) + 1,
 				TS:          now,
 				CreatedAt:   now,
@@ -53,7 +53,7 @@ func (g *SensorGenerator) Generate() <-chan *domain.SensorData {
 			ch <- &domain.SensorData{
 				SensorValue: 400 + rand.Float64()*1600,
 				SensorType:  "co2",
-				ID1:         "meeting-room-A",
+				ID1:         "MEETING-ROOM-A",
 				ID2:         1,
 				TS:          now,
 				CreatedAt:   now,
@@ -63,7 +63,7 @@ func (g *SensorGenerator) Generate() <-chan *domain.SensorData {
 			ch <- &domain.SensorData{
 				SensorValue: float64(rand.Intn(2)),
 				SensorType:  "motion",
-				ID1:         "corridor-1",
+				ID1:         "CORRIDOR-1",
 				ID2:         rand.Intn(5) + 1,
 				TS:          now,
 				CreatedAt:   now,
@@ -73,7 +73,7 @@ func (g *SensorGenerator) Generate() <-chan *domain.SensorData {
 			ch <- &domain.SensorData{
 				SensorValue: 100 + rand.Float64()*900,
 				SensorType:  "light",
-				ID1:         "room-101",
+				ID1:         "ROOM-101",
 				ID2:         rand.Intn(2) + 1,
 				TS:          now,
 				CreatedAt:   now,
@@ -83,7 +83,7 @@ func (g *SensorGenerator) Generate() <-chan *domain.SensorData {
 			ch <- &domain.SensorData{
 				SensorValue: 30 + rand.Float64()*70,
 				SensorType:  "noise",
-				ID1:         "cafeteria",
+				ID1:         "CAFETERIA",
 				ID2:         1,
 				TS:          now,
 				CreatedAt:   now,
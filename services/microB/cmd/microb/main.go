@@ -1,16 +1,32 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log"
 	"net"
+	nethttp "net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/thomasdarmawan9/datastream-backend/services/internal/probe"
 	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/domain"
 	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/infrastructure/auth"
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/infrastructure/deadletter"
 	grpcInfra "github.com/thomasdarmawan9/datastream-backend/services/microB/internal/infrastructure/grpc"
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/infrastructure/health"
+	mqttInfra "github.com/thomasdarmawan9/datastream-backend/services/microB/internal/infrastructure/mqtt"
 	mysqlRepo "github.com/thomasdarmawan9/datastream-backend/services/microB/internal/infrastructure/mysql"
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/infrastructure/wal"
 	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/interfaces/http"
 	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/interfaces/middleware"
 	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/usecase"
@@ -21,6 +37,9 @@ import (
 	"github.com/labstack/echo/v4"
 	echomw "github.com/labstack/echo/v4/middleware"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 
@@ -41,8 +60,19 @@ import (
 // @host localhost:8080
 // @BasePath /api
 func main() {
+	healthcheck := flag.Bool("healthcheck", false, "query /readyz of the running service and exit 0 if ready (for docker healthcheck)")
+	flag.Parse()
+
 	_ = godotenv.Load()
 
+	if *healthcheck {
+		port := os.Getenv("PORT")
+		if port == "" {
+			port = "8080"
+		}
+		os.Exit(runHealthcheck(port))
+	}
+
 	// --- Load ENV ---
 	dsn := os.Getenv("DB_DSN") // contoh: root@tcp(localhost:3306)/datastream?parseTime=true
 	if dsn == "" {
@@ -60,39 +90,247 @@ func main() {
 	if grpcPort == "" {
 		grpcPort = "50051"
 	}
-
-	// --- DB Init ---
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
-	if err != nil {
-		log.Fatal("failed to connect db: ", err)
+	// jumlah (producer_id, seq, ts) terakhir yang diingat untuk dedup
+	dedupCacheSize := envInt("DEDUP_CACHE_SIZE", 100000)
+	deadLetterDir := os.Getenv("DEADLETTER_DIR")
+	if deadLetterDir == "" {
+		deadLetterDir = "data/deadletter"
 	}
-	if err := db.AutoMigrate(&domain.User{}, &domain.SensorData{}); err != nil {
-		log.Fatal("failed migrate: ", err)
+	walDir := os.Getenv("WAL_DIR")
+	if walDir == "" {
+		walDir = "data/wal"
 	}
-	sqlDB, err := db.DB()
+	validationCfg := usecase.ValidationConfig{
+		ID1Pattern: os.Getenv("VALIDATE_ID1_PATTERN"),
+		ID2Min:     envInt("VALIDATE_ID2_MIN", 1),
+		ID2Max:     envInt("VALIDATE_ID2_MAX", 9999),
+		MaxFuture:  envDuration("VALIDATE_MAX_FUTURE", 5*time.Minute),
+		// backlog MicroA bisa tertahan lama kalau MicroB down
+		MaxAge: envDuration("VALIDATE_MAX_AGE", 30*24*time.Hour),
+	}
+	ingestCfg := usecase.IngestConfig{
+		QueueSize:      envInt("INGEST_MAX_BUFFERED", 5000),
+		BatchSize:      envInt("INGEST_MAX_BATCH_SIZE", 500),
+		FlushInterval:  envDuration("INGEST_FLUSH_INTERVAL", 5*time.Second),
+		Workers:        envInt("INGEST_WORKERS", 4),
+		MaxRetries:     envInt("INGEST_MAX_RETRIES", 3),
+		RetryBackoff:   envDuration("INGEST_RETRY_BACKOFF", 500*time.Millisecond),
+		EnqueueTimeout: envDuration("INGEST_BACKPRESSURE_TIMEOUT", 30*time.Second),
+	}
+	shutdownTimeout := envDuration("SHUTDOWN_TIMEOUT", 30*time.Second)
+
+	// SIGINT/SIGTERM memicu graceful shutdown (lihat shutdown di bawah)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// --- DB Init ---
+	// koneksi dibuka lazy; ping, migrasi dan seed jalan di background (lihat
+	// initDatabase) supaya health check sudah bisa menjawab NOT_SERVING
+	sqlDB, err := mysqlRepo.OpenDB(dsn)
 	if err != nil {
-		log.Fatal("failed to get sql.DB from gorm.DB: ", err)
+		log.Fatal("invalid DB_DSN: ", err)
 	}
+	readiness := health.NewReadiness()
 
 	// --- Repository ---
 	userRepo := mysqlRepo.NewUserRepository(sqlDB)
-	sensorRepo := mysqlRepo.NewSensorRepository(sqlDB)
+	// rollup 1m/1h/1d di-update bersama insert, ROLLUP_ENABLED=false untuk mematikan
+	var rollups *mysqlRepo.RollupManager
+	if envBool("ROLLUP_ENABLED", true) {
+		rollups, err = mysqlRepo.NewRollupManager(sqlDB, mysqlRepo.RollupConfig{
+			Retention: map[domain.RollupLevel]time.Duration{
+				domain.RollupMinute: envDuration("ROLLUP_RETENTION_1M", 30*24*time.Hour),
+				domain.RollupHour:   envDuration("ROLLUP_RETENTION_1H", 365*24*time.Hour),
+				domain.RollupDay:    envDuration("ROLLUP_RETENTION_1D", 0),
+			},
+			Backfill:      envBool("ROLLUP_BACKFILL", true),
+			CheckInterval: envDuration("ROLLUP_CHECK_INTERVAL", time.Hour),
+		})
+		if err != nil {
+			log.Fatal("invalid rollup config: ", err)
+		}
+	}
+	sensorRepo := mysqlRepo.NewSensorRepository(sqlDB, mysqlRepo.SensorRepoConfig{
+		InsertChunkSize: envInt("INSERT_CHUNK_SIZE", 500),
+		MaxPacketBytes:  envInt("INSERT_MAX_PACKET_BYTES", 0),
+		Rollups:         rollups,
+	})
+	sensorTypeRepo := mysqlRepo.NewSensorTypeRepository(sqlDB)
+	retentionRepo := mysqlRepo.NewRetentionPolicyRepository(sqlDB)
+	// partisi sensor_data per hari/bulan, default mati karena tabel lama
+	// di-copy ulang oleh MySQL saat pertama kali dipartisi
+	var partitions *mysqlRepo.PartitionManager
+	if partitionInterval := os.Getenv("PARTITION_INTERVAL"); partitionInterval != "" && partitionInterval != "off" {
+		partitions, err = mysqlRepo.NewPartitionManager(sqlDB, mysqlRepo.PartitionConfig{
+			Interval:      mysqlRepo.PartitionInterval(partitionInterval),
+			Premake:       envInt("PARTITION_PREMAKE", 7),
+			Retention:     envDuration("PARTITION_RETENTION", 0),
+			CheckInterval: envDuration("PARTITION_CHECK_INTERVAL", time.Hour),
+		})
+		if err != nil {
+			log.Fatal("invalid partition config: ", err)
+		}
+	}
+	deadLetterRepo, err := deadletter.NewFileRepository(deadLetterDir)
+	if err != nil {
+		log.Fatal("failed to open dead letter dir: ", err)
+	}
 
 	// --- Usecase ---
 	userUC := usecase.NewUserUsecase(userRepo)
-	sensorUC := usecase.NewSensorUsecase(sensorRepo)
+	sensorTypeUC := usecase.NewSensorTypeUsecase(sensorTypeRepo)
+	sensorUC := usecase.NewSensorUsecase(sensorRepo, sensorTypeUC)
 	jwtExpiry := 24 * time.Hour
 	jwtManager := auth.NewJWTManager(jwtSecret, jwtExpiry)
+	apiKeys, err := auth.NewAPIKeyStore(os.Getenv("GRPC_API_KEYS"))
+	if err != nil {
+		log.Fatal("invalid GRPC_API_KEYS: ", err)
+	}
+	dedup := usecase.NewDeduplicator(dedupCacheSize)
+	validator, err := usecase.NewValidator(validationCfg, sensorTypeUC)
+	if err != nil {
+		log.Fatal("invalid validation config: ", err)
+	}
 
-	// --- Start gRPC Server ---
+	// --- Write-ahead log ---
+	var walLog domain.WriteAheadLog
+	if envBool("WAL_ENABLED", true) {
+		l, err := wal.Open(walDir, int64(envInt("WAL_SEGMENT_BYTES", 64<<20)))
+		if err != nil {
+			log.Fatal("failed to open WAL: ", err)
+		}
+		walLog = l
+	}
+	// data yang ter-commit dibagikan ke subscriber gRPC dan live feed HTTP
+	slowPolicy := usecase.SlowConsumerPolicy(os.Getenv("SUBSCRIBE_SLOW_POLICY"))
+	if slowPolicy == "" {
+		slowPolicy = usecase.SlowConsumerDrop
+	}
+	hub, err := usecase.NewSensorHub(envInt("SUBSCRIBE_BUFFER", 256), envInt("SUBSCRIBE_HISTORY", 10000), slowPolicy)
+	if err != nil {
+		log.Fatal("invalid SUBSCRIBE_SLOW_POLICY: ", err)
+	}
+	ingestPipeline := usecase.NewIngestPipeline(sensorRepo, dedup, validator, deadLetterRepo, walLog, hub, ingestCfg)
+	deadLetterUC := usecase.NewDeadLetterUsecase(deadLetterRepo, ingestPipeline)
+	// retention per sensor type / id1, RETENTION_INTERVAL=0 untuk hanya manual
+	retentionUC := usecase.NewRetentionUsecase(retentionRepo, sensorRepo, usecase.RetentionConfig{
+		Interval:   envDuration("RETENTION_INTERVAL", time.Hour),
+		ChunkSize:  envInt("RETENTION_CHUNK_SIZE", 1000),
+		ChunkPause: envDuration("RETENTION_CHUNK_PAUSE", 100*time.Millisecond),
+		History:    envInt("RETENTION_HISTORY", 20),
+	})
+
+	// --- MQTT (opsional) ---
+	var mqttSub *mqttInfra.Subscriber
+	if broker := os.Getenv("MQTT_BROKER"); broker != "" {
+		clientID := os.Getenv("MQTT_CLIENT_ID")
+		if clientID == "" {
+			clientID = "microb"
+		}
+		topic := os.Getenv("MQTT_TOPIC")
+		if topic == "" {
+			topic = "sensors/{id1}/{id2}/{type}"
+		}
+		mqttSub, err = mqttInfra.NewSubscriber(mqttInfra.Config{
+			BrokerURL:    broker,
+			ClientID:     clientID,
+			Username:     os.Getenv("MQTT_USERNAME"),
+			Password:     os.Getenv("MQTT_PASSWORD"),
+			Topic:        topic,
+			QoS:          byte(envInt("MQTT_QOS", 1)),
+			CleanSession: envBool("MQTT_CLEAN_SESSION", false),
+		}, ingestPipeline)
+		if err != nil {
+			log.Fatal("invalid MQTT config: ", err)
+		}
+	}
+
+	// --- Readiness: tunggu MySQL, migrasi + seed, lalu replay WAL ---
 	go func() {
-		lis, err := net.Listen("tcp", ":"+grpcPort)
+		initDatabase(dsn, sensorTypeUC, partitions, readiness, envDuration("DB_RETRY_INTERVAL", 2*time.Second))
+		// admin pertama; /register hanya membuat role user
+		if username := os.Getenv("ADMIN_USERNAME"); username != "" {
+			if err := userUC.EnsureAdmin(username, os.Getenv("ADMIN_PASSWORD")); err != nil {
+				log.Printf("failed to create admin user: %v", err)
+			}
+		}
+		// record yang diterima tapi belum ter-commit sebelum proses mati
+		n, err := ingestPipeline.ReplayWAL(ctx)
 		if err != nil {
-			log.Fatalf("failed to listen on gRPC port %s: %v", grpcPort, err)
+			log.Printf("WAL replay incomplete, remaining segments kept for next start: %v", err)
+		}
+		if n > 0 {
+			log.Printf("Replayed %d records from WAL", n)
+		}
+		readiness.Set(true, "")
+		if mqttSub != nil {
+			mqttSub.Start()
+		}
+		if partitions != nil {
+			go partitions.Run(ctx)
+		}
+		go retentionUC.Run(ctx)
+		if rollups != nil {
+			go rollups.Run(ctx)
 		}
-		grpcServer := grpc.NewServer()
-		sensorpb.RegisterSensorServiceServer(grpcServer, grpcInfra.NewSensorGRPCServer(sensorRepo))
+		// dengan WAL database mati tidak menghentikan ingest
+		readiness.WatchDB(ctx, sqlDB, envDuration("DB_HEALTH_INTERVAL", 10*time.Second), ingestPipeline.Durable())
+	}()
+
+	// --- Start gRPC Server ---
+	var grpcServing atomic.Bool
+	lis, err := net.Listen("tcp", ":"+grpcPort)
+	if err != nil {
+		log.Fatalf("failed to listen on gRPC port %s: %v", grpcPort, err)
+	}
+	var opts []grpc.ServerOption
+	// RPC ingest ditolak UNAVAILABLE selama belum siap
+	gate := grpcInfra.NewReadinessGate(readiness)
+	unary := []grpc.UnaryServerInterceptor{gate.UnaryInterceptor}
+	stream := []grpc.StreamServerInterceptor{gate.StreamInterceptor}
+	certFile := os.Getenv("GRPC_TLS_CERT")
+	if certFile != "" {
+		tlsCfg, err := grpcInfra.ServerTLSConfig(grpcInfra.TLSConfig{
+			CertFile:           certFile,
+			KeyFile:            os.Getenv("GRPC_TLS_KEY"),
+			ClientCAFile:       os.Getenv("GRPC_TLS_CLIENT_CA"),
+			ClientCertOptional: envBool("GRPC_TLS_CLIENT_CERT_OPTIONAL", false),
+		})
+		if err != nil {
+			log.Fatalf("failed to load gRPC TLS config: %v", err)
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsCfg)))
+		log.Printf("gRPC TLS enabled (client cert: %v)", tlsCfg.ClientAuth)
+	}
+	if envBool("GRPC_AUTH_ENABLED", true) {
+		// producer wajib kirim JWT (role producer/admin) atau API key
+		allowInsecureKeys := envBool("GRPC_ALLOW_INSECURE_API_KEYS", false)
+		authenticator := grpcInfra.NewAuthenticator(jwtManager, apiKeys, allowInsecureKeys)
+		unary = append(unary, authenticator.UnaryInterceptor)
+		stream = append(stream, authenticator.StreamInterceptor)
+		log.Printf("gRPC producer auth enabled (%d API keys)", apiKeys.Len())
+		// tanpa TLS API key terkirim apa adanya di jaringan
+		if apiKeys.Len() > 0 && certFile == "" {
+			if !allowInsecureKeys {
+				log.Fatal("GRPC_API_KEYS requires gRPC TLS (GRPC_TLS_CERT); set GRPC_ALLOW_INSECURE_API_KEYS=true to accept keys over plaintext")
+			}
+			log.Println("WARNING: gRPC API keys accepted over plaintext")
+		}
+	} else {
+		log.Println("WARNING: gRPC producer auth disabled, anyone can send data")
+	}
+	opts = append(opts, grpc.ChainUnaryInterceptor(unary...), grpc.ChainStreamInterceptor(stream...))
+	grpcServer := grpc.NewServer(opts...)
+	sensorServer := grpcInfra.NewSensorGRPCServer(ingestPipeline, hub)
+	sensorpb.RegisterSensorServiceServer(grpcServer, sensorServer)
+	healthpb.RegisterHealthServer(grpcServer, grpcInfra.NewHealthServer(readiness))
+	if envBool("GRPC_REFLECTION", true) {
+		reflection.Register(grpcServer)
+	}
+	go func() {
 		log.Println("Microservice B gRPC server running at :" + grpcPort)
+		grpcServing.Store(true)
+		defer grpcServing.Store(false)
 		if err := grpcServer.Serve(lis); err != nil {
 			log.Fatalf("failed to serve gRPC: %v", err)
 		}
@@ -100,21 +338,251 @@ func main() {
 
 	// --- Start HTTP Server (Echo) ---
 	e := echo.New()
-	e.Use(echomw.Logger())
+	e.Use(echomw.LoggerWithConfig(echomw.LoggerConfig{
+		// probe healthcheck tiap beberapa detik tidak perlu di-log
+		Skipper: func(c echo.Context) bool {
+			return c.Path() == "/healthz" || c.Path() == "/readyz" ||
+				// koneksi live feed panjang dan URI-nya bisa membawa access_token
+				c.Path() == "/api/sensors/stream"
+		},
+	}))
 	e.Use(echomw.Recover())
 
 	e.GET("/swagger/*", echoSwagger.WrapHandler)
 
+	// Health probes
+	healthChecks := map[string]probe.Check{
+		"database": func(ctx context.Context) error {
+			// migrasi/seed belum selesai
+			if ready, reason := readiness.Ready(); !ready {
+				return errors.New(reason)
+			}
+			if err := sqlDB.PingContext(ctx); err != nil {
+				if ingestPipeline.Durable() {
+					return fmt.Errorf("%w: %v (buffering in WAL)", probe.ErrDegraded, err)
+				}
+				return err
+			}
+			return nil
+		},
+		"grpc": func(ctx context.Context) error {
+			if !grpcServing.Load() {
+				return errors.New("gRPC listener not serving")
+			}
+			return nil
+		},
+		"ingest": func(ctx context.Context) error {
+			stats := ingestPipeline.Stats()
+			err := stats.Healthy()
+			// write gagal tapi data aman di WAL dan antrian masih ada tempat
+			if err != nil && ingestPipeline.Durable() && stats.QueueDepth < stats.QueueCapacity {
+				return fmt.Errorf("%w: %v", probe.ErrDegraded, err)
+			}
+			return err
+		},
+	}
+	if mqttSub != nil {
+		healthChecks["mqtt"] = func(ctx context.Context) error {
+			if !mqttSub.Connected() {
+				return errors.New("not connected to MQTT broker")
+			}
+			return nil
+		}
+	}
+	probe.Register(e, healthChecks)
+
 	// Public routes
 	http.NewUserHandler(e, userUC, jwtManager)
 
 	// Protected routes
 	api := e.Group("/api")
 	api.Use(middleware.JWTAuth(jwtManager, "admin", "user"))
-	http.NewSensorHandler(api, sensorUC)
+	http.NewSensorHandler(api, sensorUC, hub, http.StreamConfig{
+		Heartbeat:      envDuration("STREAM_HEARTBEAT", 15*time.Second),
+		AllowedOrigins: envList("STREAM_ALLOWED_ORIGINS"),
+	})
+	http.NewIngestHandler(api, ingestPipeline)
+
+	// Producer routes: kirim data lewat HTTP, role sama dengan ingest gRPC.
+	// Middleware dipasang per route, group /api di atas hanya untuk admin/user.
+	producerOnly := middleware.JWTAuth(jwtManager, "producer", "admin")
+	http.NewSensorIngestHandler(e.Group("/api"), ingestPipeline, int64(envInt("HTTP_INGEST_MAX_BYTES", 32<<20)), producerOnly)
+
+	// Admin-only routes
+	adminOnly := middleware.JWTAuth(jwtManager, "admin")
+	http.NewSensorTypeHandler(api, sensorTypeUC, adminOnly)
+	http.NewDeadLetterHandler(api, deadLetterUC, adminOnly)
+	http.NewRetentionHandler(api, retentionUC, adminOnly)
+	http.NewUserAdminHandler(api, userUC, adminOnly)
+
+	go func() {
+		log.Println("Microservice B HTTP server running at :" + httpPort)
+		if err := e.Start(":" + httpPort); err != nil && !errors.Is(err, nethttp.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	log.Printf("Shutting down (timeout %v)...", shutdownTimeout)
+	// satu deadline untuk semua tahap supaya total shutdown tetap di bawah
+	// stop_grace_period, bukan SHUTDOWN_TIMEOUT per tahap
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	// 1. tandai tidak siap: health gRPC jadi NOT_SERVING dan stream baru ditolak
+	readiness.Shutdown()
+
+	// 2. stream yang masih jalan berhenti membaca, menunggu ack pesan yang sudah
+	// diterima lalu ditutup UNAVAILABLE; producer mengirim ulang sisanya nanti
+	sensorServer.Drain()
+	if !waitShutdown(shutdownCtx, "gRPC graceful stop", grpcServer.GracefulStop) {
+		grpcServer.Stop()
+	}
+
+	// 3. live feed SSE/WebSocket tidak pernah selesai sendiri, putus dulu
+	// supaya Shutdown tidak menunggu sampai timeout
+	hub.Close()
+
+	// 4. HTTP dimatikan sebelum pipeline supaya request ingest yang sedang
+	// jalan masih bisa masuk antrian
+	if err := e.Shutdown(shutdownCtx); err != nil {
+		log.Printf("HTTP shutdown: %v", err)
+	}
+
+	// 5. MQTT berhenti dulu supaya tidak ada pesan baru selama flush; pesan
+	// yang belum di-ack dikirim ulang broker setelah restart
+	if mqttSub != nil {
+		waitShutdown(shutdownCtx, "MQTT close", mqttSub.Close)
+	}
+
+	// 6. flush terakhir semua buffer ingest ke database. Kalau deadline lewat,
+	// WAL dibiarkan terbuka: isinya di-replay saat start berikutnya
+	if waitShutdown(shutdownCtx, "ingest flush", ingestPipeline.Close) {
+		if l, ok := walLog.(*wal.Log); ok {
+			if err := l.Close(); err != nil {
+				log.Printf("close WAL: %v", err)
+			}
+		}
+	}
+	if err := sqlDB.Close(); err != nil {
+		log.Printf("close database: %v", err)
+	}
+	log.Printf("Shutdown complete, ingest stats: %+v", ingestPipeline.Stats())
+}
+
+// waitShutdown menjalankan fn dan menunggunya sampai ctx habis; false kalau
+// fn belum selesai saat deadline lewat
+func waitShutdown(ctx context.Context, name string, fn func()) bool {
+	done := make(chan struct{})
+	go func() {
+		fn()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		log.Printf("%s timed out", name)
+		return false
+	}
+}
+
+// runHealthcheck dipakai healthcheck docker: cek /readyz proses yang sedang
+// jalan di container yang sama
+func runHealthcheck(port string) int {
+	client := nethttp.Client{Timeout: 3 * time.Second}
+	resp, err := client.Get("http://127.0.0.1:" + port + "/readyz")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "healthcheck:", err)
+		return 1
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != nethttp.StatusOK {
+		fmt.Fprintf(os.Stderr, "healthcheck: %s %s\n", resp.Status, body)
+		return 1
+	}
+	return 0
+}
+
+// initDatabase menunggu MySQL bisa dihubungi lalu menjalankan migrasi,
+// seed sensor type dan partisi; selama itu readiness tetap tidak siap
+func initDatabase(dsn string, sensorTypeUC usecase.SensorTypeUsecase, partitions *mysqlRepo.PartitionManager, readiness *health.Readiness, retry time.Duration) {
+	for {
+		err := migrate(dsn, sensorTypeUC, partitions)
+		if err == nil {
+			return
+		}
+		readiness.Set(false, err.Error())
+		log.Printf("Database not ready, retrying in %v: %v", retry, err)
+		time.Sleep(retry)
+	}
+}
+
+// migrate memakai koneksi gorm sendiri yang ditutup setelah selesai; query
+// aplikasi tetap lewat sqlDB
+func migrate(dsn string, sensorTypeUC usecase.SensorTypeUsecase, partitions *mysqlRepo.PartitionManager) error {
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	if err != nil {
+		return fmt.Errorf("connect db: %w", err)
+	}
+	if conn, err := db.DB(); err == nil {
+		defer conn.Close()
+	}
+	if err := db.AutoMigrate(&domain.User{}, &domain.SensorData{}, &domain.SensorType{}, &domain.RetentionPolicy{}); err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+	// satu struct untuk semua tabel rollup, tetap dibuat walau rollup mati
+	for _, l := range domain.RollupLevels {
+		if err := db.Table(mysqlRepo.RollupTable(l)).AutoMigrate(&domain.SensorRollup{}); err != nil {
+			return fmt.Errorf("migrate %s: %w", mysqlRepo.RollupTable(l), err)
+		}
+	}
+	// sensor type bawaan hanya ditambahkan kalau belum ada, perubahan admin tidak ditimpa
+	if err := sensorTypeUC.Seed(usecase.DefaultSensorTypes()); err != nil {
+		return fmt.Errorf("load sensor types: %w", err)
+	}
+	// tabel dipartisi sebelum ingest dimulai; di tabel lama yang besar bisa lama
+	if partitions != nil {
+		if err := partitions.Ensure(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// envInt membaca env integer, pakai def kalau kosong atau tidak valid
+func envInt(key string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return v
+	}
+	return def
+}
+
+// envDuration membaca env durasi (contoh "5s", "500ms"), pakai def kalau kosong atau tidak valid
+func envDuration(key string, def time.Duration) time.Duration {
+	if v, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return v
+	}
+	return def
+}
+
+// envBool membaca env boolean ("true", "false", "1", "0"), pakai def kalau kosong atau tidak valid
+// envList nilai dipisah koma, kosong kalau tidak diset
+func envList(key string) []string {
+	var list []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
 
-	log.Println("Microservice B HTTP server running at :" + httpPort)
-	if err := e.Start(":" + httpPort); err != nil {
-		log.Fatal(err)
+func envBool(key string, def bool) bool {
+	if v, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return v
 	}
+	return def
 }
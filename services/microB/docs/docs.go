@@ -23,42 +23,54 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/login": {
-            "post": {
-                "description": "Authenticate user with username and password",
-                "consumes": [
-                    "application/json"
-                ],
+        "/deadletters": {
+            "get": {
+                "description": "List batches that failed to persist after all retries (without records)",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "auth"
+                    "deadletters"
                 ],
-                "summary": "Login user",
-                "parameters": [
-                    {
-                        "description": "Login Request",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
+                "summary": "List dead-lettered batches",
+                "responses": {
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/dto.LoginRequest"
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
                         }
                     }
+                }
+            },
+            "delete": {
+                "description": "Permanently delete every dead-lettered batch",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "deadletters"
                 ],
+                "summary": "Purge all dead-lettered batches",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
                             "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "additionalProperties": true
                         }
                     },
-                    "401": {
-                        "description": "Unauthorized",
+                    "500": {
+                        "description": "Internal Server Error",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -69,42 +81,34 @@ const docTemplate = `{
                 }
             }
         },
-        "/register": {
-            "post": {
-                "description": "Create a new user account",
-                "consumes": [
-                    "application/json"
-                ],
+        "/deadletters/{id}": {
+            "get": {
+                "description": "Get a dead-lettered batch including its records",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "auth"
+                    "deadletters"
                 ],
-                "summary": "Register new user",
+                "summary": "Inspect a dead-lettered batch",
                 "parameters": [
                     {
-                        "description": "Register Request",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/dto.RegisterRequest"
-                        }
+                        "type": "string",
+                        "description": "Dead letter ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/domain.DeadLetter"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -122,59 +126,23 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/sensors": {
-            "get": {
-                "description": "Retrieve sensor data based on various filters",
-                "consumes": [
-                    "application/json"
-                ],
+            },
+            "delete": {
+                "description": "Permanently delete a dead-lettered batch without storing it",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "sensors"
+                    "deadletters"
                 ],
-                "summary": "Get sensor data by filter",
+                "summary": "Purge a dead-lettered batch",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "ID1 filter",
-                        "name": "id1",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "description": "ID2 filter",
-                        "name": "id2",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Start timestamp (RFC3339)",
-                        "name": "from",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "End timestamp (RFC3339)",
-                        "name": "to",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "default": 10,
-                        "description": "Limit number of results",
-                        "name": "limit",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "default": 0,
-                        "description": "Offset for pagination",
-                        "name": "offset",
-                        "in": "query"
+                        "description": "Dead letter ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
@@ -185,8 +153,8 @@ const docTemplate = `{
                             "additionalProperties": true
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -204,49 +172,24 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "put": {
-                "description": "Update sensor data values based on various filters",
-                "consumes": [
-                    "application/json"
-                ],
+            }
+        },
+        "/deadletters/{id}/replay": {
+            "post": {
+                "description": "Store the batch again through the ingest pipeline (validation, dedup, live feed); on success the dead letter is removed and records rejected by validation are listed",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "sensors"
+                    "deadletters"
                 ],
-                "summary": "Update sensor data by filter",
+                "summary": "Replay a dead-lettered batch",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "ID1 filter",
-                        "name": "id1",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "description": "ID2 filter",
-                        "name": "id2",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Start timestamp (RFC3339)",
-                        "name": "from",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "End timestamp (RFC3339)",
-                        "name": "to",
-                        "in": "query"
-                    },
-                    {
-                        "type": "number",
-                        "description": "New sensor value",
-                        "name": "new_value",
-                        "in": "query",
+                        "description": "Dead letter ID",
+                        "name": "id",
+                        "in": "path",
                         "required": true
                     }
                 ],
@@ -254,12 +197,11 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": true
+                            "$ref": "#/definitions/usecase.ReplayResult"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -277,9 +219,54 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "delete": {
-                "description": "Delete sensor data based on various filters",
+            }
+        },
+        "/healthz": {
+            "get": {
+                "description": "Returns 200 while the process is running",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Liveness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/ingest/stats": {
+            "get": {
+                "description": "Queue depth, batch counters and write latency of the shared ingest pipeline",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "ingest"
+                ],
+                "summary": "Get ingest pipeline stats",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/usecase.IngestStats"
+                        }
+                    }
+                }
+            }
+        },
+        "/login": {
+            "post": {
+                "description": "Authenticate user with username and password",
                 "consumes": [
                     "application/json"
                 ],
@@ -287,45 +274,23 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "sensors"
+                    "auth"
                 ],
-                "summary": "Delete sensor data by filter",
+                "summary": "Login user",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "ID1 filter",
-                        "name": "id1",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "description": "ID2 filter",
-                        "name": "id2",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Start timestamp (RFC3339)",
-                        "name": "from",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "End timestamp (RFC3339)",
-                        "name": "to",
-                        "in": "query"
+                        "description": "Login Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.LoginRequest"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
-                        "schema": {
-                            "type": "object",
-                            "additionalProperties": true
-                        }
-                    },
-                    "400": {
-                        "description": "Bad Request",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -333,8 +298,8 @@ const docTemplate = `{
                             }
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -344,16 +309,1376 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "dto.LoginRequest": {
-            "type": "object",
-            "properties": {
-                "password": {
-                    "type": "string",
-                    "example": "password123"
-                },
+        },
+        "/readyz": {
+            "get": {
+                "description": "Checks every dependency; 503 if any dependency fails, 200 with status degraded if a dependency is down but ingest still works",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Readiness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/probe.Report"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/probe.Report"
+                        }
+                    }
+                }
+            }
+        },
+        "/register": {
+            "post": {
+                "description": "Create a new account with role user. Producer and admin accounts are created by an admin via POST /api/users.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Register new user",
+                "parameters": [
+                    {
+                        "description": "Register Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.RegisterRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/retention/policies": {
+            "get": {
+                "description": "List data retention policies (admin only)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "retention"
+                ],
+                "summary": "List retention policies",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Delete readings of a sensor type and/or id1 pattern once they are older than max_age (admin only).\nWhen a reading matches several policies the longest max_age applies.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "retention"
+                ],
+                "summary": "Create a retention policy",
+                "parameters": [
+                    {
+                        "description": "Retention policy",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.RetentionPolicyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/domain.RetentionPolicy"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/retention/policies/{id}": {
+            "get": {
+                "description": "Get one data retention policy (admin only)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "retention"
+                ],
+                "summary": "Get a retention policy",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Policy ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.RetentionPolicy"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Replace scope, max age and enabled flag of a retention policy (admin only)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "retention"
+                ],
+                "summary": "Update a retention policy",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Policy ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Retention policy",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.RetentionPolicyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.RetentionPolicy"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Remove a retention policy; matching readings are kept from now on (admin only)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "retention"
+                ],
+                "summary": "Delete a retention policy",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Policy ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/retention/run": {
+            "post": {
+                "description": "Apply all enabled retention policies once and report what was purged per policy (admin only).\nWith dry_run=true nothing is deleted and the report shows how many readings would be.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "retention"
+                ],
+                "summary": "Run retention now",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Only count matching readings",
+                        "name": "dry_run",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/usecase.RetentionRun"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/retention/runs": {
+            "get": {
+                "description": "Reports of the most recent retention runs, newest first (admin only)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "retention"
+                ],
+                "summary": "List recent retention runs",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/sensor-types": {
+            "get": {
+                "description": "List the sensor type catalog with units and valid ranges",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "sensor-types"
+                ],
+                "summary": "List sensor types",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Add a sensor type to the catalog (admin only)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "sensor-types"
+                ],
+                "summary": "Create a sensor type",
+                "parameters": [
+                    {
+                        "description": "Sensor type",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.SensorTypeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/domain.SensorType"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/sensor-types/{name}": {
+            "get": {
+                "description": "Get one sensor type from the catalog",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "sensor-types"
+                ],
+                "summary": "Get a sensor type",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Sensor type name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.SensorType"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Replace unit, description, range and precision of a sensor type (admin only)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "sensor-types"
+                ],
+                "summary": "Update a sensor type",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Sensor type name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Sensor type (name is taken from the path)",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.SensorTypeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.SensorType"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Remove a sensor type from the catalog; new readings of this type will be rejected (admin only)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "sensor-types"
+                ],
+                "summary": "Delete a sensor type",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Sensor type name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/sensors": {
+            "get": {
+                "description": "Retrieve sensor data based on various filters",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "sensors"
+                ],
+                "summary": "Get sensor data by filter",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID1 filter",
+                        "name": "id1",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "ID2 filter",
+                        "name": "id2",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start timestamp (RFC3339)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End timestamp (RFC3339)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Limit number of results",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Offset for pagination",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Update sensor data values based on various filters",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "sensors"
+                ],
+                "summary": "Update sensor data by filter",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID1 filter",
+                        "name": "id1",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "ID2 filter",
+                        "name": "id2",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start timestamp (RFC3339)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End timestamp (RFC3339)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "New sensor value",
+                        "name": "new_value",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Store readings sent over HTTP. The body is a single JSON reading, a JSON array, NDJSON (` + "`" + `application/x-ndjson` + "`" + `) or CSV (` + "`" + `text/csv` + "`" + `, header row with sensor_value, sensor_type, id1, id2, timestamp and optional producer_id, seq). Each reading is validated like gRPC ingest; invalid ones are reported per line and do not stop the rest. Readings with producer_id and seq are deduplicated, so a failed request can safely be resent. Also available as POST /sensors/batch.",
+                "consumes": [
+                    "application/json",
+                    "application/x-ndjson",
+                    "text/csv"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "sensors"
+                ],
+                "summary": "Ingest sensor readings",
+                "parameters": [
+                    {
+                        "description": "Reading, array of readings, NDJSON or CSV",
+                        "name": "readings",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.SensorReading"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.IngestSummary"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.IngestSummary"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/dto.IngestSummary"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/dto.IngestSummary"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete sensor data based on various filters",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "sensors"
+                ],
+                "summary": "Delete sensor data by filter",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID1 filter",
+                        "name": "id1",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "ID2 filter",
+                        "name": "id2",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start timestamp (RFC3339)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End timestamp (RFC3339)",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/sensors/aggregate": {
+            "get": {
+                "description": "One row per series (id1, id2, sensor type) per bucket that has data, with the requested functions.\nBuckets start at multiples of the interval since the Unix epoch in UTC (1d buckets at 00:00 UTC, 7d buckets on Thursdays; no time zone parameter).\nfrom is rounded down and to is rounded up to a bucket boundary, so the first and last buckets are always complete; the response carries the rounded range.\nServed from the 1m/1h/1d rollup tables when the interval is a multiple of a rollup level still within its retention, otherwise from raw data.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "sensors"
+                ],
+                "summary": "Aggregate sensor data per time bucket",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID1 filter",
+                        "name": "id1",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "ID2 filter",
+                        "name": "id2",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sensor types, comma separated",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start timestamp, inclusive (RFC3339); default 24h before to",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End timestamp, exclusive (RFC3339); default now",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "1h",
+                        "description": "Bucket size, e.g. 1m, 15m, 1h, 1d",
+                        "name": "interval",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "avg",
+                        "description": "Functions, comma separated: avg, min, max, sum, count, first, last, stddev",
+                        "name": "fns",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/sensors/stream": {
+            "get": {
+                "description": "Push every newly stored reading matching the filters. Uses Server-Sent Events, or WebSocket when the request is a WebSocket upgrade (one JSON message per event). Resume after a reconnect with the Last-Event-ID header or the last_event_id query param. Browsers that cannot set the Authorization header may pass the JWT as the access_token query param.",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "sensors"
+                ],
+                "summary": "Live sensor feed",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID1 filter",
+                        "name": "id1",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "ID2 filter",
+                        "name": "id2",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sensor type filter, comma separated",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Resume after this event ID",
+                        "name": "last_event_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "event stream",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/users": {
+            "post": {
+                "description": "Create an account with any role (user, producer or admin); admin only",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Create user",
+                "parameters": [
+                    {
+                        "description": "Create User Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.CreateUserRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "domain.DeadLetter": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer"
+                },
+                "count": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "failed_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "records": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.SensorData"
+                    }
+                }
+            }
+        },
+        "domain.RetentionPolicy": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "enabled": {
+                    "type": "boolean"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "id1_pattern": {
+                    "description": "ID1Pattern glob, \"*\" untuk sembarang karakter (contoh \"LAB-*\")",
+                    "type": "string"
+                },
+                "max_age_seconds": {
+                    "type": "integer"
+                },
+                "sensor_type": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "domain.SensorData": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "description": "ts ikut di primary key dan idx_producer_seq karena tabel dipartisi per\nts (lihat mysql.PartitionManager); MySQL meminta kolom partisi ada di\nsemua unique key",
+                    "type": "integer"
+                },
+                "id1": {
+                    "type": "string"
+                },
+                "id2": {
+                    "type": "integer"
+                },
+                "ingestedBy": {
+                    "description": "identitas kredensial yang mengirim data",
+                    "type": "string"
+                },
+                "producerID": {
+                    "type": "string"
+                },
+                "sensorType": {
+                    "type": "string"
+                },
+                "sensorValue": {
+                    "type": "number"
+                },
+                "seq": {
+                    "type": "integer"
+                },
+                "ts": {
+                    "type": "string"
+                },
+                "unit": {
+                    "description": "Unit diambil dari katalog sensor_types saat query, tidak disimpan",
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "domain.SensorType": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "max_value": {
+                    "type": "number"
+                },
+                "min_value": {
+                    "description": "nilai di luar min/max ditolak saat ingest",
+                    "type": "number"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "precision": {
+                    "description": "jumlah desimal untuk tampilan",
+                    "type": "integer"
+                },
+                "unit": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.CreateUserRequest": {
+            "type": "object",
+            "properties": {
+                "password": {
+                    "type": "string",
+                    "example": "newpassword"
+                },
+                "role": {
+                    "type": "string",
+                    "enum": [
+                        "user",
+                        "producer",
+                        "admin"
+                    ],
+                    "example": "producer"
+                },
+                "username": {
+                    "type": "string",
+                    "example": "gateway-1"
+                }
+            }
+        },
+        "dto.IngestResult": {
+            "type": "object",
+            "properties": {
+                "line": {
+                    "type": "integer",
+                    "example": 3
+                },
+                "message": {
+                    "type": "string",
+                    "example": "sensor_value 120 out of range"
+                },
+                "status": {
+                    "description": "stored, duplicate, rejected atau failed",
+                    "type": "string",
+                    "example": "rejected"
+                }
+            }
+        },
+        "dto.IngestSummary": {
+            "type": "object",
+            "properties": {
+                "duplicates": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "rejected": {
+                    "type": "integer"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.IngestResult"
+                    }
+                },
+                "stored": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dto.LoginRequest": {
+            "type": "object",
+            "properties": {
+                "password": {
+                    "type": "string",
+                    "example": "password123"
+                },
                 "username": {
                     "type": "string",
                     "example": "admin"
@@ -367,15 +1692,271 @@ const docTemplate = `{
                     "type": "string",
                     "example": "newpassword"
                 },
-                "role": {
-                    "type": "string",
-                    "example": "viewer"
-                },
                 "username": {
                     "type": "string",
                     "example": "newuser"
                 }
             }
+        },
+        "dto.RetentionPolicyRequest": {
+            "type": "object",
+            "properties": {
+                "enabled": {
+                    "description": "Enabled default true",
+                    "type": "boolean",
+                    "example": true
+                },
+                "id1_pattern": {
+                    "type": "string",
+                    "example": "LAB-*"
+                },
+                "max_age": {
+                    "type": "string",
+                    "example": "30d"
+                },
+                "sensor_type": {
+                    "type": "string",
+                    "example": "temperature"
+                }
+            }
+        },
+        "dto.SensorReading": {
+            "type": "object",
+            "properties": {
+                "id1": {
+                    "type": "string",
+                    "example": "A"
+                },
+                "id2": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "producer_id": {
+                    "description": "ProducerID dan Seq opsional, dipakai untuk dedup kalau data dikirim ulang",
+                    "type": "string",
+                    "example": "sensor-gw-1"
+                },
+                "sensor_type": {
+                    "type": "string",
+                    "example": "temperature"
+                },
+                "sensor_value": {
+                    "type": "number",
+                    "example": 21.5
+                },
+                "seq": {
+                    "type": "integer",
+                    "example": 42
+                },
+                "timestamp": {
+                    "type": "string",
+                    "example": "2025-01-01T10:00:00Z"
+                }
+            }
+        },
+        "dto.SensorTypeRequest": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string",
+                    "example": "Barometric pressure"
+                },
+                "max_value": {
+                    "type": "number",
+                    "example": 1100
+                },
+                "min_value": {
+                    "type": "number",
+                    "example": 800
+                },
+                "name": {
+                    "type": "string",
+                    "example": "pressure"
+                },
+                "precision": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "unit": {
+                    "type": "string",
+                    "example": "hPa"
+                }
+            }
+        },
+        "probe.DependencyStatus": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "latency_ms": {
+                    "type": "number"
+                },
+                "status": {
+                    "description": "\"ok\", \"degraded\" atau \"fail\"",
+                    "type": "string"
+                }
+            }
+        },
+        "probe.Report": {
+            "type": "object",
+            "properties": {
+                "checks": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/probe.DependencyStatus"
+                    }
+                },
+                "status": {
+                    "description": "\"ok\", \"degraded\" atau \"unavailable\"",
+                    "type": "string"
+                }
+            }
+        },
+        "usecase.IngestStats": {
+            "type": "object",
+            "properties": {
+                "avg_write_ms": {
+                    "type": "number"
+                },
+                "batches": {
+                    "type": "integer"
+                },
+                "dead_lettered": {
+                    "type": "integer"
+                },
+                "duplicates": {
+                    "type": "integer"
+                },
+                "failed_batches": {
+                    "type": "integer"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "last_write_ms": {
+                    "type": "number"
+                },
+                "max_write_ms": {
+                    "type": "number"
+                },
+                "queue_capacity": {
+                    "type": "integer"
+                },
+                "queue_depth": {
+                    "type": "integer"
+                },
+                "records": {
+                    "type": "integer"
+                },
+                "rejected": {
+                    "description": "per alasan penolakan validator",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer",
+                        "format": "int64"
+                    }
+                },
+                "wal_pending": {
+                    "type": "integer"
+                },
+                "workers": {
+                    "type": "integer"
+                }
+            }
+        },
+        "usecase.ReplayReject": {
+            "type": "object",
+            "properties": {
+                "index": {
+                    "description": "posisi di Records dead letter",
+                    "type": "integer"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "seq": {
+                    "type": "integer"
+                }
+            }
+        },
+        "usecase.ReplayResult": {
+            "type": "object",
+            "properties": {
+                "duplicates": {
+                    "type": "integer"
+                },
+                "inserted": {
+                    "type": "integer"
+                },
+                "rejected": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/usecase.ReplayReject"
+                    }
+                }
+            }
+        },
+        "usecase.RetentionPolicyResult": {
+            "type": "object",
+            "properties": {
+                "cutoff": {
+                    "description": "data sebelum ini dihapus",
+                    "type": "string"
+                },
+                "deleted": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "id1_pattern": {
+                    "type": "string"
+                },
+                "oldest": {
+                    "description": "hanya untuk dry run",
+                    "type": "string"
+                },
+                "policy_id": {
+                    "type": "integer"
+                },
+                "sensor_type": {
+                    "type": "string"
+                }
+            }
+        },
+        "usecase.RetentionRun": {
+            "type": "object",
+            "properties": {
+                "deleted": {
+                    "type": "integer"
+                },
+                "dry_run": {
+                    "type": "boolean"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "finished_at": {
+                    "type": "string"
+                },
+                "policies": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/usecase.RetentionPolicyResult"
+                    }
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "trigger": {
+                    "description": "\"schedule\" atau \"manual\"",
+                    "type": "string"
+                }
+            }
         }
     }
 }`
@@ -0,0 +1,18 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// DeadLetter batch yang tetap gagal disimpan setelah semua retry habis
+type DeadLetter struct {
+	ID       string        `json:"id"`
+	Error    string        `json:"error"`
+	Attempts int           `json:"attempts"`
+	FailedAt time.Time     `json:"failed_at"`
+	Count    int           `json:"count"`
+	Records  []*SensorData `json:"records,omitempty"`
+}
@@ -5,10 +5,56 @@ import "time"
 // Repository untuk SensorData
 type SensorRepository interface {
 	Store(sensor *SensorData) error
-	StoreBatch(sensors []*SensorData) error
+	StoreBatch(sensors []*SensorData) (BatchResult, error)
 	FindByFilter(id1 string, id2 *int, from, to *time.Time, limit, offset int) ([]*SensorData, int, error)
 	UpdateByFilter(id1 string, id2 *int, from, to *time.Time, newValue float64) (int64, error)
 	DeleteByFilter(id1 string, id2 *int, from, to *time.Time) (int64, error)
+	// CountExpired jumlah data yang cocok dengan f dan ts paling lama di antaranya
+	CountExpired(f RetentionFilter) (count int64, oldest *time.Time, err error)
+	// DeleteExpired menghapus maksimal limit data yang cocok dengan f
+	DeleteExpired(f RetentionFilter, limit int) (int64, error)
+	// Aggregate satu SensorRollup per series per bucket (Bucket = awal
+	// interval), dari tabel rollup kalau bisa; source "raw" atau level rollup
+	Aggregate(q AggregateQuery) (rows []*SensorRollup, source string, err error)
+}
+
+// Repository untuk batch yang gagal disimpan (dead letter)
+type DeadLetterRepository interface {
+	Save(dl *DeadLetter) error
+	List() ([]*DeadLetter, error) // tanpa Records
+	Get(id string) (*DeadLetter, error)
+	Delete(id string) error
+	DeleteAll() (int, error)
+}
+
+// WriteAheadLog log lokal untuk record yang sudah diterima tapi belum
+// ter-commit ke database
+type WriteAheadLog interface {
+	// Append kembali setelah record tersimpan di disk; hasilnya dipakai untuk Release
+	Append(sensor *SensorData) (uint64, error)
+	Release(segment uint64)
+	Pending() int
+	// Replay meneruskan record sisa proses sebelumnya beserta segment-nya;
+	// record tidak ditulis ulang, cukup di-Release setelah ter-commit
+	Replay(fn func(sensor *SensorData, segment uint64) error) (int, error)
+}
+
+// Repository untuk katalog SensorType
+type SensorTypeRepository interface {
+	Create(st *SensorType) error
+	Update(st *SensorType) error
+	Delete(name string) error
+	FindByName(name string) (*SensorType, error)
+	FindAll() ([]*SensorType, error)
+}
+
+// Repository untuk RetentionPolicy
+type RetentionPolicyRepository interface {
+	Create(p *RetentionPolicy) error
+	Update(p *RetentionPolicy) error
+	Delete(id uint64) error
+	FindByID(id uint64) (*RetentionPolicy, error)
+	FindAll() ([]*RetentionPolicy, error)
 }
 
 // Repository untuk User
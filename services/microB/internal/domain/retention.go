@@ -0,0 +1,51 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrRetentionPolicyNotFound = errors.New("retention policy not found")
+	ErrRetentionPolicyExists   = errors.New("retention policy for this sensor type and id1 pattern already exists")
+)
+
+// RetentionPolicy data yang cocok lebih tua dari MaxAgeSeconds dihapus job
+// retention. SensorType dan ID1Pattern kosong artinya cocok dengan semua.
+// Kalau satu data cocok dengan beberapa policy, yang umurnya paling panjang
+// yang berlaku, jadi policy umum yang pendek tidak menghapus data yang
+// masih disimpan policy lain.
+type RetentionPolicy struct {
+	ID         uint64 `gorm:"primaryKey;autoIncrement" json:"id"`
+	SensorType string `gorm:"type:varchar(64);not null;default:'';uniqueIndex:idx_retention_scope,priority:1" json:"sensor_type"`
+	// ID1Pattern glob, "*" untuk sembarang karakter (contoh "LAB-*")
+	ID1Pattern    string    `gorm:"type:varchar(64);not null;default:'';uniqueIndex:idx_retention_scope,priority:2" json:"id1_pattern"`
+	MaxAgeSeconds int64     `gorm:"not null" json:"max_age_seconds"`
+	Enabled       bool      `gorm:"not null;default:true" json:"enabled"`
+	CreatedAt     time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (p *RetentionPolicy) MaxAge() time.Duration {
+	return time.Duration(p.MaxAgeSeconds) * time.Second
+}
+
+func (p *RetentionPolicy) Scope() RetentionScope {
+	return RetentionScope{SensorType: p.SensorType, ID1Pattern: p.ID1Pattern}
+}
+
+// RetentionScope data yang dicakup satu policy, field kosong tidak difilter
+type RetentionScope struct {
+	SensorType string
+	ID1Pattern string
+}
+
+// RetentionFilter data yang boleh dihapus: cocok dengan Scope dan ts sebelum
+// Before, kecuali yang cocok dengan salah satu Exclude. Exclude berisi scope
+// policy lain yang umurnya lebih panjang; data itu hanya diurus policy
+// tersebut, jadi satu data tidak pernah dihitung dua policy.
+type RetentionFilter struct {
+	Scope   RetentionScope
+	Before  time.Time
+	Exclude []RetentionScope
+}
@@ -0,0 +1,120 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// RollupLevel resolusi bucket rollup, bucket selalu dihitung dalam UTC
+type RollupLevel string
+
+const (
+	RollupMinute RollupLevel = "1m"
+	RollupHour   RollupLevel = "1h"
+	RollupDay    RollupLevel = "1d"
+)
+
+// RollupLevels semua level dari yang paling halus; level yang lebih kasar
+// dihitung ulang dari level sebelumnya
+var RollupLevels = []RollupLevel{RollupMinute, RollupHour, RollupDay}
+
+func ParseRollupLevel(s string) (RollupLevel, error) {
+	for _, l := range RollupLevels {
+		if string(l) == s {
+			return l, nil
+		}
+	}
+	return "", fmt.Errorf("unknown rollup level %q (1m, 1h or 1d)", s)
+}
+
+func (l RollupLevel) Duration() time.Duration {
+	switch l {
+	case RollupMinute:
+		return time.Minute
+	case RollupHour:
+		return time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// Bucket awal bucket yang berisi t
+func (l RollupLevel) Bucket(t time.Time) time.Time {
+	return t.UTC().Truncate(l.Duration())
+}
+
+// SensorRollup agregat satu series (id1, id2, sensor type) dalam satu bucket.
+// Avg dan stddev dihitung dari Sum dan SumSq saat query, jadi bucket bisa
+// digabung jadi bucket yang lebih besar tanpa kehilangan presisi.
+type SensorRollup struct {
+	ID1        string    `gorm:"type:char(20);primaryKey" json:"id1"`
+	ID2        int       `gorm:"primaryKey;autoIncrement:false" json:"id2"`
+	SensorType string    `gorm:"type:varchar(64);primaryKey" json:"sensor_type"`
+	Bucket     time.Time `gorm:"primaryKey;index" json:"bucket"`
+	Samples    int64     `gorm:"not null" json:"samples"`
+	MinValue   float64   `gorm:"not null" json:"min"`
+	MaxValue   float64   `gorm:"not null" json:"max"`
+	SumValue   float64   `gorm:"not null" json:"sum"`
+	SumSq      float64   `gorm:"not null" json:"-"`
+	FirstTS    time.Time `gorm:"precision:6;not null" json:"first_ts"`
+	FirstVal   float64   `gorm:"not null" json:"first"`
+	LastTS     time.Time `gorm:"precision:6;not null" json:"last_ts"`
+	LastVal    float64   `gorm:"not null" json:"last"`
+}
+
+func (r *SensorRollup) Avg() float64 {
+	if r.Samples == 0 {
+		return 0
+	}
+	return r.SumValue / float64(r.Samples)
+}
+
+// Add memasukkan satu data ke bucket. Data dengan ts sama: first tetap yang
+// masuk lebih dulu, last diganti yang masuk belakangan.
+func (r *SensorRollup) Add(value float64, ts time.Time) {
+	if r.Samples == 0 {
+		r.MinValue, r.MaxValue = value, value
+		r.FirstTS, r.FirstVal = ts, value
+		r.LastTS, r.LastVal = ts, value
+	}
+	r.Samples++
+	r.MinValue = min(r.MinValue, value)
+	r.MaxValue = max(r.MaxValue, value)
+	r.SumValue += value
+	r.SumSq += value * value
+	if ts.Before(r.FirstTS) {
+		r.FirstTS, r.FirstVal = ts, value
+	}
+	if !ts.Before(r.LastTS) {
+		r.LastTS, r.LastVal = ts, value
+	}
+}
+
+// AggregateQuery agregat data sensor per Interval di [From, To). Bucket
+// dihitung dari Unix epoch (UTC), jadi interval 1d mulai tengah malam UTC.
+type AggregateQuery struct {
+	ID1         string
+	ID2         *int
+	SensorTypes []string
+	From, To    time.Time
+	Interval    time.Duration
+}
+
+// Aligned From dibulatkan ke bawah dan To ke atas ke batas bucket Interval,
+// jadi bucket pertama dan terakhir selalu utuh dan tabel rollup bisa dipakai
+// untuk interval kelipatan level rollup. Interval harus kelipatan detik.
+func (q AggregateQuery) Aligned() AggregateQuery {
+	secs := int64(q.Interval / time.Second)
+	if secs <= 0 {
+		return q
+	}
+	floor := func(t time.Time) int64 {
+		s := t.Unix()
+		return s - ((s%secs)+secs)%secs
+	}
+	from, to := floor(q.From), floor(q.To)
+	if time.Unix(to, 0).Before(q.To) {
+		to += secs
+	}
+	q.From, q.To = time.Unix(from, 0).UTC(), time.Unix(to, 0).UTC()
+	return q
+}
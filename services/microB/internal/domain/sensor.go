@@ -3,12 +3,46 @@ package domain
 import "time"
 
 type SensorData struct {
+	// ts ikut di primary key dan idx_producer_seq karena tabel dipartisi per
+	// ts (lihat mysql.PartitionManager); MySQL meminta kolom partisi ada di
+	// semua unique key
 	ID          uint64     `gorm:"primaryKey;autoIncrement"`
 	SensorValue float64    `gorm:"not null"`
 	SensorType  string     `gorm:"type:varchar(64);not null;index"`
 	ID1         string     `gorm:"type:char(20);not null;index:idx_ids_ts,priority:1"`
 	ID2         int        `gorm:"not null;index:idx_ids_ts,priority:2"`
-	TS          time.Time  `gorm:"precision:6;not null;index:idx_ids_ts,priority:3"`
+	TS          time.Time  `gorm:"primaryKey;precision:6;not null;index:idx_ids_ts,priority:3;uniqueIndex:idx_producer_seq,priority:3"`
+	ProducerID  *string    `gorm:"type:varchar(64);uniqueIndex:idx_producer_seq,priority:1"`
+	Seq         *uint64    `gorm:"uniqueIndex:idx_producer_seq,priority:2"`
+	IngestedBy  *string    `gorm:"type:varchar(96)"` // identitas kredensial yang mengirim data
 	CreatedAt   time.Time  `gorm:"autoCreateTime"`
 	UpdatedAt   *time.Time `gorm:"autoUpdateTime"`
+	// Unit diambil dari katalog sensor_types saat query, tidak disimpan
+	Unit string `gorm:"-" json:",omitempty"`
+}
+
+// DedupKey mengembalikan key (producer_id, seq, ts) dan false kalau data
+// tidak membawa producer_id, artinya tidak bisa di-dedup. Sama dengan
+// idx_producer_seq: seq yang sama dengan ts berbeda bukan duplikat.
+func (s *SensorData) DedupKey() (DedupKey, bool) {
+	if s.ProducerID == nil || s.Seq == nil {
+		return DedupKey{}, false
+	}
+	return DedupKey{ProducerID: *s.ProducerID, Seq: *s.Seq, TS: s.TS.UnixNano()}, true
+}
+
+type DedupKey struct {
+	ProducerID string
+	Seq        uint64
+	TS         int64 // UnixNano, bukan time.Time supaya zona waktu tidak ikut dibandingkan
+}
+
+// BatchResult ringkasan hasil StoreBatch. Data duplikat (producer_id, seq
+// sudah ada) tidak dianggap error, hanya dihitung dan ditandai per baris.
+type BatchResult struct {
+	Inserted   int
+	Duplicates int
+	// Duplicate sejajar dengan data yang disimpan, true untuk baris yang
+	// sudah ada; nil kalau tidak ada duplikat
+	Duplicate []bool
 }
@@ -0,0 +1,23 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrSensorTypeNotFound = errors.New("sensor type not found")
+	ErrSensorTypeExists   = errors.New("sensor type already exists")
+)
+
+// SensorType katalog jenis sensor yang boleh dikirim producer
+type SensorType struct {
+	Name        string    `gorm:"type:varchar(64);primaryKey" json:"name"`
+	Unit        string    `gorm:"type:varchar(32);not null" json:"unit"`
+	Description string    `gorm:"type:varchar(255)" json:"description"`
+	MinValue    float64   `gorm:"not null" json:"min_value"` // nilai di luar min/max ditolak saat ingest
+	MaxValue    float64   `gorm:"not null" json:"max_value"`
+	Precision   int       `gorm:"column:display_precision;not null;default:2" json:"precision"` // jumlah desimal untuk tampilan
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
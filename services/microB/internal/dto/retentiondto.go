@@ -0,0 +1,11 @@
+package dto
+
+// RetentionPolicyRequest sensor_type dan id1_pattern kosong artinya semua
+// data. max_age menerima durasi Go ("720h") atau hari ("30d").
+type RetentionPolicyRequest struct {
+	SensorType string `json:"sensor_type" example:"temperature"`
+	ID1Pattern string `json:"id1_pattern" example:"LAB-*"`
+	MaxAge     string `json:"max_age" example:"30d"`
+	// Enabled default true
+	Enabled *bool `json:"enabled,omitempty" example:"true"`
+}
@@ -0,0 +1,33 @@
+package dto
+
+// SensorReading satu data sensor yang dikirim lewat POST /api/sensors, format
+// sama dengan payload JSON MQTT
+type SensorReading struct {
+	SensorValue float64 `json:"sensor_value" example:"21.5"`
+	SensorType  string  `json:"sensor_type" example:"temperature"`
+	ID1         string  `json:"id1" example:"A"`
+	ID2         int     `json:"id2" example:"1"`
+	Timestamp   string  `json:"timestamp" example:"2025-01-01T10:00:00Z"`
+	// ProducerID dan Seq opsional, dipakai untuk dedup kalau data dikirim ulang
+	ProducerID string `json:"producer_id,omitempty" example:"sensor-gw-1"`
+	Seq        uint64 `json:"seq,omitempty" example:"42"`
+}
+
+// IngestResult hasil satu record. Line nomor baris untuk NDJSON dan CSV,
+// atau urutan data (mulai 1) untuk JSON.
+type IngestResult struct {
+	Line    int    `json:"line" example:"3"`
+	Status  string `json:"status" example:"rejected"` // stored, duplicate, rejected atau failed
+	Message string `json:"message,omitempty" example:"sensor_value 120 out of range"`
+}
+
+// IngestSummary jawaban POST /api/sensors. Error diisi kalau body berhenti
+// diproses di tengah jalan; data sebelum itu tetap tersimpan.
+type IngestSummary struct {
+	Stored     int            `json:"stored"`
+	Duplicates int            `json:"duplicates"`
+	Rejected   int            `json:"rejected"`
+	Failed     int            `json:"failed"`
+	Results    []IngestResult `json:"results"`
+	Error      string         `json:"error,omitempty"`
+}
@@ -0,0 +1,51 @@
+package dto
+
+import (
+	"time"
+
+	sensorpb "github.com/thomasdarmawan9/datastream-backend/proto/sensorpb"
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/domain"
+)
+
+// SensorDataFromProto mengubah pesan proto menjadi entity domain, dipakai
+// server gRPC dan subscriber MQTT. Timestamp yang tidak bisa di-parse
+// dibiarkan kosong supaya ditolak validator. ingestedBy identitas pengirim,
+// kosong kalau autentikasi dimatikan.
+func SensorDataFromProto(data *sensorpb.SensorData, ingestedBy string) *domain.SensorData {
+	t, _ := time.Parse(time.RFC3339, data.Timestamp)
+
+	sensor := &domain.SensorData{
+		SensorValue: data.SensorValue,
+		SensorType:  data.SensorType,
+		ID1:         data.Id1,
+		ID2:         int(data.Id2),
+		TS:          t,
+		CreatedAt:   time.Now(),
+	}
+	if ingestedBy != "" {
+		sensor.IngestedBy = &ingestedBy
+	}
+	// (producer_id, seq) hanya dipakai kalau producer mengirim id-nya
+	if data.ProducerId != "" {
+		producerID, seq := data.ProducerId, data.Seq
+		sensor.ProducerID = &producerID
+		sensor.Seq = &seq
+	}
+	return sensor
+}
+
+// SensorDataToProto kebalikan SensorDataFromProto, untuk data yang dikirim ke
+// subscriber gRPC
+func SensorDataToProto(s *domain.SensorData) *sensorpb.SensorData {
+	data := &sensorpb.SensorData{
+		SensorValue: s.SensorValue,
+		SensorType:  s.SensorType,
+		Id1:         s.ID1,
+		Id2:         int32(s.ID2),
+		Timestamp:   s.TS.Format(time.RFC3339Nano),
+	}
+	if s.ProducerID != nil && s.Seq != nil {
+		data.ProducerId, data.Seq = *s.ProducerID, *s.Seq
+	}
+	return data
+}
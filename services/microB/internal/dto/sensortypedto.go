@@ -0,0 +1,10 @@
+package dto
+
+type SensorTypeRequest struct {
+	Name        string  `json:"name" example:"pressure"`
+	Unit        string  `json:"unit" example:"hPa"`
+	Description string  `json:"description" example:"Barometric pressure"`
+	MinValue    float64 `json:"min_value" example:"800"`
+	MaxValue    float64 `json:"max_value" example:"1100"`
+	Precision   int     `json:"precision" example:"1"`
+}
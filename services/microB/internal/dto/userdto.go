@@ -5,8 +5,15 @@ type LoginRequest struct {
 	Password string `json:"password" example:"password123"`
 }
 
+// RegisterRequest daftar sendiri, selalu dapat role user
 type RegisterRequest struct {
 	Username string `json:"username" example:"newuser"`
 	Password string `json:"password" example:"newpassword"`
-	Role     string `json:"role" example:"viewer"`
+}
+
+// CreateUserRequest akun yang dibuat admin, termasuk producer dan admin lain
+type CreateUserRequest struct {
+	Username string `json:"username" example:"gateway-1"`
+	Password string `json:"password" example:"newpassword"`
+	Role     string `json:"role" example:"producer" enums:"user,producer,admin"`
 }
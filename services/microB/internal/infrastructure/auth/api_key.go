@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"strings"
+)
+
+// APIKeyStore daftar API key producer. Key disimpan sebagai hash sha256 dan
+// dibandingkan constant-time.
+type APIKeyStore struct {
+	keys map[[sha256.Size]byte]string // hash key → nama producer
+}
+
+// NewAPIKeyStore membaca spec "nama:key,nama:key" (contoh dari env GRPC_API_KEYS)
+func NewAPIKeyStore(spec string) (*APIKeyStore, error) {
+	s := &APIKeyStore{keys: map[[sha256.Size]byte]string{}}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, key, ok := strings.Cut(item, ":")
+		if !ok || name == "" || key == "" {
+			return nil, fmt.Errorf("invalid api key entry %q, expected name:key", item)
+		}
+		s.keys[sha256.Sum256([]byte(key))] = name
+	}
+	return s, nil
+}
+
+// Lookup mengembalikan nama producer pemilik key
+func (s *APIKeyStore) Lookup(key string) (string, bool) {
+	sum := sha256.Sum256([]byte(key))
+	for hash, name := range s.keys {
+		if subtle.ConstantTimeCompare(hash[:], sum[:]) == 1 {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+func (s *APIKeyStore) Len() int {
+	return len(s.keys)
+}
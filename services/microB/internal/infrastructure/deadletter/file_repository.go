@@ -0,0 +1,138 @@
+package deadletter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/domain"
+)
+
+// id dibuat sendiri oleh Save, jadi cukup angka dan tanda minus;
+// sekaligus mencegah path traversal lewat parameter :id
+var validID = regexp.MustCompile(`^[0-9]+-[0-9]+$`)
+
+// fileRepo menyimpan tiap batch dead letter sebagai satu file JSON di dir.
+// File hanya ditulis sekali (tmp lalu rename) dan dihapus saat replay/purge,
+// jadi tetap aman kalau proses mati di tengah penulisan.
+type fileRepo struct {
+	dir string
+	seq atomic.Uint64
+}
+
+func NewFileRepository(dir string) (domain.DeadLetterRepository, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &fileRepo{dir: dir}, nil
+}
+
+func (r *fileRepo) path(id string) string {
+	return filepath.Join(r.dir, id+".json")
+}
+
+func (r *fileRepo) Save(dl *domain.DeadLetter) error {
+	if dl.ID == "" {
+		dl.ID = fmt.Sprintf("%d-%d", dl.FailedAt.UnixNano(), r.seq.Add(1))
+	}
+	dl.Count = len(dl.Records)
+
+	data, err := json.Marshal(dl)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(r.dir, dl.ID+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), r.path(dl.ID))
+}
+
+func (r *fileRepo) List() ([]*domain.DeadLetter, error) {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*domain.DeadLetter
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || !validID.MatchString(id) {
+			continue
+		}
+		dl, err := r.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		dl.Records = nil
+		result = append(result, dl)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].FailedAt.Before(result[j].FailedAt) })
+	return result, nil
+}
+
+func (r *fileRepo) Get(id string) (*domain.DeadLetter, error) {
+	if !validID.MatchString(id) {
+		return nil, domain.ErrDeadLetterNotFound
+	}
+	data, err := os.ReadFile(r.path(id))
+	if os.IsNotExist(err) {
+		return nil, domain.ErrDeadLetterNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var dl domain.DeadLetter
+	if err := json.Unmarshal(data, &dl); err != nil {
+		return nil, fmt.Errorf("corrupt dead letter %s: %w", id, err)
+	}
+	return &dl, nil
+}
+
+func (r *fileRepo) Delete(id string) error {
+	if !validID.MatchString(id) {
+		return domain.ErrDeadLetterNotFound
+	}
+	err := os.Remove(r.path(id))
+	if os.IsNotExist(err) {
+		return domain.ErrDeadLetterNotFound
+	}
+	return err
+}
+
+func (r *fileRepo) DeleteAll() (int, error) {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return 0, err
+	}
+	deleted := 0
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || !validID.MatchString(id) {
+			continue
+		}
+		if err := os.Remove(r.path(id)); err != nil && !os.IsNotExist(err) {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
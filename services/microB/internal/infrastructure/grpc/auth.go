@@ -0,0 +1,149 @@
+package grpc
+
+import (
+	"context"
+	"log"
+	"slices"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	sensorpb "github.com/thomasdarmawan9/datastream-backend/proto/sensorpb"
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/infrastructure/auth"
+)
+
+// role JWT yang boleh mengirim data lewat gRPC
+var producerRoles = []string{"producer", "admin"}
+
+// subscriberRoles role yang boleh membaca data, sama dengan REST API
+var subscriberRoles = []string{"producer", "admin", "user"}
+
+// allowedRoles role JWT yang boleh memanggil method ini
+func allowedRoles(fullMethod string) []string {
+	if fullMethod == sensorpb.SensorService_Subscribe_FullMethodName {
+		return subscriberRoles
+	}
+	return producerRoles
+}
+
+type producerKey struct{}
+
+// ProducerFromContext identitas producer yang sudah lolos autentikasi
+func ProducerFromContext(ctx context.Context) string {
+	producer, _ := ctx.Value(producerKey{}).(string)
+	return producer
+}
+
+// Authenticator memeriksa kredensial producer: sertifikat client yang sudah
+// diverifikasi saat handshake mTLS (identitas dari CN), atau metadata gRPC
+// "authorization: Bearer <jwt>" (token dari /login dengan role producer/admin)
+// atau "x-api-key: <key>"
+type Authenticator struct {
+	jwtManager           *auth.JWTManager
+	apiKeys              *auth.APIKeyStore
+	allowInsecureAPIKeys bool
+}
+
+// allowInsecureAPIKeys API key juga diterima lewat koneksi tanpa TLS (dev saja)
+func NewAuthenticator(jwtManager *auth.JWTManager, apiKeys *auth.APIKeyStore, allowInsecureAPIKeys bool) *Authenticator {
+	return &Authenticator{jwtManager: jwtManager, apiKeys: apiKeys, allowInsecureAPIKeys: allowInsecureAPIKeys}
+}
+
+func (a *Authenticator) authenticate(ctx context.Context, fullMethod string) (context.Context, error) {
+	if cn := clientCertName(ctx); cn != "" {
+		return context.WithValue(ctx, producerKey{}, "cert:"+cn), nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	if keys := md.Get("x-api-key"); len(keys) > 0 && a.apiKeys != nil {
+		// key yang terkirim tanpa TLS dianggap bocor, jangan dipakai
+		if !a.allowInsecureAPIKeys && !isTLS(ctx) {
+			return nil, status.Error(codes.Unauthenticated, "api key requires TLS")
+		}
+		name, ok := a.apiKeys.Lookup(keys[0])
+		if !ok {
+			return nil, status.Error(codes.Unauthenticated, "invalid api key")
+		}
+		return context.WithValue(ctx, producerKey{}, "key:"+name), nil
+	}
+
+	if values := md.Get("authorization"); len(values) > 0 {
+		token, ok := strings.CutPrefix(values[0], "Bearer ")
+		if !ok {
+			return nil, status.Error(codes.Unauthenticated, "invalid authorization metadata")
+		}
+		claims, err := a.jwtManager.Verify(token)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, "invalid token")
+		}
+		if !slices.Contains(allowedRoles(fullMethod), claims.Role) {
+			return nil, status.Errorf(codes.PermissionDenied, "role %q not allowed to call %s", claims.Role, fullMethod)
+		}
+		return context.WithValue(ctx, producerKey{}, "user:"+claims.Username), nil
+	}
+
+	return nil, status.Error(codes.Unauthenticated, "missing credentials")
+}
+
+// clientCertName CN sertifikat client yang lolos verifikasi, kosong kalau
+// koneksi tanpa mTLS
+func clientCertName(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 {
+		return ""
+	}
+	return info.State.VerifiedChains[0][0].Subject.CommonName
+}
+
+func isTLS(ctx context.Context) bool {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return false
+	}
+	_, ok = p.AuthInfo.(credentials.TLSInfo)
+	return ok
+}
+
+func (a *Authenticator) UnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if isInfraMethod(info.FullMethod) {
+		return handler(ctx, req)
+	}
+	ctx, err := a.authenticate(ctx, info.FullMethod)
+	if err != nil {
+		log.Printf("gRPC auth failed for %s: %v", info.FullMethod, err)
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a *Authenticator) StreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if isInfraMethod(info.FullMethod) {
+		return handler(srv, ss)
+	}
+	ctx, err := a.authenticate(ss.Context(), info.FullMethod)
+	if err != nil {
+		log.Printf("gRPC auth failed for %s: %v", info.FullMethod, err)
+		return err
+	}
+	return handler(srv, &authedStream{ServerStream: ss, ctx: ctx})
+}
+
+// authedStream ServerStream dengan context yang membawa identitas producer
+type authedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authedStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,159 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	sensorpb "github.com/thomasdarmawan9/datastream-backend/proto/sensorpb"
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/infrastructure/auth"
+)
+
+// peerCtx context RPC dari koneksi dengan AuthInfo tertentu (nil = plaintext)
+func peerCtx(info credentials.AuthInfo, kv ...string) context.Context {
+	ctx := peer.NewContext(context.Background(), &peer.Peer{AuthInfo: info})
+	return metadata.NewIncomingContext(ctx, metadata.Pairs(kv...))
+}
+
+func tlsInfo(cn string) credentials.TLSInfo {
+	var info credentials.TLSInfo
+	if cn != "" {
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: cn}}
+		info.State = tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	}
+	return info
+}
+
+func TestAuthenticate(t *testing.T) {
+	jwt := auth.NewJWTManager("secret", time.Hour)
+	keys, err := auth.NewAPIKeyStore("gw-1:k1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := func(role string) string {
+		tok, err := jwt.Generate(role+"-user", role)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return "Bearer " + tok
+	}
+
+	const (
+		stream    = sensorpb.SensorService_StreamDataWithAck_FullMethodName
+		batch     = sensorpb.SensorService_IngestBatch_FullMethodName
+		subscribe = sensorpb.SensorService_Subscribe_FullMethodName
+	)
+	tests := []struct {
+		name          string
+		ctx           context.Context
+		method        string
+		allowInsecure bool
+		wantCode      codes.Code
+		wantProducer  string
+	}{
+		// role JWT per RPC
+		{"producer sends", peerCtx(nil, "authorization", token("producer")), stream, false, codes.OK, "user:producer-user"},
+		{"producer subscribes", peerCtx(nil, "authorization", token("producer")), subscribe, false, codes.OK, "user:producer-user"},
+		{"admin sends", peerCtx(nil, "authorization", token("admin")), batch, false, codes.OK, "user:admin-user"},
+		{"admin subscribes", peerCtx(nil, "authorization", token("admin")), subscribe, false, codes.OK, "user:admin-user"},
+		{"user cannot send", peerCtx(nil, "authorization", token("user")), stream, false, codes.PermissionDenied, ""},
+		{"user cannot batch", peerCtx(nil, "authorization", token("user")), batch, false, codes.PermissionDenied, ""},
+		{"user subscribes", peerCtx(nil, "authorization", token("user")), subscribe, false, codes.OK, "user:user-user"},
+		{"unknown role", peerCtx(nil, "authorization", token("guest")), subscribe, false, codes.PermissionDenied, ""},
+		{"invalid token", peerCtx(nil, "authorization", "Bearer nope"), stream, false, codes.Unauthenticated, ""},
+		{"not bearer", peerCtx(nil, "authorization", "Basic abc"), stream, false, codes.Unauthenticated, ""},
+		{"no credentials", peerCtx(nil), stream, false, codes.Unauthenticated, ""},
+		{"no peer or metadata", context.Background(), stream, false, codes.Unauthenticated, ""},
+
+		// sertifikat client menang atas metadata apa pun
+		{"mtls cn", peerCtx(tlsInfo("gw-cert")), stream, false, codes.OK, "cert:gw-cert"},
+		{"mtls cn ignores bad token", peerCtx(tlsInfo("gw-cert"), "authorization", "Bearer nope"), subscribe, false, codes.OK, "cert:gw-cert"},
+		{"tls without client cert", peerCtx(tlsInfo("")), stream, false, codes.Unauthenticated, ""},
+
+		// API key tidak terikat role, tapi wajib TLS kecuali diizinkan
+		{"api key over tls", peerCtx(tlsInfo(""), "x-api-key", "k1"), stream, false, codes.OK, "key:gw-1"},
+		{"api key subscribes", peerCtx(tlsInfo(""), "x-api-key", "k1"), subscribe, false, codes.OK, "key:gw-1"},
+		{"api key beats user token", peerCtx(tlsInfo(""), "x-api-key", "k1", "authorization", token("user")), stream, false, codes.OK, "key:gw-1"},
+		{"wrong api key", peerCtx(tlsInfo(""), "x-api-key", "k2"), stream, false, codes.Unauthenticated, ""},
+		{"api key over plaintext", peerCtx(nil, "x-api-key", "k1"), stream, false, codes.Unauthenticated, ""},
+		{"api key over plaintext allowed", peerCtx(nil, "x-api-key", "k1"), stream, true, codes.OK, "key:gw-1"},
+		{"wrong api key over plaintext allowed", peerCtx(nil, "x-api-key", "k2"), stream, true, codes.Unauthenticated, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAuthenticator(jwt, keys, tt.allowInsecure)
+			ctx, err := a.authenticate(tt.ctx, tt.method)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("code %v, want %v (%v)", code, tt.wantCode, err)
+			}
+			if err == nil && ProducerFromContext(ctx) != tt.wantProducer {
+				t.Fatalf("producer %q, want %q", ProducerFromContext(ctx), tt.wantProducer)
+			}
+		})
+	}
+}
+
+// fakeServerStream ServerStream yang hanya membawa context
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s fakeServerStream) Context() context.Context { return s.ctx }
+
+func TestAuthInterceptors(t *testing.T) {
+	a := NewAuthenticator(auth.NewJWTManager("secret", time.Hour), nil, false)
+	method := sensorpb.SensorService_StreamDataWithAck_FullMethodName
+	cert := peerCtx(tlsInfo("gw-cert"))
+
+	var producer string
+	_, err := a.UnaryInterceptor(cert, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, _ any) (any, error) {
+		producer = ProducerFromContext(ctx)
+		return nil, nil
+	})
+	if err != nil || producer != "cert:gw-cert" {
+		t.Fatalf("unary: producer %q, err %v", producer, err)
+	}
+
+	producer = ""
+	err = a.StreamInterceptor(nil, fakeServerStream{ctx: cert}, &grpc.StreamServerInfo{FullMethod: method}, func(_ any, ss grpc.ServerStream) error {
+		producer = ProducerFromContext(ss.Context())
+		return nil
+	})
+	if err != nil || producer != "cert:gw-cert" {
+		t.Fatalf("stream: producer %q, err %v", producer, err)
+	}
+
+	called := false
+	_, err = a.UnaryInterceptor(peerCtx(nil), nil, &grpc.UnaryServerInfo{FullMethod: method}, func(context.Context, any) (any, error) {
+		called = true
+		return nil, nil
+	})
+	if status.Code(err) != codes.Unauthenticated || called {
+		t.Fatalf("unary without credentials: err %v, handler called %v", err, called)
+	}
+
+	// health check tidak butuh kredensial
+	_, err = a.UnaryInterceptor(peerCtx(nil), nil, &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}, func(context.Context, any) (any, error) {
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("health check: %v", err)
+	}
+	err = a.StreamInterceptor(nil, fakeServerStream{ctx: peerCtx(nil)}, &grpc.StreamServerInfo{FullMethod: "/grpc.health.v1.Health/Watch"}, func(any, grpc.ServerStream) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("health watch: %v", err)
+	}
+}
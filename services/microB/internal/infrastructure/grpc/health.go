@@ -0,0 +1,70 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	sensorpb "github.com/thomasdarmawan9/datastream-backend/proto/sensorpb"
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/infrastructure/health"
+)
+
+// NewHealthServer health service standar (grpc.health.v1) yang mengikuti
+// readiness: NOT_SERVING sampai MySQL bisa dihubungi dan migrasi selesai
+func NewHealthServer(readiness *health.Readiness) *grpchealth.Server {
+	hs := grpchealth.NewServer()
+	readiness.OnChange(func(ready bool) {
+		st := healthpb.HealthCheckResponse_NOT_SERVING
+		if ready {
+			st = healthpb.HealthCheckResponse_SERVING
+		}
+		hs.SetServingStatus("", st)
+		hs.SetServingStatus(sensorpb.SensorService_ServiceDesc.ServiceName, st)
+	})
+	return hs
+}
+
+// isInfraMethod health check dan reflection tidak butuh kredensial producer
+// dan tetap jalan walau MicroB belum siap
+func isInfraMethod(fullMethod string) bool {
+	return strings.HasPrefix(fullMethod, "/grpc.health.v1.Health/") ||
+		strings.HasPrefix(fullMethod, "/grpc.reflection.")
+}
+
+// ReadinessGate menolak RPC dengan UNAVAILABLE selama MicroB belum siap
+type ReadinessGate struct {
+	readiness *health.Readiness
+}
+
+func NewReadinessGate(readiness *health.Readiness) *ReadinessGate {
+	return &ReadinessGate{readiness: readiness}
+}
+
+func (g *ReadinessGate) check(fullMethod string) error {
+	if isInfraMethod(fullMethod) {
+		return nil
+	}
+	if ready, reason := g.readiness.Ready(); !ready {
+		return status.Error(codes.Unavailable, "service not ready: "+reason)
+	}
+	return nil
+}
+
+func (g *ReadinessGate) UnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := g.check(info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (g *ReadinessGate) StreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := g.check(info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
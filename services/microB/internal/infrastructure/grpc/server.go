@@ -1,109 +1,441 @@
 package grpc
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"sync"
-	"time"
+	"sync/atomic"
 
 	sensorpb "github.com/thomasdarmawan9/datastream-backend/proto/sensorpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/domain"
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/dto"
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/usecase"
 )
 
+// SensorGRPCServer hanya memasukkan data ke pipeline ingest; batching dan
+// penulisan ke database dikerjakan pipeline yang dipakai bersama semua stream.
+// Subscriber membaca data yang sudah ter-commit dari hub.
 type SensorGRPCServer struct {
 	sensorpb.UnimplementedSensorServiceServer
-	sensorRepo domain.SensorRepository
+	pipeline usecase.IngestPipeline
+	hub      *usecase.SensorHub
+
+	drainOnce sync.Once
+	draining  chan struct{}
 }
 
-func NewSensorGRPCServer(repo domain.SensorRepository) *SensorGRPCServer {
-	return &SensorGRPCServer{sensorRepo: repo}
+// hub boleh nil, Subscribe lalu dibalas UNIMPLEMENTED
+func NewSensorGRPCServer(pipeline usecase.IngestPipeline, hub *usecase.SensorHub) *SensorGRPCServer {
+	return &SensorGRPCServer{pipeline: pipeline, hub: hub, draining: make(chan struct{})}
 }
 
-// StreamData menerima stream dari MicroA
-func (s *SensorGRPCServer) StreamData(stream sensorpb.SensorService_StreamDataServer) error {
-	var (
-		mu      sync.Mutex
-		sensors []*domain.SensorData
-	)
+// Drain dipanggil saat shutdown: semua stream berhenti membaca pesan baru,
+// menunggu pesan yang sudah masuk pipeline selesai (dan di-ack), lalu ditutup
+// dengan UNAVAILABLE supaya producer reconnect dan mengirim ulang sisanya
+func (s *SensorGRPCServer) Drain() {
+	s.drainOnce.Do(func() { close(s.draining) })
+}
+
+var errDraining = status.Error(codes.Unavailable, "server shutting down")
 
-	// ticker buat auto flush tiap 5 detik
-	ticker := time.NewTicker(5 * time.Second)
-	defer ticker.Stop()
+type recvResult struct {
+	req *sensorpb.StreamRequest
+	err error
+}
 
-	// goroutine buat auto flush
-	done := make(chan struct{})
+// receive menjalankan Recv di goroutine sendiri supaya handler bisa berhenti
+// membaca saat Drain tanpa menunggu pesan berikutnya dari producer
+func (s *SensorGRPCServer) receive(stream interface {
+	Recv() (*sensorpb.StreamRequest, error)
+	Context() context.Context
+}) func() (*sensorpb.StreamRequest, error) {
+	ch := make(chan recvResult)
 	go func() {
 		for {
+			req, err := stream.Recv()
 			select {
-			case <-ticker.C:
-				mu.Lock()
-				if len(sensors) > 0 {
-					log.Printf("Auto flushing %d records...", len(sensors))
-					if err := s.sensorRepo.StoreBatch(sensors); err != nil {
-						log.Printf("Error storing batch: %v", err)
-					} else {
-						log.Printf("Successfully flushed %d records", len(sensors))
-						sensors = nil // kosongkan buffer
-					}
-				}
-				mu.Unlock()
-			case <-done:
+			case ch <- recvResult{req, err}:
+			case <-stream.Context().Done():
+				return
+			}
+			if err != nil {
 				return
 			}
 		}
 	}()
+	return func() (*sensorpb.StreamRequest, error) {
+		select {
+		case r := <-ch:
+			return r.req, r.err
+		case <-s.draining:
+			return nil, errDraining
+		}
+	}
+}
 
+// StreamData menerima stream dari MicroA
+func (s *SensorGRPCServer) StreamData(stream sensorpb.SensorService_StreamDataServer) error {
+	var (
+		wg          sync.WaitGroup
+		mu          sync.Mutex
+		failed      int
+		firstErr    error
+		duplicates  int
+		rejected    int
+		firstReject error
+		// deadLettered gagal disimpan tapi aman di dead letter; dikirim
+		// ulang pun akan masuk dead letter lagi, jadi tidak dihitung failed
+		deadLettered int
+	)
+
+	recv := s.receive(stream)
 	for {
-		req, err := stream.Recv()
+		req, err := recv()
+		if err == errDraining {
+			// data yang sudah diterima tetap ditulis, sisanya dikirim ulang producer
+			wg.Wait()
+			return err
+		}
 		if err == io.EOF {
-			// flush terakhir
-			mu.Lock()
-			if len(sensors) > 0 {
-				if err := s.sensorRepo.StoreBatch(sensors); err != nil {
-					log.Printf("Error storing batch on EOF: %v", err)
-					mu.Unlock()
-					close(done)
-					return stream.SendAndClose(&sensorpb.StreamResponse{
-						Status:  "error",
-						Message: err.Error(),
-					})
-				}
-				log.Printf("Successfully flushed %d records on EOF", len(sensors))
+			// tunggu semua data dari stream ini selesai ditulis
+			wg.Wait()
+			if failed > 0 {
+				return stream.SendAndClose(&sensorpb.StreamResponse{
+					Status:  "error",
+					Message: fmt.Sprintf("%d records not stored: %v", failed, firstErr),
+				})
 			}
-			mu.Unlock()
 
-			close(done)
+			message := "data stored"
+			if duplicates > 0 {
+				message += fmt.Sprintf(", %d duplicates skipped", duplicates)
+			}
+			if deadLettered > 0 {
+				message += fmt.Sprintf(", %d dead-lettered", deadLettered)
+			}
+			if rejected > 0 {
+				// data yang ditolak tidak akan pernah disimpan, beri tahu producer alasannya
+				message += fmt.Sprintf(", %d rejected (first: %v)", rejected, firstReject)
+			}
 			return stream.SendAndClose(&sensorpb.StreamResponse{
 				Status:  "ok",
-				Message: "data stored",
+				Message: message,
 			})
 		}
 		if err != nil {
-			close(done)
 			return err
 		}
 
 		data := req.GetData()
 
-		// parse timestamp
-		t, err := time.Parse(time.RFC3339, data.Timestamp)
+		wg.Add(1)
+		err = s.pipeline.Enqueue(stream.Context(), usecase.IngestRecord{
+			Data: dto.SensorDataFromProto(data, ProducerFromContext(stream.Context())),
+			Done: func(err error) {
+				switch {
+				case errors.Is(err, usecase.ErrDeadLettered):
+					mu.Lock()
+					deadLettered++
+					mu.Unlock()
+				case err != nil:
+					mu.Lock()
+					failed++
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+				}
+				wg.Done()
+			},
+		})
+		if err != nil {
+			wg.Done()
+			if errors.Is(err, usecase.ErrDuplicate) {
+				duplicates++
+				continue
+			}
+			if isRejected(err) {
+				log.Printf("Rejected data %+v: %v", data, err)
+				rejected++
+				if firstReject == nil {
+					firstReject = err
+				}
+				continue
+			}
+			return toStatus(err)
+		}
+
+		log.Printf("Received data: %+v", data)
+	}
+}
+
+// StreamDataWithAck menerima stream dari MicroA dan mengirim ack per pesan.
+// Tanpa WAL, ack baru dikirim setelah batch yang memuat pesan itu berhasil
+// di-commit; kalau StoreBatch tetap gagal setelah retry, pesan di-nack supaya
+// producer bisa mengirim ulang, kecuali batch-nya sudah masuk dead letter. Karena batch ditulis beberapa writer secara
+// paralel, urutan ack bisa berbeda dengan urutan pesan. Dengan WAL, ack
+// dikirim begitu pesan tersimpan di WAL. Pesan yang tidak lolos validasi
+// dibalas status "rejected" dan tidak perlu dikirim ulang.
+func (s *SensorGRPCServer) StreamDataWithAck(stream sensorpb.SensorService_StreamDataWithAckServer) error {
+	acks := newAckSender(stream)
+	go acks.run()
+
+	// dengan WAL, pesan di-ack begitu tersimpan di disk MicroB
+	durable := s.pipeline.Durable()
+
+	var wg sync.WaitGroup
+	recv := s.receive(stream)
+	for {
+		req, err := recv()
+		if err == io.EOF || err == errDraining {
+			// tunggu semua pesan di-ack sebelum stream ditutup
+			wg.Wait()
+			if cerr := acks.close(); cerr != nil {
+				return cerr
+			}
+			if err == errDraining {
+				return err
+			}
+			return nil
+		}
 		if err != nil {
-			log.Printf("Invalid timestamp: %v, using now()", err)
-			t = time.Now()
+			acks.abort()
+			return err
 		}
 
-		mu.Lock()
-		sensors = append(sensors, &domain.SensorData{
-			SensorValue: data.SensorValue,
-			SensorType:  data.SensorType,
-			ID1:         data.Id1,
-			ID2:         int(data.Id2),
-			TS:          t,
-			CreatedAt:   time.Now(),
+		data := req.GetData()
+		seq := data.Seq
+
+		wg.Add(1)
+		err = s.pipeline.Enqueue(stream.Context(), usecase.IngestRecord{
+			Data: dto.SensorDataFromProto(data, ProducerFromContext(stream.Context())),
+			Done: func(err error) {
+				defer wg.Done()
+				if durable {
+					return // sudah di-ack saat masuk WAL
+				}
+				ack := &sensorpb.StreamAck{Seq: seq, Status: "ok", Message: "stored"}
+				switch {
+				case errors.Is(err, usecase.ErrDeadLettered):
+					// sama dengan MQTT: data aman di dead letter, kalau di-nack
+					// producer akan mengirim ulang dan dead letter terisi duplikat
+					ack.Message = "dead-lettered"
+				case err != nil:
+					ack.Status, ack.Message = "error", err.Error()
+				}
+				acks.push(ack)
+			},
 		})
-		mu.Unlock()
+		if err != nil {
+			wg.Done()
+			if errors.Is(err, usecase.ErrDuplicate) {
+				acks.push(&sensorpb.StreamAck{Seq: seq, Status: "ok", Message: "duplicate"})
+				continue
+			}
+			if isRejected(err) {
+				log.Printf("Rejected data %+v: %v", data, err)
+				acks.push(&sensorpb.StreamAck{Seq: seq, Status: "rejected", Message: err.Error()})
+				continue
+			}
+			acks.abort()
+			return toStatus(err)
+		}
+		if durable {
+			acks.push(&sensorpb.StreamAck{Seq: seq, Status: "ok", Message: "accepted"})
+		}
+	}
+}
 
-		log.Printf("Received data: %+v", data)
+// maxIngestBatchItems batas jumlah data per IngestBatch, semuanya ditulis
+// dalam satu transaksi
+const maxIngestBatchItems = 10000
+
+// IngestBatch menyimpan semua data yang lolos validasi dalam satu transaksi
+// dan membalas status per item. Data duplikat dianggap sudah tersimpan.
+func (s *SensorGRPCServer) IngestBatch(ctx context.Context, req *sensorpb.IngestBatchRequest) (*sensorpb.IngestBatchResponse, error) {
+	items := req.GetData()
+	if len(items) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "data is empty")
+	}
+	if len(items) > maxIngestBatchItems {
+		return nil, status.Errorf(codes.InvalidArgument, "too many items: %d (max %d)", len(items), maxIngestBatchItems)
+	}
+
+	producer := ProducerFromContext(ctx)
+	data := make([]*domain.SensorData, len(items))
+	for i, item := range items {
+		data[i] = dto.SensorDataFromProto(item, producer)
+	}
+	itemErrs, res, err := s.pipeline.IngestBatch(data)
+	if err != nil {
+		// transaksi di-rollback, producer boleh mengirim ulang seluruh batch
+		return nil, status.Errorf(codes.Unavailable, "batch not stored: %v", err)
+	}
+
+	// duplikat dihitung dari status per item, yang di cache dedup maupun di database
+	resp := &sensorpb.IngestBatchResponse{
+		Stored: uint32(res.Inserted),
+		Items:  make([]*sensorpb.IngestItemStatus, len(items)),
+	}
+	for i, item := range items {
+		st := &sensorpb.IngestItemStatus{Seq: item.Seq, Status: "ok", Message: "stored"}
+		switch err := itemErrs[i]; {
+		case err == nil:
+		case errors.Is(err, usecase.ErrDuplicate):
+			st.Message = "duplicate"
+			resp.Duplicates++
+		case isRejected(err):
+			st.Status, st.Message = "rejected", err.Error()
+			resp.Rejected++
+		}
+		resp.Items[i] = st
+	}
+	log.Printf("IngestBatch from %q: %d stored, %d duplicates, %d rejected", producer, resp.Stored, resp.Duplicates, resp.Rejected)
+	return resp, nil
+}
+
+// Subscribe mengirim data yang cocok dengan filter begitu ter-commit, sampai
+// client memutus stream, server shutdown, atau subscriber diputus hub karena
+// terlalu lambat
+func (s *SensorGRPCServer) Subscribe(req *sensorpb.SubscribeRequest, stream sensorpb.SensorService_SubscribeServer) error {
+	if s.hub == nil {
+		return status.Error(codes.Unimplemented, "subscriptions disabled")
+	}
+	filter := usecase.SubscriptionFilter{
+		ID1:         req.GetId1(),
+		ID2:         int(req.GetId2()),
+		SensorTypes: req.GetSensorTypes(),
+	}
+	// proto tidak membawa ID event, jadi tidak ada resume
+	sub := s.hub.Subscribe(filter, 0)
+	defer sub.Close()
+
+	subscriber := ProducerFromContext(stream.Context())
+	log.Printf("Subscriber %q started (filter %+v, %d active)", subscriber, filter, s.hub.Subscribers())
+	defer func() {
+		log.Printf("Subscriber %q stopped (%d dropped)", subscriber, sub.Dropped())
+	}()
+
+	for {
+		select {
+		case ev := <-sub.C():
+			if err := stream.Send(dto.SensorDataToProto(ev.Data)); err != nil {
+				return err
+			}
+		case <-sub.Done():
+			if errors.Is(sub.Err(), usecase.ErrHubClosed) {
+				return errDraining
+			}
+			return status.Error(codes.ResourceExhausted, sub.Err().Error())
+		case <-s.draining:
+			return errDraining
+		case <-stream.Context().Done():
+			return status.FromContextError(stream.Context().Err()).Err()
+		}
+	}
+}
+
+// ackSender mengirim ack dari satu goroutine, karena Done dipanggil dari
+// writer pipeline dan stream.Send tidak boleh dipanggil bersamaan
+type ackSender struct {
+	stream sensorpb.SensorService_StreamDataWithAckServer
+
+	mu      sync.Mutex
+	pending []*sensorpb.StreamAck
+	notify  chan struct{}
+	stop    chan struct{}
+	stopped chan struct{}
+	aborted atomic.Bool
+	err     error
+}
+
+func newAckSender(stream sensorpb.SensorService_StreamDataWithAckServer) *ackSender {
+	return &ackSender{
+		stream:  stream,
+		notify:  make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+}
+
+func (a *ackSender) push(ack *sensorpb.StreamAck) {
+	a.mu.Lock()
+	a.pending = append(a.pending, ack)
+	a.mu.Unlock()
+	select {
+	case a.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (a *ackSender) run() {
+	defer close(a.stopped)
+	for {
+		select {
+		case <-a.notify:
+		case <-a.stop:
+			if !a.aborted.Load() {
+				a.err = a.send()
+			}
+			return
+		}
+		if err := a.send(); err != nil {
+			log.Printf("Error sending ack: %v", err)
+			a.err = err
+			<-a.stop
+			return
+		}
 	}
 }
+
+func (a *ackSender) send() error {
+	a.mu.Lock()
+	pending := a.pending
+	a.pending = nil
+	a.mu.Unlock()
+	for _, ack := range pending {
+		if err := a.stream.Send(ack); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// close mengirim sisa ack lalu menunggu goroutine run selesai
+func (a *ackSender) close() error {
+	close(a.stop)
+	<-a.stopped
+	return a.err
+}
+
+// abort menghentikan run tanpa mengirim sisa ack
+func (a *ackSender) abort() {
+	a.aborted.Store(true)
+	close(a.stop)
+	<-a.stopped
+}
+
+// toStatus memetakan error pipeline ke status gRPC
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, usecase.ErrQueueFull):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, usecase.ErrPipelineClosed):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// isRejected true kalau data ditolak validator
+func isRejected(err error) bool {
+	var verr *usecase.ValidationError
+	return errors.As(err, &verr)
+}
@@ -0,0 +1,191 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+
+	sensorpb "github.com/thomasdarmawan9/datastream-backend/proto/sensorpb"
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/domain"
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/dto"
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/usecase"
+)
+
+// dupRepo menganggap seq di dup sudah ada di database
+type dupRepo struct {
+	domain.SensorRepository
+	dup map[uint64]bool
+}
+
+func (r *dupRepo) StoreBatch(sensors []*domain.SensorData) (domain.BatchResult, error) {
+	var res domain.BatchResult
+	flags := make([]bool, len(sensors))
+	for i, s := range sensors {
+		if r.dup[*s.Seq] {
+			flags[i] = true
+			res.Duplicates++
+			continue
+		}
+		res.Inserted++
+	}
+	if res.Duplicates > 0 {
+		res.Duplicate = flags
+	}
+	return res, nil
+}
+
+type sensorTypes map[string]*domain.SensorType
+
+func (t sensorTypes) Lookup(name string) (*domain.SensorType, bool) {
+	st, ok := t[name]
+	return st, ok
+}
+
+func TestIngestBatchItemStatus(t *testing.T) {
+	validator, err := usecase.NewValidator(usecase.ValidationConfig{ID2Max: 100, MaxFuture: time.Hour}, sensorTypes{
+		"temperature": {Name: "temperature", MinValue: -50, MaxValue: 150},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dedup := usecase.NewDeduplicator(100)
+	item := func(seq uint64, id1 string) *sensorpb.SensorData {
+		return &sensorpb.SensorData{
+			SensorValue: 21.5,
+			SensorType:  "temperature",
+			Id1:         id1,
+			Id2:         1,
+			Timestamp:   time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC).Format(time.RFC3339),
+			ProducerId:  "gw-1",
+			Seq:         seq,
+		}
+	}
+	// seq 2 sudah di cache dedup, seq 4 baru ketahuan duplikat di database
+	dedup.MarkCommitted([]*domain.SensorData{dto.SensorDataFromProto(item(2, "A"), "")})
+	pipeline := usecase.NewIngestPipeline(&dupRepo{dup: map[uint64]bool{4: true}}, dedup, validator, nil, nil, nil, usecase.IngestConfig{})
+	defer pipeline.Close()
+
+	resp, err := NewSensorGRPCServer(pipeline, nil).IngestBatch(context.Background(), &sensorpb.IngestBatchRequest{
+		Data: []*sensorpb.SensorData{item(1, "A"), item(2, "A"), item(3, "bad id"), item(4, "A"), item(5, "A")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct{ status, message string }{
+		{"ok", "stored"},
+		{"ok", "duplicate"},
+		{"rejected", ""},
+		{"ok", "duplicate"},
+		{"ok", "stored"},
+	}
+	for i, w := range want {
+		got := resp.Items[i]
+		if got.Seq != uint64(i+1) || got.Status != w.status || (w.message != "" && got.Message != w.message) {
+			t.Errorf("item %d: seq %d %s %q, want %s %q", i, got.Seq, got.Status, got.Message, w.status, w.message)
+		}
+	}
+	if resp.Stored != 2 || resp.Duplicates != 2 || resp.Rejected != 1 {
+		t.Fatalf("stored %d duplicates %d rejected %d, want 2, 2 and 1", resp.Stored, resp.Duplicates, resp.Rejected)
+	}
+}
+
+// failRepo StoreBatch selalu gagal, seperti database mati
+type failRepo struct{ domain.SensorRepository }
+
+func (failRepo) StoreBatch([]*domain.SensorData) (domain.BatchResult, error) {
+	return domain.BatchResult{}, errors.New("database down")
+}
+
+type deadLetters struct {
+	domain.DeadLetterRepository
+	mu    sync.Mutex
+	saved int
+}
+
+func (d *deadLetters) Save(dl *domain.DeadLetter) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.saved += len(dl.Records)
+	return nil
+}
+
+// dialServer SensorGRPCServer di atas koneksi in-memory
+func dialServer(t *testing.T, srv *SensorGRPCServer) sensorpb.SensorServiceClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	gs := grpc.NewServer()
+	sensorpb.RegisterSensorServiceServer(gs, srv)
+	go gs.Serve(lis)
+	t.Cleanup(gs.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return sensorpb.NewSensorServiceClient(conn)
+}
+
+func TestStreamAckDeadLettered(t *testing.T) {
+	tests := []struct {
+		name        string
+		deadLetters *deadLetters // nil: dead letter tidak aktif
+		wantStatus  string
+		wantMessage string
+	}{
+		{"dead-lettered is acked", &deadLetters{}, "ok", "dead-lettered"},
+		{"failed without dead letter is nacked", nil, "error", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var dl domain.DeadLetterRepository
+			if tt.deadLetters != nil {
+				dl = tt.deadLetters
+			}
+			pipeline := usecase.NewIngestPipeline(failRepo{}, usecase.NewDeduplicator(100), nil, dl, nil, nil, usecase.IngestConfig{
+				BatchSize:     1,
+				FlushInterval: 10 * time.Millisecond,
+				RetryBackoff:  time.Millisecond,
+			})
+			defer pipeline.Close()
+			client := dialServer(t, NewSensorGRPCServer(pipeline, nil))
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			stream, err := client.StreamDataWithAck(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for seq := uint64(1); seq <= 2; seq++ {
+				data := &sensorpb.SensorData{SensorValue: 1, SensorType: "temperature", Id1: "A", Id2: 1,
+					Timestamp: "2026-01-02T03:04:05Z", ProducerId: "gw-1", Seq: seq}
+				if err := stream.Send(&sensorpb.StreamRequest{Data: data}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for range 2 {
+				ack, err := stream.Recv()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if ack.Status != tt.wantStatus || (tt.wantMessage != "" && ack.Message != tt.wantMessage) {
+					t.Fatalf("ack seq %d: %s %q, want %s %q", ack.Seq, ack.Status, ack.Message, tt.wantStatus, tt.wantMessage)
+				}
+			}
+			stream.CloseSend()
+			if tt.deadLetters != nil && tt.deadLetters.saved != 2 {
+				t.Fatalf("%d records dead-lettered, want 2", tt.deadLetters.saved)
+			}
+		})
+	}
+}
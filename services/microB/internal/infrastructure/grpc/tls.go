@@ -0,0 +1,51 @@
+package grpc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// TLSConfig pengaturan TLS listener gRPC
+type TLSConfig struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile CA untuk memverifikasi sertifikat producer (mTLS); kosong
+	// artinya TLS biasa tanpa sertifikat client
+	ClientCAFile string
+	// ClientCertOptional sertifikat client hanya diverifikasi kalau dikirim,
+	// producer tanpa sertifikat tetap bisa masuk dengan API key/JWT
+	ClientCertOptional bool
+}
+
+// ServerTLSConfig membuat tls.Config untuk grpc.Creds
+func ServerTLSConfig(cfg TLSConfig) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load server certificate: %w", err)
+	}
+	tlsCfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if cfg.ClientCAFile == "" {
+		return tlsCfg, nil
+	}
+
+	pem, err := os.ReadFile(cfg.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("read client CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("client CA file contains no certificates")
+	}
+	tlsCfg.ClientCAs = pool
+	tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	if cfg.ClientCertOptional {
+		tlsCfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tlsCfg, nil
+}
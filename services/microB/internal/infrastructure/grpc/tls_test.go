@@ -0,0 +1,155 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/thomasdarmawan9/datastream-backend/services/internal/testpki"
+)
+
+// startTLSServer gRPC server dengan health service; cn berisi CN sertifikat
+// client yang dilihat server untuk panggilan terakhir
+func startTLSServer(t *testing.T, cfg TLSConfig) (addr string, cn <-chan string) {
+	t.Helper()
+	tlsCfg, err := ServerTLSConfig(cfg)
+	if err != nil {
+		t.Fatalf("server TLS config: %v", err)
+	}
+	seen := make(chan string, 1)
+	srv := grpc.NewServer(
+		grpc.Creds(credentials.NewTLS(tlsCfg)),
+		grpc.UnaryInterceptor(func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			seen <- clientCertName(ctx)
+			return handler(ctx, req)
+		}),
+	)
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	return lis.Addr().String(), seen
+}
+
+// check satu panggilan Health.Check lewat TLS; client nil artinya tanpa
+// sertifikat client
+func check(t *testing.T, addr, rootCA string, client *testpki.Pair) error {
+	t.Helper()
+	pem, err := os.ReadFile(rootCA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(pem)
+	tlsCfg := &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	if client != nil {
+		cert, err := tls.LoadX509KeyPair(client.CertFile, client.KeyFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// selalu dikirim, walau CA-nya tidak ada di daftar CA server
+		tlsCfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return &cert, nil
+		}
+	}
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(credentials.NewTLS(tlsCfg)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	return err
+}
+
+func TestServerTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := testpki.NewCA(t, dir, "ca")
+	otherCA := testpki.NewCA(t, dir, "other-ca")
+	server := ca.Server(t, "microb")
+	client := ca.Client(t, "microa-1")
+	stranger := otherCA.Client(t, "microa-1")
+
+	mtls := TLSConfig{CertFile: server.CertFile, KeyFile: server.KeyFile, ClientCAFile: ca.File}
+	optional := mtls
+	optional.ClientCertOptional = true
+	serverOnly := TLSConfig{CertFile: server.CertFile, KeyFile: server.KeyFile}
+
+	tests := []struct {
+		name    string
+		server  TLSConfig
+		rootCA  string
+		client  *testpki.Pair
+		wantErr bool
+		wantCN  string
+	}{
+		{name: "mtls", server: mtls, rootCA: ca.File, client: &client, wantCN: "microa-1"},
+		{name: "mtls without client cert", server: mtls, rootCA: ca.File, wantErr: true},
+		{name: "mtls with client cert from other CA", server: mtls, rootCA: ca.File, client: &stranger, wantErr: true},
+		{name: "client does not trust server CA", server: mtls, rootCA: otherCA.File, client: &client, wantErr: true},
+		{name: "optional mtls with cert", server: optional, rootCA: ca.File, client: &client, wantCN: "microa-1"},
+		{name: "optional mtls without cert", server: optional, rootCA: ca.File},
+		{name: "optional mtls with cert from other CA", server: optional, rootCA: ca.File, client: &stranger, wantErr: true},
+		{name: "server only", server: serverOnly, rootCA: ca.File},
+		// tanpa ClientCAFile sertifikat client tidak diminta, jadi tidak jadi identitas
+		{name: "server only ignores client cert", server: serverOnly, rootCA: ca.File, client: &client},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, seen := startTLSServer(t, tt.server)
+			err := check(t, addr, tt.rootCA, tt.client)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("handshake succeeded, want rejection")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("check: %v", err)
+			}
+			if cn := <-seen; cn != tt.wantCN {
+				t.Fatalf("client identity %q, want %q", cn, tt.wantCN)
+			}
+		})
+	}
+}
+
+func TestServerTLSConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	ca := testpki.NewCA(t, dir, "ca")
+	server := ca.Server(t, "microb")
+	empty := dir + "/empty.pem"
+	if err := os.WriteFile(empty, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		cfg  TLSConfig
+	}{
+		{"missing key", TLSConfig{CertFile: server.CertFile, KeyFile: dir + "/missing.key"}},
+		{"missing client CA", TLSConfig{CertFile: server.CertFile, KeyFile: server.KeyFile, ClientCAFile: dir + "/missing.crt"}},
+		{"client CA without certificates", TLSConfig{CertFile: server.CertFile, KeyFile: server.KeyFile, ClientCAFile: empty}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ServerTLSConfig(tt.cfg); err == nil {
+				t.Fatal("want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sync"
+	"time"
+)
+
+// Readiness status siap tidaknya MicroB menerima data. Satu status dipakai
+// bersama oleh health service gRPC dan endpoint health HTTP.
+type Readiness struct {
+	mu        sync.RWMutex
+	ready     bool
+	reason    string
+	degraded  string // dependency bermasalah tapi data tetap diterima
+	stopping  bool
+	listeners []func(ready bool)
+}
+
+func NewReadiness() *Readiness {
+	return &Readiness{reason: "starting"}
+}
+
+// Set mengubah status; reason menjelaskan kenapa belum siap
+func (r *Readiness) Set(ready bool, reason string) {
+	r.mu.Lock()
+	if r.stopping && ready {
+		// sudah shutdown, WatchDB tidak boleh menandai siap lagi
+		r.mu.Unlock()
+		return
+	}
+	changed := r.ready != ready
+	r.ready, r.reason = ready, reason
+	listeners := r.listeners
+	r.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	if ready {
+		log.Println("MicroB is ready")
+	} else {
+		log.Printf("MicroB not ready: %s", reason)
+	}
+	for _, fn := range listeners {
+		fn(ready)
+	}
+}
+
+// Shutdown menandai tidak siap untuk seterusnya, dipanggil di awal graceful
+// shutdown supaya load balancer / MicroA berhenti mengirim data
+func (r *Readiness) Shutdown() {
+	r.mu.Lock()
+	r.stopping = true
+	r.mu.Unlock()
+	r.Set(false, "shutting down")
+}
+
+func (r *Readiness) Ready() (bool, string) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.ready, r.reason
+}
+
+// SetDegraded menandai ada dependency yang bermasalah tanpa menolak data,
+// reason kosong artinya sudah normal lagi
+func (r *Readiness) SetDegraded(reason string) {
+	r.mu.Lock()
+	changed := r.degraded != reason
+	r.degraded = reason
+	r.mu.Unlock()
+
+	switch {
+	case !changed:
+	case reason != "":
+		log.Printf("MicroB degraded: %s", reason)
+	default:
+		log.Println("MicroB no longer degraded")
+	}
+}
+
+// Degraded alasan status degraded, kosong kalau normal
+func (r *Readiness) Degraded() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.degraded
+}
+
+// OnChange memanggil fn setiap status berubah, dan sekali di awal dengan status sekarang
+func (r *Readiness) OnChange(fn func(ready bool)) {
+	r.mu.Lock()
+	r.listeners = append(r.listeners, fn)
+	ready := r.ready
+	r.mu.Unlock()
+	fn(ready)
+}
+
+// WatchDB ping database setiap interval dan menandai tidak siap selama
+// database tidak bisa dihubungi. Dengan buffered (ingest memakai WAL) data
+// tetap diterima dan ditulis ke database setelah pulih, jadi status hanya
+// degraded supaya producer tidak berhenti mengirim.
+func (r *Readiness) WatchDB(ctx context.Context, db *sql.DB, interval time.Duration, buffered bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		pctx, cancel := context.WithTimeout(ctx, interval)
+		err := db.PingContext(pctx)
+		cancel()
+		switch {
+		case err != nil && buffered:
+			r.SetDegraded("database unreachable, buffering in WAL: " + err.Error())
+		case err != nil:
+			r.Set(false, "database unreachable: "+err.Error())
+		default:
+			r.SetDegraded("")
+			r.Set(true, "")
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// unreachableDB database di port yang tidak menerima koneksi
+func unreachableDB(t *testing.T) *sql.DB {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := lis.Addr().String()
+	lis.Close()
+
+	db, err := sql.Open("mysql", "root:root@tcp("+addr+")/datastream?timeout=200ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestWatchDB(t *testing.T) {
+	tests := []struct {
+		name      string
+		buffered  bool
+		wantReady bool
+	}{
+		{"without WAL", false, false},
+		{"with WAL", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewReadiness()
+			r.Set(true, "")
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			r.WatchDB(ctx, unreachableDB(t), 100*time.Millisecond, tt.buffered)
+
+			ready, reason := r.Ready()
+			if ready != tt.wantReady {
+				t.Fatalf("ready %v (%s), want %v", ready, reason, tt.wantReady)
+			}
+			degraded := r.Degraded()
+			if tt.buffered && !strings.Contains(degraded, "database unreachable") {
+				t.Fatalf("degraded %q, want database unreachable", degraded)
+			}
+			if !tt.buffered && degraded != "" {
+				t.Fatalf("degraded %q without WAL", degraded)
+			}
+		})
+	}
+}
+
+func TestShutdownStaysNotReady(t *testing.T) {
+	r := NewReadiness()
+	r.Set(true, "")
+	r.Shutdown()
+	r.Set(true, "")
+	if ready, _ := r.Ready(); ready {
+		t.Fatal("ready again after Shutdown")
+	}
+}
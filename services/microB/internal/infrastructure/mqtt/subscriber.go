@@ -0,0 +1,375 @@
+package mqtt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"google.golang.org/protobuf/proto"
+
+	sensorpb "github.com/thomasdarmawan9/datastream-backend/proto/sensorpb"
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/domain"
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/dto"
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/usecase"
+)
+
+// Config koneksi ke broker MQTT
+type Config struct {
+	BrokerURL string // contoh tcp://mosquitto:1883
+	// ClientID harus tetap antar restart supaya sesi persisten (CleanSession
+	// false) di broker dipakai lagi dan pesan selama MicroB mati tidak hilang
+	ClientID     string
+	Username     string
+	Password     string
+	Topic        string // template, contoh sensors/{id1}/{id2}/{type}
+	QoS          byte
+	CleanSession bool
+}
+
+// Subscriber menerima data sensor dari broker MQTT dan memasukkannya ke
+// pipeline ingest yang sama dengan gRPC, jadi validasi, dedup dan batching
+// sama persis. Payload berupa JSON atau protobuf SensorData.
+//
+// Pesan QoS 1/2 baru di-ack ke broker setelah data ter-commit, masuk WAL atau
+// masuk dead letter. MQTT 3.1.1 mewajibkan ack sesuai urutan terima, jadi ack
+// ditahan sampai semua pesan sebelumnya juga selesai. Kalau satu pesan gagal
+// disimpan (antrian penuh, database mati tanpa dead letter), koneksi diputus
+// dan dibuka lagi supaya broker mengirim ulang semua pesan yang belum di-ack;
+// data yang ternyata sudah tersimpan dibuang dedup.
+//
+// Callback paho hanya mencatat pesan lalu kembali; Enqueue yang bisa menunggu
+// antrian penuh jalan di goroutine sendiri supaya router paho (dan keepalive)
+// tidak ikut tertahan.
+type Subscriber struct {
+	cfg      Config
+	scheme   *TopicScheme
+	pipeline usecase.IngestPipeline
+	client   paho.Client
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu        sync.Mutex
+	pending   []*inflight    // urut sesuai diterima, belum di-ack
+	next      int            // index pending berikutnya untuk worker
+	acks      []paho.Message // siap di-ack, urut sesuai diterima
+	session   uint64         // naik setiap koneksi baru, ack sesi lama dibuang
+	wake      chan struct{}
+	ackWake   chan struct{}
+	closing   bool
+	reconnect bool   // ada pesan gagal, acker akan connect ulang
+	redelivs  uint64 // berapa kali koneksi diputus supaya broker mengirim ulang
+}
+
+type inflightState int
+
+const (
+	stateWaiting   inflightState = iota
+	stateAck                     // selesai, boleh di-ack
+	stateRedeliver               // gagal, minta broker kirim ulang
+	stateHold                    // pipeline ditutup, biarkan tanpa ack
+)
+
+type inflight struct {
+	msg     paho.Message
+	session uint64
+	state   inflightState
+}
+
+func NewSubscriber(cfg Config, pipeline usecase.IngestPipeline) (*Subscriber, error) {
+	if cfg.QoS > 2 {
+		return nil, fmt.Errorf("invalid QoS %d", cfg.QoS)
+	}
+	scheme, err := ParseTopicScheme(cfg.Topic)
+	if err != nil {
+		return nil, err
+	}
+	s := &Subscriber{
+		cfg:      cfg,
+		scheme:   scheme,
+		pipeline: pipeline,
+		wake:     make(chan struct{}, 1),
+		ackWake:  make(chan struct{}, 1),
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+
+	opts := paho.NewClientOptions().
+		AddBroker(cfg.BrokerURL).
+		SetClientID(cfg.ClientID).
+		SetUsername(cfg.Username).
+		SetPassword(cfg.Password).
+		SetCleanSession(cfg.CleanSession).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetMaxReconnectInterval(30 * time.Second).
+		// ack manual setelah commit, lihat handle
+		SetAutoAckDisabled(true).
+		// pesan dari sesi persisten bisa datang sebelum SUBSCRIBE selesai
+		SetDefaultPublishHandler(s.handle).
+		SetOnConnectHandler(s.onConnect).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			log.Printf("MQTT connection lost: %v", err)
+			s.resetSession()
+		})
+	s.client = paho.NewClient(opts)
+	return s, nil
+}
+
+// Start mulai connect di background; kalau broker belum bisa dihubungi
+// client terus mencoba
+func (s *Subscriber) Start() {
+	log.Printf("MQTT subscriber connecting to %s (topic %s, QoS %d)", s.cfg.BrokerURL, s.scheme.Filter(), s.cfg.QoS)
+	s.wg.Add(2)
+	go s.worker()
+	go s.acker()
+	s.client.Connect()
+}
+
+// Connected true selama koneksi ke broker terbuka
+func (s *Subscriber) Connected() bool {
+	return s.client.IsConnectionOpen()
+}
+
+// Close mengirim ack yang sudah siap lalu memutus koneksi; pesan yang belum
+// di-ack tetap di sesi persisten broker
+func (s *Subscriber) Close() {
+	s.mu.Lock()
+	s.closing = true
+	s.mu.Unlock()
+	s.cancel()
+	s.wg.Wait()
+	s.client.Disconnect(250)
+}
+
+func (s *Subscriber) onConnect(c paho.Client) {
+	filter := s.scheme.Filter()
+	token := c.Subscribe(filter, s.cfg.QoS, s.handle)
+	go func() {
+		token.Wait()
+		if err := token.Error(); err != nil {
+			log.Printf("MQTT subscribe %s failed: %v", filter, err)
+			return
+		}
+		log.Printf("MQTT subscribed to %s", filter)
+	}()
+}
+
+// resetSession membuang pesan dari koneksi yang sudah putus: ack-nya tidak
+// bisa dikirim lagi dan broker akan mengirim ulang semuanya
+func (s *Subscriber) resetSession() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.session++
+	s.pending, s.next, s.acks = nil, 0, nil
+	s.reconnect = false
+}
+
+// handle callback paho, tidak boleh menunggu apa pun. Jumlah pesan yang
+// tertahan dibatasi inflight window broker.
+func (s *Subscriber) handle(_ paho.Client, msg paho.Message) {
+	s.mu.Lock()
+	s.pending = append(s.pending, &inflight{msg: msg, session: s.session})
+	s.mu.Unlock()
+	notify(s.wake)
+}
+
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// worker memproses pesan satu per satu sesuai urutan terima
+func (s *Subscriber) worker() {
+	defer s.wg.Done()
+	for {
+		s.mu.Lock()
+		var m *inflight
+		if s.next < len(s.pending) {
+			m = s.pending[s.next]
+			s.next++
+		}
+		s.mu.Unlock()
+
+		if m != nil {
+			s.process(m)
+			continue
+		}
+		select {
+		case <-s.wake:
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *Subscriber) process(m *inflight) {
+	msg := m.msg
+	data, err := s.decode(msg.Topic(), msg.Payload())
+	if err != nil {
+		// payload rusak tidak akan jadi benar kalau dikirim ulang
+		log.Printf("MQTT dropping message on %s: %v", msg.Topic(), err)
+		s.finish(m, stateAck)
+		return
+	}
+
+	durable := s.pipeline.Durable()
+	err = s.pipeline.Enqueue(s.ctx, usecase.IngestRecord{
+		Data: data,
+		Done: func(err error) {
+			if durable {
+				return // sudah di-ack saat masuk WAL
+			}
+			switch {
+			case err == nil:
+				s.finish(m, stateAck)
+			case errors.Is(err, usecase.ErrDeadLettered):
+				// aman di dead letter, dikirim ulang pun akan gagal lagi
+				s.finish(m, stateAck)
+			default:
+				log.Printf("MQTT message on %s not stored, asking broker to redeliver: %v", msg.Topic(), err)
+				s.finish(m, stateRedeliver)
+			}
+		},
+	})
+	var verr *usecase.ValidationError
+	switch {
+	case err == nil:
+		if durable {
+			s.finish(m, stateAck)
+		}
+	case errors.Is(err, usecase.ErrDuplicate):
+		s.finish(m, stateAck)
+	case errors.As(err, &verr):
+		log.Printf("MQTT rejected data on %s: %v", msg.Topic(), err)
+		s.finish(m, stateAck)
+	case errors.Is(err, usecase.ErrPipelineClosed), s.ctx.Err() != nil:
+		// sedang shutdown, broker mengirim ulang setelah restart
+		s.finish(m, stateHold)
+	default:
+		// antrian penuh atau WAL gagal ditulis
+		log.Printf("MQTT message on %s not queued, asking broker to redeliver: %v", msg.Topic(), err)
+		s.finish(m, stateRedeliver)
+	}
+}
+
+// finish mencatat hasil satu pesan lalu meneruskan pesan di depan antrian
+// yang sudah selesai ke acker, sesuai urutan terima
+func (s *Subscriber) finish(m *inflight, state inflightState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if m.session != s.session {
+		return
+	}
+	m.state = state
+
+	for len(s.pending) > 0 {
+		head := s.pending[0]
+		switch head.state {
+		case stateWaiting, stateHold:
+			return
+		case stateRedeliver:
+			// pesan sesudahnya juga tidak di-ack sampai koneksi diganti
+			if !s.closing && !s.reconnect {
+				s.redelivs++
+				s.reconnect = true
+				notify(s.ackWake)
+			}
+			return
+		}
+		s.acks = append(s.acks, head.msg)
+		s.pending[0] = nil
+		s.pending = s.pending[1:]
+		s.next--
+		notify(s.ackWake)
+	}
+}
+
+// acker satu-satunya yang memanggil msg.Ack, jadi urutan ack sama dengan
+// urutan s.acks. Ack bisa menunggu koneksi, jadi tidak dipanggil dengan mu
+// terkunci. Reconnect juga dilakukan di sini, setelah ack yang sudah siap
+// terkirim.
+func (s *Subscriber) acker() {
+	defer s.wg.Done()
+	for {
+		s.mu.Lock()
+		acks, reconnect := s.acks, s.reconnect && !s.closing
+		s.acks = nil
+		s.mu.Unlock()
+
+		for _, msg := range acks {
+			msg.Ack()
+		}
+		if reconnect {
+			// broker mengirim ulang semua pesan yang belum di-ack
+			log.Printf("MQTT reconnecting so the broker redelivers unacked messages")
+			s.client.Disconnect(250)
+			s.resetSession()
+			s.client.Connect()
+		}
+		if len(acks) > 0 || reconnect {
+			continue
+		}
+		select {
+		case <-s.ackWake:
+		case <-s.ctx.Done():
+			// kirim ack terakhir sebelum koneksi ditutup
+			s.mu.Lock()
+			acks, s.acks = s.acks, nil
+			s.mu.Unlock()
+			for _, msg := range acks {
+				msg.Ack()
+			}
+			return
+		}
+	}
+}
+
+// decode membaca payload JSON (diawali '{') atau protobuf SensorData; id1,
+// id2 dan sensor type dari topic menimpa nilai di payload
+func (s *Subscriber) decode(topic string, payload []byte) (*domain.SensorData, error) {
+	fields, err := s.scheme.Parse(topic)
+	if err != nil {
+		return nil, err
+	}
+
+	pb := &sensorpb.SensorData{}
+	if trimmed := bytes.TrimSpace(payload); len(trimmed) > 0 && trimmed[0] == '{' {
+		var p sensorpb.JSONPayload
+		if err := json.Unmarshal(trimmed, &p); err != nil {
+			return nil, fmt.Errorf("invalid JSON payload: %w", err)
+		}
+		pb = p.Proto()
+	} else if err := proto.Unmarshal(payload, pb); err != nil {
+		return nil, fmt.Errorf("payload is neither JSON nor protobuf SensorData: %w", err)
+	}
+
+	if fields.ID1 != "" {
+		pb.Id1 = fields.ID1
+	}
+	if fields.HasID2 {
+		pb.Id2 = int32(fields.ID2)
+	}
+	if fields.SensorType != "" {
+		pb.SensorType = fields.SensorType
+	}
+	return dto.SensorDataFromProto(pb, ingestedBy(topic)), nil
+}
+
+// ingestedBy topic asal data, karena pengirim diautentikasi oleh broker;
+// dipotong sesuai panjang kolom ingested_by
+func ingestedBy(topic string) string {
+	id := "mqtt:" + topic
+	if len(id) > 96 {
+		id = id[:96]
+	}
+	return id
+}
@@ -0,0 +1,283 @@
+package mqtt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/domain"
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/usecase"
+)
+
+const testClientID = "microb-test"
+
+// ackHook mencatat publish ke subscriber dan PUBACK dari subscriber, dalam
+// payload seq supaya urutannya mudah dibandingkan
+type ackHook struct {
+	mochi.HookBase
+	mu    sync.Mutex
+	sent  map[uint16]uint64
+	acked []uint64
+}
+
+func (h *ackHook) ID() string { return "acks" }
+
+func (h *ackHook) Provides(b byte) bool {
+	return b == mochi.OnPacketSent || b == mochi.OnQosComplete
+}
+
+func (h *ackHook) OnPacketSent(cl *mochi.Client, pk packets.Packet, _ []byte) {
+	if cl.ID != testClientID || pk.FixedHeader.Type != packets.Publish {
+		return
+	}
+	var seq uint64
+	fmt.Sscanf(string(pk.Payload), `{"seq":%d`, &seq)
+	h.mu.Lock()
+	h.sent[pk.PacketID] = seq
+	h.mu.Unlock()
+}
+
+func (h *ackHook) OnQosComplete(cl *mochi.Client, pk packets.Packet) {
+	if cl.ID != testClientID {
+		return
+	}
+	h.mu.Lock()
+	h.acked = append(h.acked, h.sent[pk.PacketID])
+	h.mu.Unlock()
+}
+
+func (h *ackHook) Acked() []uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return slices.Clone(h.acked)
+}
+
+// fakePipeline mencatat record yang masuk; enqueue boleh diganti per test
+type fakePipeline struct {
+	durable bool
+	enqueue func(n int, rec usecase.IngestRecord) error
+
+	mu      sync.Mutex
+	records []usecase.IngestRecord
+}
+
+func (p *fakePipeline) Enqueue(_ context.Context, rec usecase.IngestRecord) error {
+	p.mu.Lock()
+	n := len(p.records)
+	p.records = append(p.records, rec)
+	p.mu.Unlock()
+	if p.enqueue != nil {
+		return p.enqueue(n, rec)
+	}
+	return nil
+}
+
+func (p *fakePipeline) IngestBatch([]*domain.SensorData) ([]error, domain.BatchResult, error) {
+	return nil, domain.BatchResult{}, nil
+}
+func (p *fakePipeline) Durable() bool                          { return p.durable }
+func (p *fakePipeline) ReplayWAL(context.Context) (int, error) { return 0, nil }
+func (p *fakePipeline) Stats() usecase.IngestStats             { return usecase.IngestStats{} }
+func (p *fakePipeline) Close()                                 {}
+
+func (p *fakePipeline) Records() []usecase.IngestRecord {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.Clone(p.records)
+}
+
+func startBroker(t *testing.T) (*mochi.Server, *ackHook, string) {
+	t.Helper()
+	srv := mochi.New(&mochi.Options{
+		InlineClient: true,
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	if err := srv.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatal(err)
+	}
+	hook := &ackHook{sent: map[uint16]uint64{}}
+	if err := srv.AddHook(hook, nil); err != nil {
+		t.Fatal(err)
+	}
+	l := listeners.NewTCP(listeners.Config{ID: "tcp", Address: "127.0.0.1:0"})
+	if err := srv.AddListener(l); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Serve(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	return srv, hook, "tcp://" + l.Address()
+}
+
+func startSubscriber(t *testing.T, broker string, pipeline usecase.IngestPipeline) *Subscriber {
+	t.Helper()
+	s, err := NewSubscriber(Config{
+		BrokerURL: broker,
+		ClientID:  testClientID,
+		Topic:     "sensors/{id1}/{id2}/{type}",
+		QoS:       1,
+	}, pipeline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Start()
+	t.Cleanup(s.Close)
+	eventually(t, "connected", s.Connected)
+	// SUBACK belum tentu diterima walau koneksi sudah terbuka
+	time.Sleep(100 * time.Millisecond)
+	return s
+}
+
+func publish(t *testing.T, srv *mochi.Server, seqs ...uint64) {
+	t.Helper()
+	for _, seq := range seqs {
+		payload := fmt.Sprintf(`{"seq":%d,"producer_id":"gw-1","sensor_value":1.5,"timestamp":"2026-01-02T03:04:05Z"}`, seq)
+		if err := srv.Publish("sensors/A/1/temperature", []byte(payload), false, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func seqOf(rec usecase.IngestRecord) uint64 {
+	return *rec.Data.Seq
+}
+
+func TestSubscriberAcksInReceiveOrder(t *testing.T) {
+	srv, hook, broker := startBroker(t)
+	pipeline := &fakePipeline{}
+	startSubscriber(t, broker, pipeline)
+
+	publish(t, srv, 1, 2, 3)
+	eventually(t, "3 records enqueued", func() bool { return len(pipeline.Records()) == 3 })
+	recs := pipeline.Records()
+
+	// writer paralel menyelesaikan batch tidak berurutan
+	recs[2].Done(nil)
+	recs[1].Done(fmt.Errorf("%w: db down", usecase.ErrDeadLettered))
+	time.Sleep(200 * time.Millisecond)
+	if acked := hook.Acked(); len(acked) != 0 {
+		t.Fatalf("acked %v before the first message was stored", acked)
+	}
+
+	recs[0].Done(nil)
+	eventually(t, "3 acks", func() bool { return len(hook.Acked()) == 3 })
+	if got, want := hook.Acked(), []uint64{1, 2, 3}; !slices.Equal(got, want) {
+		t.Fatalf("ack order %v, want %v", got, want)
+	}
+}
+
+func TestSubscriberAcksRejectedAndDuplicates(t *testing.T) {
+	srv, hook, broker := startBroker(t)
+	pipeline := &fakePipeline{enqueue: func(n int, rec usecase.IngestRecord) error {
+		if n == 0 {
+			return &usecase.ValidationError{Reason: "value_out_of_range", Message: "too hot"}
+		}
+		return usecase.ErrDuplicate
+	}}
+	startSubscriber(t, broker, pipeline)
+
+	publish(t, srv, 1, 2)
+	eventually(t, "2 acks", func() bool { return len(hook.Acked()) == 2 })
+}
+
+func TestSubscriberRedeliversFailedMessages(t *testing.T) {
+	srv, hook, broker := startBroker(t)
+	pipeline := &fakePipeline{}
+	s := startSubscriber(t, broker, pipeline)
+
+	publish(t, srv, 1, 2)
+	eventually(t, "2 records enqueued", func() bool { return len(pipeline.Records()) == 2 })
+	recs := pipeline.Records()
+	recs[1].Done(nil)
+	// database mati dan tidak ada dead letter
+	recs[0].Done(errors.New("db down"))
+
+	// broker mengirim ulang keduanya setelah reconnect
+	eventually(t, "redelivery", func() bool { return len(pipeline.Records()) == 4 })
+	recs = pipeline.Records()
+	// urutan kirim ulang tergantung broker
+	if got := []uint64{seqOf(recs[2]), seqOf(recs[3])}; !slices.Contains(got, 1) || !slices.Contains(got, 2) {
+		t.Fatalf("redelivered %v, want [1 2]", got)
+	}
+	if len(hook.Acked()) != 0 {
+		t.Fatalf("acked %v before redelivery was stored", hook.Acked())
+	}
+	recs[2].Done(nil)
+	recs[3].Done(nil)
+	eventually(t, "2 acks", func() bool { return len(hook.Acked()) == 2 })
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.redelivs != 1 {
+		t.Fatalf("redeliveries %d, want 1", s.redelivs)
+	}
+}
+
+func TestSubscriberRedeliversWhenQueueFull(t *testing.T) {
+	srv, hook, broker := startBroker(t)
+	pipeline := &fakePipeline{durable: true, enqueue: func(n int, _ usecase.IngestRecord) error {
+		if n == 0 {
+			return usecase.ErrQueueFull
+		}
+		return nil
+	}}
+	startSubscriber(t, broker, pipeline)
+
+	publish(t, srv, 1)
+	eventually(t, "ack after redelivery", func() bool { return len(hook.Acked()) == 1 })
+	if n := len(pipeline.Records()); n != 2 {
+		t.Fatalf("enqueued %d times, want 2", n)
+	}
+}
+
+func TestSubscriberCallbackDoesNotBlock(t *testing.T) {
+	srv, hook, broker := startBroker(t)
+	release := make(chan struct{})
+	pipeline := &fakePipeline{durable: true, enqueue: func(n int, _ usecase.IngestRecord) error {
+		if n == 0 {
+			<-release // antrian penuh, producer ditahan
+		}
+		return nil
+	}}
+	s := startSubscriber(t, broker, pipeline)
+
+	publish(t, srv, 1, 2, 3)
+	// pesan berikutnya tetap diterima walau Enqueue pertama masih menunggu
+	eventually(t, "3 messages received", func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return len(s.pending) == 3
+	})
+	if !s.Connected() {
+		t.Fatal("connection dropped while Enqueue was blocked")
+	}
+
+	close(release)
+	eventually(t, "3 acks", func() bool { return len(hook.Acked()) == 3 })
+	if got, want := hook.Acked(), []uint64{1, 2, 3}; !slices.Equal(got, want) {
+		t.Fatalf("ack order %v, want %v", got, want)
+	}
+}
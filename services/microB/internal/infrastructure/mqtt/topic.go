@@ -0,0 +1,85 @@
+package mqtt
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// placeholder yang boleh dipakai di template topic
+const (
+	fieldID1  = "{id1}"
+	fieldID2  = "{id2}"
+	fieldType = "{type}"
+)
+
+// TopicScheme template topic seperti "sensors/{id1}/{id2}/{type}". Tiap
+// placeholder mengisi satu level topic; level lain harus sama persis.
+type TopicScheme struct {
+	levels []string
+}
+
+func ParseTopicScheme(template string) (*TopicScheme, error) {
+	levels := strings.Split(template, "/")
+	seen := map[string]bool{}
+	for _, level := range levels {
+		switch {
+		case level == fieldID1 || level == fieldID2 || level == fieldType:
+			if seen[level] {
+				return nil, fmt.Errorf("topic template %q uses %s twice", template, level)
+			}
+			seen[level] = true
+		case level == "" || strings.ContainsAny(level, "+#{}"):
+			return nil, fmt.Errorf("invalid topic level %q in %q", level, template)
+		}
+	}
+	return &TopicScheme{levels: levels}, nil
+}
+
+// Filter topic filter untuk SUBSCRIBE, placeholder diganti wildcard "+"
+func (t *TopicScheme) Filter() string {
+	levels := make([]string, len(t.levels))
+	for i, level := range t.levels {
+		if strings.HasPrefix(level, "{") {
+			level = "+"
+		}
+		levels[i] = level
+	}
+	return strings.Join(levels, "/")
+}
+
+// topicFields nilai yang diambil dari topic; field yang tidak ada di
+// template dibiarkan kosong dan diambil dari payload
+type topicFields struct {
+	ID1        string
+	ID2        int
+	HasID2     bool
+	SensorType string
+}
+
+func (t *TopicScheme) Parse(topic string) (topicFields, error) {
+	var f topicFields
+	levels := strings.Split(topic, "/")
+	if len(levels) != len(t.levels) {
+		return f, fmt.Errorf("topic %q does not match %s", topic, strings.Join(t.levels, "/"))
+	}
+	for i, level := range t.levels {
+		switch level {
+		case fieldID1:
+			f.ID1 = levels[i]
+		case fieldID2:
+			id2, err := strconv.Atoi(levels[i])
+			if err != nil {
+				return f, fmt.Errorf("invalid id2 %q in topic %q", levels[i], topic)
+			}
+			f.ID2, f.HasID2 = id2, true
+		case fieldType:
+			f.SensorType = levels[i]
+		default:
+			if levels[i] != level {
+				return f, fmt.Errorf("topic %q does not match %s", topic, strings.Join(t.levels, "/"))
+			}
+		}
+	}
+	return f, nil
+}
@@ -0,0 +1,273 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// PartitionInterval ukuran satu partisi sensor_data
+type PartitionInterval string
+
+const (
+	PartitionDaily   PartitionInterval = "day"
+	PartitionMonthly PartitionInterval = "month"
+)
+
+const (
+	// partisi penampung sisa data di atas partisi terakhir, supaya insert
+	// tidak gagal kalau partisi ke depan belum sempat dibuat
+	partitionMax = "pmax"
+	// partisi penampung data lama saat tabel yang sudah berisi dipartisi
+	partitionHistory = "phistory"
+	// format batas partisi di PARTITION_DESCRIPTION (UTC)
+	partitionBoundLayout = "2006-01-02 15:04:05"
+)
+
+// PartitionConfig pengaturan partisi RANGE COLUMNS(ts) di sensor_data
+type PartitionConfig struct {
+	Interval PartitionInterval
+	// Premake jumlah partisi setelah periode sekarang yang dibuat lebih dulu
+	Premake int
+	// Retention umur data; partisi yang seluruh isinya lebih tua dari ini
+	// di-drop. 0 artinya data tidak pernah dihapus.
+	Retention time.Duration
+	// CheckInterval jarak antar pengecekan di Run
+	CheckInterval time.Duration
+}
+
+// PartitionManager membagi sensor_data per hari atau per bulan berdasarkan
+// ts. Query dengan filter ts hanya membaca partisi yang relevan (partition
+// pruning), dan data kedaluwarsa dihapus dengan DROP PARTITION yang jauh
+// lebih ringan dari DELETE.
+type PartitionManager struct {
+	db  *sql.DB
+	cfg PartitionConfig
+}
+
+func NewPartitionManager(db *sql.DB, cfg PartitionConfig) (*PartitionManager, error) {
+	if cfg.Interval != PartitionDaily && cfg.Interval != PartitionMonthly {
+		return nil, fmt.Errorf("unknown partition interval %q (day or month)", cfg.Interval)
+	}
+	if cfg.Premake < 1 {
+		cfg.Premake = 1
+	}
+	if cfg.CheckInterval <= 0 {
+		cfg.CheckInterval = time.Hour
+	}
+	return &PartitionManager{db: db, cfg: cfg}, nil
+}
+
+// Run menjalankan Ensure berkala sampai ctx selesai
+func (m *PartitionManager) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.cfg.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := m.Ensure(); err != nil {
+				log.Printf("Partition maintenance failed: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+type partition struct {
+	name  string
+	bound time.Time // VALUES LESS THAN, zero untuk MAXVALUE
+}
+
+// Ensure mempartisi sensor_data kalau belum, membuat partisi untuk periode
+// sekarang sampai Premake periode ke depan, lalu men-drop partisi yang sudah
+// melewati Retention. Aman dipanggil berulang.
+func (m *PartitionManager) Ensure() error {
+	parts, err := m.partitions()
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	if len(parts) == 0 {
+		return m.partitionTable(now)
+	}
+	if err := m.createAhead(parts, now); err != nil {
+		return err
+	}
+	if m.cfg.Retention > 0 {
+		// daftar dibaca ulang karena createAhead bisa menambah partisi
+		if parts, err = m.partitions(); err != nil {
+			return err
+		}
+		return m.dropExpired(parts, now.Add(-m.cfg.Retention))
+	}
+	return nil
+}
+
+// partitions daftar partisi urut dari yang paling lama; kosong kalau tabel
+// belum dipartisi
+func (m *PartitionManager) partitions() ([]partition, error) {
+	rows, err := m.db.Query(`SELECT PARTITION_NAME, PARTITION_DESCRIPTION FROM information_schema.PARTITIONS
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'sensor_data' AND PARTITION_NAME IS NOT NULL
+		ORDER BY PARTITION_ORDINAL_POSITION`)
+	if err != nil {
+		return nil, fmt.Errorf("list partitions: %w", err)
+	}
+	defer rows.Close()
+
+	var parts []partition
+	for rows.Next() {
+		var p partition
+		var desc string
+		if err := rows.Scan(&p.name, &desc); err != nil {
+			return nil, err
+		}
+		if desc != "MAXVALUE" {
+			if p.bound, err = time.Parse(partitionBoundLayout, strings.Trim(desc, "'")); err != nil {
+				return nil, fmt.Errorf("partition %s: unexpected bound %q", p.name, desc)
+			}
+		}
+		parts = append(parts, p)
+	}
+	return parts, rows.Err()
+}
+
+// partitionTable mengubah sensor_data jadi tabel berpartisi. MySQL meminta
+// ts ada di semua unique key, jadi primary key jadi (id, ts) dan dedup key
+// jadi (producer_id, seq, ts); data yang dikirim ulang membawa ts yang sama
+// sehingga tetap terdeteksi duplikat. Data lama masuk partisi phistory.
+// Tabel yang besar di-copy oleh MySQL, jadi bisa makan waktu lama.
+func (m *PartitionManager) partitionTable(now time.Time) error {
+	start := m.periodStart(now)
+	defs := []string{fmt.Sprintf("PARTITION %s VALUES LESS THAN ('%s')", partitionHistory, start.Format(partitionBoundLayout))}
+	defs = append(defs, m.definitions(start, m.aheadUntil(now))...)
+	defs = append(defs, fmt.Sprintf("PARTITION %s VALUES LESS THAN (MAXVALUE)", partitionMax))
+
+	// tabel baru sudah dibuat AutoMigrate dengan key yang benar, tabel dari
+	// versi lama perlu diubah dulu
+	var keysWithTS int
+	err := m.db.QueryRow(`SELECT COUNT(*) FROM information_schema.STATISTICS
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'sensor_data'
+		AND INDEX_NAME IN ('PRIMARY', 'idx_producer_seq') AND COLUMN_NAME = 'ts'`).Scan(&keysWithTS)
+	if err != nil {
+		return fmt.Errorf("read sensor_data keys: %w", err)
+	}
+	query := "ALTER TABLE sensor_data "
+	if keysWithTS < 2 {
+		query += `DROP PRIMARY KEY, ADD PRIMARY KEY (id, ts),
+		DROP INDEX idx_producer_seq, ADD UNIQUE INDEX idx_producer_seq (producer_id, seq, ts) `
+	}
+	query += "PARTITION BY RANGE COLUMNS(ts) (" + strings.Join(defs, ", ") + ")"
+
+	log.Printf("Partitioning sensor_data by %s (%d partitions), this may take a while on a large table", m.cfg.Interval, len(defs))
+	if _, err := m.db.Exec(query); err != nil {
+		return fmt.Errorf("partition sensor_data: %w", err)
+	}
+	return nil
+}
+
+// createAhead menambah partisi dari batas partisi terakhir sampai Premake
+// periode setelah periode sekarang, dengan memecah pmax
+func (m *PartitionManager) createAhead(parts []partition, now time.Time) error {
+	last, hasMax := parts[len(parts)-1], false
+	if last.bound.IsZero() {
+		hasMax = true
+		if len(parts) == 1 {
+			return fmt.Errorf("sensor_data has only partition %s", last.name)
+		}
+		last = parts[len(parts)-2]
+	}
+	until := m.aheadUntil(now)
+	defs := m.definitions(last.bound, until)
+	if len(defs) == 0 {
+		return nil
+	}
+	created := len(defs)
+
+	var err error
+	if hasMax {
+		// pmax biasanya kosong, jadi REORGANIZE hanya mengubah metadata
+		defs = append(defs, fmt.Sprintf("PARTITION %s VALUES LESS THAN (MAXVALUE)", partitionMax))
+		_, err = m.db.Exec(fmt.Sprintf("ALTER TABLE sensor_data REORGANIZE PARTITION %s INTO (%s)", partitionMax, strings.Join(defs, ", ")))
+	} else {
+		_, err = m.db.Exec("ALTER TABLE sensor_data ADD PARTITION (" + strings.Join(defs, ", ") + ")")
+	}
+	if err != nil {
+		return fmt.Errorf("create partitions: %w", err)
+	}
+	log.Printf("Created %d sensor_data partitions up to %s", created, until.Format(time.DateOnly))
+	return nil
+}
+
+// dropExpired men-drop partisi yang batas atasnya tidak lebih baru dari cutoff
+func (m *PartitionManager) dropExpired(parts []partition, cutoff time.Time) error {
+	expired := expiredPartitions(parts, cutoff)
+	if len(expired) == 0 {
+		return nil
+	}
+	if _, err := m.db.Exec("ALTER TABLE sensor_data DROP PARTITION " + strings.Join(expired, ", ")); err != nil {
+		return fmt.Errorf("drop expired partitions: %w", err)
+	}
+	log.Printf("Dropped expired sensor_data partitions (data before %s): %s", cutoff.Format(time.DateTime), strings.Join(expired, ", "))
+	return nil
+}
+
+// expiredPartitions nama partisi yang seluruh isinya lebih tua dari cutoff;
+// pmax tidak pernah ikut
+func expiredPartitions(parts []partition, cutoff time.Time) []string {
+	var expired []string
+	for _, p := range parts {
+		if !p.bound.IsZero() && !p.bound.After(cutoff) {
+			expired = append(expired, p.name)
+		}
+	}
+	return expired
+}
+
+// definitions definisi partisi per periode dari from sampai until. Batas
+// pertama dibulatkan ke awal periode berikutnya, jadi kalau interval pernah
+// diganti partisi lama tetap nyambung.
+func (m *PartitionManager) definitions(from, until time.Time) []string {
+	var defs []string
+	for lower := from; lower.Before(until); {
+		upper := m.next(m.periodStart(lower))
+		defs = append(defs, fmt.Sprintf("PARTITION %s VALUES LESS THAN ('%s')", m.name(lower), upper.Format(partitionBoundLayout)))
+		lower = upper
+	}
+	return defs
+}
+
+// aheadUntil batas atas partisi terakhir yang harus sudah ada
+func (m *PartitionManager) aheadUntil(now time.Time) time.Time {
+	t := m.periodStart(now)
+	for range m.cfg.Premake + 1 {
+		t = m.next(t)
+	}
+	return t
+}
+
+func (m *PartitionManager) periodStart(t time.Time) time.Time {
+	t = t.UTC()
+	if m.cfg.Interval == PartitionMonthly {
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func (m *PartitionManager) next(start time.Time) time.Time {
+	if m.cfg.Interval == PartitionMonthly {
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// name nama partisi dari awal periodenya, contoh p20250131 atau p202501
+func (m *PartitionManager) name(lower time.Time) string {
+	if m.cfg.Interval == PartitionMonthly {
+		return lower.Format("p200601")
+	}
+	return lower.Format("p20060102")
+}
@@ -0,0 +1,148 @@
+package mysql
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func testPartitionManager(t *testing.T, interval PartitionInterval, premake int) *PartitionManager {
+	t.Helper()
+	m, err := NewPartitionManager(nil, PartitionConfig{Interval: interval, Premake: premake})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func date(y int, mo time.Month, d int) time.Time {
+	return time.Date(y, mo, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestPartitionPeriods(t *testing.T) {
+	wib := time.FixedZone("WIB", 7*60*60)
+	tests := []struct {
+		name      string
+		interval  PartitionInterval
+		now       time.Time
+		wantStart time.Time
+		wantNext  time.Time
+		wantName  string
+	}{
+		{"daily", PartitionDaily, time.Date(2025, 1, 15, 13, 4, 5, 0, time.UTC), date(2025, 1, 15), date(2025, 1, 16), "p20250115"},
+		{"daily end of month", PartitionDaily, time.Date(2025, 1, 31, 23, 59, 59, 0, time.UTC), date(2025, 1, 31), date(2025, 2, 1), "p20250131"},
+		{"daily leap day", PartitionDaily, date(2024, 2, 28), date(2024, 2, 28), date(2024, 2, 29), "p20240228"},
+		{"daily end of year", PartitionDaily, date(2025, 12, 31), date(2025, 12, 31), date(2026, 1, 1), "p20251231"},
+		{"daily in UTC", PartitionDaily, time.Date(2025, 2, 1, 3, 0, 0, 0, wib), date(2025, 1, 31), date(2025, 2, 1), "p20250131"},
+		{"monthly", PartitionMonthly, time.Date(2025, 1, 15, 13, 4, 5, 0, time.UTC), date(2025, 1, 1), date(2025, 2, 1), "p202501"},
+		{"monthly from the 31st", PartitionMonthly, date(2025, 1, 31), date(2025, 1, 1), date(2025, 2, 1), "p202501"},
+		{"monthly end of year", PartitionMonthly, date(2025, 12, 31), date(2025, 12, 1), date(2026, 1, 1), "p202512"},
+		{"monthly in UTC", PartitionMonthly, time.Date(2025, 2, 1, 3, 0, 0, 0, wib), date(2025, 1, 1), date(2025, 2, 1), "p202501"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := testPartitionManager(t, tt.interval, 1)
+			start := m.periodStart(tt.now)
+			if !start.Equal(tt.wantStart) {
+				t.Errorf("periodStart %v, want %v", start, tt.wantStart)
+			}
+			if next := m.next(start); !next.Equal(tt.wantNext) {
+				t.Errorf("next %v, want %v", next, tt.wantNext)
+			}
+			if name := m.name(start); name != tt.wantName {
+				t.Errorf("name %s, want %s", name, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestPartitionDefinitions(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval PartitionInterval
+		premake  int
+		now      time.Time
+		from     time.Time // batas partisi terakhir yang sudah ada
+		want     []string
+	}{
+		{
+			name: "daily across month", interval: PartitionDaily, premake: 2,
+			now: time.Date(2025, 1, 30, 10, 0, 0, 0, time.UTC), from: date(2025, 1, 30),
+			want: []string{
+				"PARTITION p20250130 VALUES LESS THAN ('2025-01-31 00:00:00')",
+				"PARTITION p20250131 VALUES LESS THAN ('2025-02-01 00:00:00')",
+				"PARTITION p20250201 VALUES LESS THAN ('2025-02-02 00:00:00')",
+			},
+		},
+		{
+			name: "monthly across year", interval: PartitionMonthly, premake: 1,
+			now: date(2025, 12, 15), from: date(2025, 12, 1),
+			want: []string{
+				"PARTITION p202512 VALUES LESS THAN ('2026-01-01 00:00:00')",
+				"PARTITION p202601 VALUES LESS THAN ('2026-02-01 00:00:00')",
+			},
+		},
+		{
+			name: "already ahead", interval: PartitionDaily, premake: 1,
+			now: date(2025, 1, 30), from: date(2025, 2, 1),
+			want: nil,
+		},
+		{
+			// interval diganti dari harian ke bulanan: partisi pertama mulai
+			// dari batas terakhir dan berakhir di awal bulan berikutnya
+			name: "switch from daily to monthly", interval: PartitionMonthly, premake: 1,
+			now: date(2025, 1, 20), from: date(2025, 1, 15),
+			want: []string{
+				"PARTITION p202501 VALUES LESS THAN ('2025-02-01 00:00:00')",
+				"PARTITION p202502 VALUES LESS THAN ('2025-03-01 00:00:00')",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := testPartitionManager(t, tt.interval, tt.premake)
+			got := m.definitions(tt.from, m.aheadUntil(tt.now))
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("definitions\n got %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpiredPartitions(t *testing.T) {
+	parts := []partition{
+		{partitionHistory, date(2025, 1, 1)},
+		{"p20250101", date(2025, 1, 2)},
+		{"p20250102", date(2025, 1, 3)},
+		{"p20250103", date(2025, 1, 4)},
+		{partitionMax, time.Time{}},
+	}
+	tests := []struct {
+		name   string
+		cutoff time.Time
+		want   []string
+	}{
+		{"nothing expired", date(2024, 12, 31), nil},
+		{"only history", date(2025, 1, 1).Add(12 * time.Hour), []string{partitionHistory}},
+		{"bound equal to cutoff", date(2025, 1, 2), []string{partitionHistory, "p20250101"}},
+		{"partition still has newer data", date(2025, 1, 2).Add(-time.Second), []string{partitionHistory}},
+		{"never pmax", date(2030, 1, 1), []string{partitionHistory, "p20250101", "p20250102", "p20250103"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := expiredPartitions(parts, tt.cutoff); !slices.Equal(got, tt.want) {
+				t.Fatalf("expired %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPartitionManager(t *testing.T) {
+	if _, err := NewPartitionManager(nil, PartitionConfig{Interval: "week"}); err == nil {
+		t.Fatal("unknown interval accepted")
+	}
+	m := testPartitionManager(t, PartitionDaily, 0)
+	if m.cfg.Premake != 1 || m.cfg.CheckInterval != time.Hour {
+		t.Fatalf("defaults %+v", m.cfg)
+	}
+}
@@ -0,0 +1,105 @@
+package mysql
+
+import (
+	"database/sql"
+	"errors"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/domain"
+)
+
+type retentionPolicyRepo struct {
+	db *sql.DB
+}
+
+func NewRetentionPolicyRepository(db *sql.DB) domain.RetentionPolicyRepository {
+	return &retentionPolicyRepo{db: db}
+}
+
+const selectRetentionPolicyQuery = `SELECT id, sensor_type, id1_pattern, max_age_seconds, enabled, created_at, updated_at FROM retention_policies`
+
+func (r *retentionPolicyRepo) Create(p *domain.RetentionPolicy) error {
+	query := `INSERT INTO retention_policies (sensor_type, id1_pattern, max_age_seconds, enabled, created_at, updated_at)
+		VALUES (?, ?, ?, ?, NOW(), NOW())`
+	res, err := r.db.Exec(query, p.SensorType, p.ID1Pattern, p.MaxAgeSeconds, p.Enabled)
+	var myErr *mysqldriver.MySQLError
+	if errors.As(err, &myErr) && myErr.Number == 1062 { // ER_DUP_ENTRY
+		return domain.ErrRetentionPolicyExists
+	}
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	p.ID = uint64(id)
+	return nil
+}
+
+func (r *retentionPolicyRepo) Update(p *domain.RetentionPolicy) error {
+	query := `UPDATE retention_policies SET sensor_type = ?, id1_pattern = ?, max_age_seconds = ?, enabled = ?, updated_at = NOW()
+		WHERE id = ?`
+	res, err := r.db.Exec(query, p.SensorType, p.ID1Pattern, p.MaxAgeSeconds, p.Enabled, p.ID)
+	var myErr *mysqldriver.MySQLError
+	if errors.As(err, &myErr) && myErr.Number == 1062 {
+		return domain.ErrRetentionPolicyExists
+	}
+	if err != nil {
+		return err
+	}
+	// affected rows 0 juga terjadi kalau isinya tidak berubah, jadi cek dulu
+	if n, _ := res.RowsAffected(); n == 0 {
+		if _, err := r.FindByID(p.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *retentionPolicyRepo) Delete(id uint64) error {
+	res, err := r.db.Exec(`DELETE FROM retention_policies WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return domain.ErrRetentionPolicyNotFound
+	}
+	return nil
+}
+
+func (r *retentionPolicyRepo) FindByID(id uint64) (*domain.RetentionPolicy, error) {
+	row := r.db.QueryRow(selectRetentionPolicyQuery+` WHERE id = ?`, id)
+	p, err := scanRetentionPolicy(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrRetentionPolicyNotFound
+	}
+	return p, err
+}
+
+func (r *retentionPolicyRepo) FindAll() ([]*domain.RetentionPolicy, error) {
+	rows, err := r.db.Query(selectRetentionPolicyQuery + ` ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*domain.RetentionPolicy
+	for rows.Next() {
+		p, err := scanRetentionPolicy(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, p)
+	}
+	return result, rows.Err()
+}
+
+func scanRetentionPolicy(row interface{ Scan(...any) error }) (*domain.RetentionPolicy, error) {
+	var p domain.RetentionPolicy
+	err := row.Scan(&p.ID, &p.SensorType, &p.ID1Pattern, &p.MaxAgeSeconds, &p.Enabled, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
@@ -0,0 +1,434 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/domain"
+)
+
+// RollupConfig pengaturan tabel rollup sensor_rollup_1m/1h/1d
+type RollupConfig struct {
+	// Retention umur bucket per level, 0 atau tidak diisi artinya disimpan
+	// selamanya. Tidak tergantung retention data mentah, tapi tidak boleh
+	// lebih pendek dari level yang lebih halus karena level kasar dihitung
+	// ulang dari level di bawahnya.
+	Retention map[domain.RollupLevel]time.Duration
+	// Backfill mengisi rollup dari data mentah yang belum masuk rollup
+	// saat Run dimulai
+	Backfill bool
+	// CheckInterval jarak antar penghapusan bucket kedaluwarsa
+	CheckInterval time.Duration
+	// DeleteChunk bucket per DELETE saat retention
+	DeleteChunk int
+}
+
+// RollupManager menjaga tabel rollup per menit, jam dan hari. Batch baru
+// ditambahkan langsung ke bucket-nya di transaksi yang sama dengan insert
+// sensor_data (lihat SensorRepoConfig.Rollups); baris duplikat tidak ikut.
+// Kalau data mentah berubah lewat update/delete, bucket yang terkena
+// dihitung ulang: 1m dari sensor_data, 1h dari 1m, 1d dari 1h.
+type RollupManager struct {
+	db  *sql.DB
+	cfg RollupConfig
+}
+
+func NewRollupManager(db *sql.DB, cfg RollupConfig) (*RollupManager, error) {
+	for i := 1; i < len(domain.RollupLevels); i++ {
+		finer, coarser := domain.RollupLevels[i-1], domain.RollupLevels[i]
+		f, c := cfg.Retention[finer], cfg.Retention[coarser]
+		if c > 0 && (f == 0 || c < f) {
+			return nil, fmt.Errorf("rollup retention %s (%v) must not be shorter than %s (%v)", coarser, c, finer, f)
+		}
+	}
+	if cfg.CheckInterval <= 0 {
+		cfg.CheckInterval = time.Hour
+	}
+	if cfg.DeleteChunk <= 0 {
+		cfg.DeleteChunk = 1000
+	}
+	return &RollupManager{db: db, cfg: cfg}, nil
+}
+
+// RollupTable nama tabel untuk satu level
+func RollupTable(l domain.RollupLevel) string {
+	return "sensor_rollup_" + string(l)
+}
+
+// bucketFormat DATE_FORMAT yang membulatkan waktu ke awal bucket
+var bucketFormat = map[domain.RollupLevel]string{
+	domain.RollupMinute: "%Y-%m-%d %H:%i:00",
+	domain.RollupHour:   "%Y-%m-%d %H:00:00",
+	domain.RollupDay:    "%Y-%m-%d 00:00:00",
+}
+
+const (
+	rollupColumns     = "id1, id2, sensor_type, bucket, samples, min_value, max_value, sum_value, sum_sq, first_ts, first_val, last_ts, last_val"
+	rollupColumnCount = 13
+	rollupChunk       = 1000
+)
+
+// rollupUpsert ON DUPLICATE KEY UPDATE untuk INSERT ke table: bucket yang
+// sudah ada digabung dengan agregat baru (alias new). Kolom lama ditulis
+// lengkap dengan nama tabel supaya tidak ambigu dengan kolom new. Urutan
+// assignment penting: first_val/last_val dibandingkan dengan ts lama sebelum
+// ts-nya ikut di-update.
+func rollupUpsert(table string) string {
+	return strings.NewReplacer("{t}", table).Replace(` AS new ON DUPLICATE KEY UPDATE
+		samples = {t}.samples + new.samples,
+		min_value = LEAST({t}.min_value, new.min_value),
+		max_value = GREATEST({t}.max_value, new.max_value),
+		sum_value = {t}.sum_value + new.sum_value,
+		sum_sq = {t}.sum_sq + new.sum_sq,
+		first_val = IF(new.first_ts < {t}.first_ts, new.first_val, {t}.first_val),
+		first_ts = LEAST({t}.first_ts, new.first_ts),
+		last_val = IF(new.last_ts >= {t}.last_ts, new.last_val, {t}.last_val),
+		last_ts = GREATEST({t}.last_ts, new.last_ts)`)
+}
+
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+type rollupKey struct {
+	id1        string
+	id2        int
+	sensorType string
+	bucket     time.Time
+}
+
+// add menambahkan data yang baru di-insert ke semua level
+func (m *RollupManager) add(tx execer, sensors []*domain.SensorData) error {
+	if len(sensors) == 0 {
+		return nil
+	}
+	for _, l := range domain.RollupLevels {
+		buckets := map[rollupKey]*domain.SensorRollup{}
+		for _, s := range sensors {
+			k := rollupKey{s.ID1, s.ID2, s.SensorType, l.Bucket(s.TS)}
+			b, ok := buckets[k]
+			if !ok {
+				b = &domain.SensorRollup{ID1: k.id1, ID2: k.id2, SensorType: k.sensorType, Bucket: k.bucket}
+				buckets[k] = b
+			}
+			b.Add(s.SensorValue, s.TS)
+		}
+		// urutan tetap supaya worker ingest yang paralel mengunci baris
+		// rollup dengan urutan yang sama dan tidak saling deadlock
+		rows := make([]*domain.SensorRollup, 0, len(buckets))
+		for _, b := range buckets {
+			rows = append(rows, b)
+		}
+		slices.SortFunc(rows, compareRollup)
+
+		for start := 0; start < len(rows); start += rollupChunk {
+			chunk := rows[start:min(start+rollupChunk, len(rows))]
+			var sb strings.Builder
+			sb.WriteString("INSERT INTO " + RollupTable(l) + " (" + rollupColumns + ") VALUES ")
+			args := make([]any, 0, len(chunk)*rollupColumnCount)
+			for i, r := range chunk {
+				if i > 0 {
+					sb.WriteByte(',')
+				}
+				sb.WriteString("(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+				args = append(args, r.ID1, r.ID2, r.SensorType, r.Bucket, r.Samples, r.MinValue, r.MaxValue,
+					r.SumValue, r.SumSq, r.FirstTS, r.FirstVal, r.LastTS, r.LastVal)
+			}
+			sb.WriteString(rollupUpsert(RollupTable(l)))
+			if _, err := tx.Exec(sb.String(), args...); err != nil {
+				return fmt.Errorf("update %s: %w", RollupTable(l), err)
+			}
+		}
+	}
+	return nil
+}
+
+func compareRollup(a, b *domain.SensorRollup) int {
+	if c := strings.Compare(a.ID1, b.ID1); c != 0 {
+		return c
+	}
+	if a.ID2 != b.ID2 {
+		return a.ID2 - b.ID2
+	}
+	if c := strings.Compare(a.SensorType, b.SensorType); c != 0 {
+		return c
+	}
+	return a.Bucket.Compare(b.Bucket)
+}
+
+// rollupRange rentang ts satu series yang bucket-nya perlu dihitung ulang
+type rollupRange struct {
+	id1        string
+	id2        int
+	sensorType string
+	from, to   time.Time // ts paling awal dan paling akhir, inklusif
+}
+
+// queryRanges rentang per series dari data sensor_data yang cocok dengan where
+func queryRanges(q querier, where string, args []any) ([]rollupRange, error) {
+	rows, err := q.Query("SELECT id1, id2, sensor_type, MIN(ts), MAX(ts) FROM sensor_data WHERE "+where+
+		" GROUP BY id1, id2, sensor_type", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ranges []rollupRange
+	for rows.Next() {
+		var r rollupRange
+		if err := rows.Scan(&r.id1, &r.id2, &r.sensorType, &r.from, &r.to); err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, rows.Err()
+}
+
+// recompute menghitung ulang bucket yang mencakup ranges di semua level
+func (m *RollupManager) recompute(tx execer, ranges []rollupRange) error {
+	now := time.Now().UTC()
+	for _, r := range ranges {
+		for i, l := range domain.RollupLevels {
+			lo, hi := l.Bucket(r.from), l.Bucket(r.to).Add(l.Duration())
+			// bucket yang sudah lewat retention level ini tidak dibuat lagi
+			if cutoff := m.cutoff(l, now); lo.Before(cutoff) {
+				lo = cutoff
+			}
+			if !lo.Before(hi) {
+				continue
+			}
+			err := m.rebuild(tx, i, "id1 = ? AND id2 = ? AND sensor_type = ?", []any{r.id1, r.id2, r.sensorType}, lo, hi, now)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// rebuild mengganti bucket level ke-i di [lo, hi) yang cocok dengan cond.
+// Sumbernya level di bawahnya, atau sensor_data kalau level itu sudah
+// terhapus retention di rentang ini.
+func (m *RollupManager) rebuild(tx execer, i int, cond string, args []any, lo, hi, now time.Time) error {
+	l := domain.RollupLevels[i]
+	table := RollupTable(l)
+	args = append(slices.Clip(args), lo, hi)
+	if _, err := tx.Exec("DELETE FROM "+table+" WHERE "+cond+" AND bucket >= ? AND bucket < ?", args...); err != nil {
+		return fmt.Errorf("rebuild %s: %w", table, err)
+	}
+
+	source := rawSource
+	if i > 0 && m.covers(domain.RollupLevels[i-1], lo, now) {
+		source = domain.RollupLevels[i-1]
+	}
+	bucketExpr := func(col string) string { return "DATE_FORMAT(" + col + ", '" + bucketFormat[l] + "')" }
+	query := "INSERT INTO " + table + " (" + rollupColumns + ") " + aggregateSelect(source, bucketExpr, cond)
+	if _, err := tx.Exec(query, args...); err != nil {
+		return fmt.Errorf("rebuild %s: %w", table, err)
+	}
+	return nil
+}
+
+// rawSource sumber aggregateSelect untuk sensor_data
+const rawSource domain.RollupLevel = ""
+
+// aggregateSelect query agregat per series per bucket dari sensor_data
+// (source rawSource) atau tabel rollup level source, untuk data yang cocok
+// dengan cond dan waktunya di [?, ?) setelah argumen cond. bucketExpr
+// mengubah kolom waktu jadi kolom bkt. Kolom hasil urut seperti rollupColumns.
+func aggregateSelect(source domain.RollupLevel, bucketExpr func(col string) string, cond string) string {
+	var src string
+	if source == rawSource {
+		src = "SELECT id1, id2, sensor_type, " + bucketExpr("ts") + " AS bkt, " +
+			"1 AS n, sensor_value AS lo, sensor_value AS hi, sensor_value AS s, sensor_value * sensor_value AS sq, " +
+			"ts AS fts, sensor_value AS fval, ts AS lts, sensor_value AS lval, id AS seq " +
+			"FROM sensor_data WHERE " + cond + " AND ts >= ? AND ts < ?"
+	} else {
+		src = "SELECT id1, id2, sensor_type, " + bucketExpr("bucket") + " AS bkt, " +
+			"samples AS n, min_value AS lo, max_value AS hi, sum_value AS s, sum_sq AS sq, " +
+			"first_ts AS fts, first_val AS fval, last_ts AS lts, last_val AS lval, 0 AS seq " +
+			"FROM " + RollupTable(source) + " WHERE " + cond + " AND bucket >= ? AND bucket < ?"
+	}
+	// first/last diambil dengan window function; data dengan ts sama
+	// diurutkan per id supaya hasilnya sama dengan SensorRollup.Add
+	return "SELECT id1, id2, sensor_type, bkt, SUM(n), MIN(lo), MAX(hi), SUM(s), SUM(sq), MIN(fts), MIN(fv), MAX(lts), MIN(lv) FROM (" +
+		"SELECT src.*, " +
+		"FIRST_VALUE(fval) OVER (PARTITION BY id1, id2, sensor_type, bkt ORDER BY fts, seq) AS fv, " +
+		"FIRST_VALUE(lval) OVER (PARTITION BY id1, id2, sensor_type, bkt ORDER BY lts DESC, seq DESC) AS lv " +
+		"FROM (" + src + ") src) w GROUP BY id1, id2, sensor_type, bkt"
+}
+
+// levelFor level rollup paling kasar yang bisa menjawab agregat per interval
+// di [from, to): interval kelipatan bucket-nya, from dan to tepat di batas
+// bucket, dan bucket sejak from belum dihapus retention
+func (m *RollupManager) levelFor(interval time.Duration, from, to time.Time) (domain.RollupLevel, bool) {
+	now := time.Now().UTC()
+	for _, l := range slices.Backward(domain.RollupLevels) {
+		if interval%l.Duration() == 0 && l.Bucket(from).Equal(from) && l.Bucket(to).Equal(to) && m.covers(l, from, now) {
+			return l, true
+		}
+	}
+	return "", false
+}
+
+// covers true kalau bucket level l mulai dari t belum dihapus retention
+func (m *RollupManager) covers(l domain.RollupLevel, t, now time.Time) bool {
+	return !t.Before(m.cutoff(l, now))
+}
+
+// cutoff bucket level l sebelum ini dihapus retention; zero kalau disimpan selamanya
+func (m *RollupManager) cutoff(l domain.RollupLevel, now time.Time) time.Time {
+	ret := m.cfg.Retention[l]
+	if ret <= 0 {
+		return time.Time{}
+	}
+	return l.Bucket(now.Add(-ret))
+}
+
+// Run menjalankan Backfill (kalau diaktifkan) lalu menghapus bucket yang
+// kedaluwarsa setiap CheckInterval sampai ctx selesai
+func (m *RollupManager) Run(ctx context.Context) {
+	if m.cfg.Backfill {
+		if err := m.Backfill(ctx); err != nil {
+			log.Printf("Rollup backfill failed: %v", err)
+		}
+	}
+	ticker := time.NewTicker(m.cfg.CheckInterval)
+	defer ticker.Stop()
+	for {
+		if err := m.purge(ctx); err != nil {
+			log.Printf("Rollup retention failed: %v", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Backfill menghitung ulang hari yang jumlah data mentahnya lebih banyak
+// dari yang tercatat di sensor_rollup_1d, misalnya data yang masuk sebelum
+// rollup diaktifkan. Hari yang data mentahnya sudah terhapus retention
+// (lebih sedikit dari rollup) dibiarkan. Membaca seluruh sensor_data sekali
+// untuk menghitung data per hari.
+func (m *RollupManager) Backfill(ctx context.Context) error {
+	raw, err := m.dailyCounts(ctx, "SELECT DATE_FORMAT(ts, '%Y-%m-%d'), COUNT(*) FROM sensor_data GROUP BY 1")
+	if err != nil {
+		return err
+	}
+	rolled, err := m.dailyCounts(ctx, "SELECT DATE_FORMAT(bucket, '%Y-%m-%d'), SUM(samples) FROM "+RollupTable(domain.RollupDay)+" GROUP BY 1")
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	var days []time.Time
+	for day, n := range raw {
+		if n > rolled[day] && m.covers(domain.RollupDay, day, now) {
+			days = append(days, day)
+		}
+	}
+	if len(days) == 0 {
+		return nil
+	}
+	slices.SortFunc(days, time.Time.Compare)
+	log.Printf("Backfilling rollups for %d days (%s to %s)", len(days), days[0].Format(time.DateOnly), days[len(days)-1].Format(time.DateOnly))
+
+	start := time.Now()
+	for _, day := range days {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := m.backfillDay(day, now); err != nil {
+			return fmt.Errorf("backfill %s: %w", day.Format(time.DateOnly), err)
+		}
+	}
+	log.Printf("Rollup backfill finished in %v", time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// backfillDay per level, 1m per jam supaya transaksinya tidak terlalu besar
+func (m *RollupManager) backfillDay(day, now time.Time) error {
+	for i, l := range domain.RollupLevels {
+		if !m.covers(l, day, now) {
+			continue
+		}
+		step := 24 * time.Hour
+		if l == domain.RollupMinute {
+			step = time.Hour
+		}
+		for lo := day; lo.Before(day.Add(24 * time.Hour)); lo = lo.Add(step) {
+			tx, err := m.db.Begin()
+			if err != nil {
+				return err
+			}
+			if err := m.rebuild(tx, i, "1 = 1", nil, lo, lo.Add(step), now); err != nil {
+				tx.Rollback()
+				return err
+			}
+			if err := tx.Commit(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (m *RollupManager) dailyCounts(ctx context.Context, query string) (map[time.Time]int64, error) {
+	rows, err := m.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[time.Time]int64{}
+	for rows.Next() {
+		var day string
+		var n int64
+		if err := rows.Scan(&day, &n); err != nil {
+			return nil, err
+		}
+		t, err := time.Parse(time.DateOnly, day)
+		if err != nil {
+			return nil, err
+		}
+		counts[t] = n
+	}
+	return counts, rows.Err()
+}
+
+// purge menghapus bucket yang lebih tua dari retention level-nya, per chunk
+func (m *RollupManager) purge(ctx context.Context) error {
+	now := time.Now().UTC()
+	for _, l := range domain.RollupLevels {
+		cutoff := m.cutoff(l, now)
+		if cutoff.IsZero() {
+			continue
+		}
+		var total int64
+		for ctx.Err() == nil {
+			res, err := m.db.ExecContext(ctx, "DELETE FROM "+RollupTable(l)+" WHERE bucket < ? LIMIT ?", cutoff, m.cfg.DeleteChunk)
+			if err != nil {
+				return fmt.Errorf("purge %s: %w", RollupTable(l), err)
+			}
+			n, _ := res.RowsAffected()
+			total += n
+			if n < int64(m.cfg.DeleteChunk) {
+				break
+			}
+		}
+		if total > 0 {
+			log.Printf("Deleted %d expired %s rollup buckets (before %s)", total, l, cutoff.Format(time.DateTime))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package mysql
+
+import (
+	"database/sql"
+	"maps"
+	"testing"
+	"time"
+
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/domain"
+)
+
+func TestLevelFor(t *testing.T) {
+	midnight := time.Now().UTC().Truncate(24 * time.Hour).Add(-24 * time.Hour)
+	m, err := NewRollupManager(nil, RollupConfig{Retention: map[domain.RollupLevel]time.Duration{domain.RollupMinute: 6 * time.Hour}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		interval time.Duration
+		from, to time.Time
+		want     domain.RollupLevel // kosong: data mentah
+	}{
+		{"daily", 24 * time.Hour, midnight, midnight.Add(24 * time.Hour), domain.RollupDay},
+		{"weekly from day rollup", 7 * 24 * time.Hour, midnight, midnight.Add(24 * time.Hour), domain.RollupDay},
+		{"hourly", time.Hour, midnight, midnight.Add(time.Hour), domain.RollupHour},
+		{"from not on hour boundary", time.Hour, midnight.Add(time.Minute), midnight.Add(time.Hour), ""},
+		{"to not on hour boundary", time.Hour, midnight, midnight.Add(time.Hour + time.Second), ""},
+		// minute rollup hanya disimpan 6 jam
+		{"90m older than minute retention", 90 * time.Minute, midnight, midnight.Add(3 * time.Hour), ""},
+		{"90m within minute retention", 90 * time.Minute, time.Now().UTC().Truncate(time.Hour).Add(-3 * time.Hour), time.Now().UTC().Truncate(time.Hour), domain.RollupMinute},
+		{"sub-minute interval", 30 * time.Second, midnight, midnight.Add(time.Hour), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, ok := m.levelFor(tt.interval, tt.from, tt.to)
+			if l != tt.want || ok != (tt.want != "") {
+				t.Fatalf("levelFor = %q, %v; want %q", l, ok, tt.want)
+			}
+		})
+	}
+}
+
+// rollupsOf isi tabel rollup semua level untuk id1, per level per bucket
+func rollupsOf(t *testing.T, db *sql.DB, id1 string) map[domain.RollupLevel]map[time.Time]domain.SensorRollup {
+	t.Helper()
+	got := map[domain.RollupLevel]map[time.Time]domain.SensorRollup{}
+	for _, l := range domain.RollupLevels {
+		got[l] = map[time.Time]domain.SensorRollup{}
+		rows, err := db.Query("SELECT "+rollupColumns+" FROM "+RollupTable(l)+" WHERE id1 = ?", id1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for rows.Next() {
+			var r domain.SensorRollup
+			err := rows.Scan(&r.ID1, &r.ID2, &r.SensorType, &r.Bucket, &r.Samples, &r.MinValue, &r.MaxValue,
+				&r.SumValue, &r.SumSq, &r.FirstTS, &r.FirstVal, &r.LastTS, &r.LastVal)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.Bucket, r.FirstTS, r.LastTS = r.Bucket.UTC(), r.FirstTS.UTC(), r.LastTS.UTC()
+			got[l][r.Bucket] = r
+		}
+		if err := rows.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return got
+}
+
+// rollupsFrom rollup yang benar untuk data di sensors, dihitung di Go
+func rollupsFrom(sensors []*domain.SensorData) map[domain.RollupLevel]map[time.Time]domain.SensorRollup {
+	want := map[domain.RollupLevel]map[time.Time]domain.SensorRollup{}
+	for _, l := range domain.RollupLevels {
+		want[l] = map[time.Time]domain.SensorRollup{}
+		for _, s := range sensors {
+			b := want[l][l.Bucket(s.TS)]
+			if b.Samples == 0 {
+				b = domain.SensorRollup{ID1: s.ID1, ID2: s.ID2, SensorType: s.SensorType, Bucket: l.Bucket(s.TS)}
+			}
+			b.Add(s.SensorValue, s.TS)
+			want[l][b.Bucket] = b
+		}
+	}
+	return want
+}
+
+func TestRollupRecompute(t *testing.T) {
+	db := testDB(t)
+	rollups, err := NewRollupManager(db, RollupConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := NewSensorRepository(db, SensorRepoConfig{Rollups: rollups})
+
+	base := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time { ts := base.Add(d); return &ts }
+	// dua menit di jam 10 dan satu data di jam 11, semua di hari yang sama
+	initial := map[uint64]struct {
+		offset time.Duration
+		value  float64
+	}{1: {10 * time.Second, 1}, 2: {20 * time.Second, 2}, 3: {time.Minute, 3}, 4: {time.Hour, 4}}
+
+	tests := []struct {
+		name      string
+		op        func(id1 string) (int64, error)
+		want      int64
+		remaining map[uint64]float64 // seq → nilai setelah op
+	}{
+		{
+			name:      "update part of a minute",
+			op:        func(id1 string) (int64, error) { return repo.UpdateByFilter(id1, nil, at(0), at(15*time.Second), 10) },
+			want:      1,
+			remaining: map[uint64]float64{1: 10, 2: 2, 3: 3, 4: 4},
+		},
+		{
+			name: "delete first reading",
+			op: func(id1 string) (int64, error) {
+				return repo.DeleteByFilter(id1, nil, at(10*time.Second), at(10*time.Second))
+			},
+			want:      1,
+			remaining: map[uint64]float64{2: 2, 3: 3, 4: 4},
+		},
+		{
+			name: "delete whole minute",
+			op: func(id1 string) (int64, error) {
+				return repo.DeleteByFilter(id1, nil, at(time.Minute), at(2*time.Minute))
+			},
+			want:      1,
+			remaining: map[uint64]float64{1: 1, 2: 2, 4: 4},
+		},
+		{
+			name:      "update across hours",
+			op:        func(id1 string) (int64, error) { return repo.UpdateByFilter(id1, nil, nil, nil, 7) },
+			want:      4,
+			remaining: map[uint64]float64{1: 7, 2: 7, 3: 7, 4: 7},
+		},
+		{
+			name:      "delete everything",
+			op:        func(id1 string) (int64, error) { return repo.DeleteByFilter(id1, nil, nil, nil) },
+			want:      4,
+			remaining: map[uint64]float64{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id1 := testSeries(t, db)
+			var sensors []*domain.SensorData
+			for seq, r := range initial {
+				sensors = append(sensors, row(id1, seq, base.Add(r.offset), r.value))
+			}
+			if _, err := repo.StoreBatch(sensors); err != nil {
+				t.Fatal(err)
+			}
+
+			n, err := tt.op(id1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != tt.want {
+				t.Fatalf("affected %d rows, want %d", n, tt.want)
+			}
+
+			var remaining []*domain.SensorData
+			for seq, value := range tt.remaining {
+				remaining = append(remaining, row(id1, seq, base.Add(initial[seq].offset), value))
+			}
+			got, want := rollupsOf(t, db, id1), rollupsFrom(remaining)
+			for _, l := range domain.RollupLevels {
+				if !maps.Equal(got[l], want[l]) {
+					t.Fatalf("%s rollup\n got  %+v\n want %+v", l, got[l], want[l])
+				}
+			}
+		})
+	}
+}
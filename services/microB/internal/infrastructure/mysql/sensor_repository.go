@@ -2,51 +2,272 @@ package mysql
 
 import (
 	"database/sql"
+	"fmt"
 	"log"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	mysqldriver "github.com/go-sql-driver/mysql"
+
 	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/domain"
 )
 
+// OpenDB membuka koneksi untuk semua repository. clientFoundRows=true di DSN
+// dimatikan: insertRows membaca affected rows 0 sebagai duplikat, sedangkan
+// dengan opsi itu duplikat juga dihitung 1 dan tidak pernah terdeteksi.
+func OpenDB(dsn string) (*sql.DB, error) {
+	cfg, err := mysqldriver.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.ClientFoundRows {
+		log.Println("DB_DSN clientFoundRows=true ignored, duplicate detection needs affected rows")
+		cfg.ClientFoundRows = false
+	}
+	connector, err := mysqldriver.NewConnector(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DSN: %w", err)
+	}
+	return sql.OpenDB(connector), nil
+}
+
+// SensorRepoConfig pengaturan tulis batch ke sensor_data
+type SensorRepoConfig struct {
+	// InsertChunkSize jumlah baris maksimal per statement INSERT multi-row
+	InsertChunkSize int
+	// MaxPacketBytes batas ukuran satu statement; 0 artinya pakai
+	// @@max_allowed_packet server (dibaca saat batch pertama)
+	MaxPacketBytes int
+	// Rollups kalau diisi, tabel rollup di-update di transaksi yang sama
+	// dengan insert, update dan delete lewat filter. Penghapusan oleh
+	// retention dan partisi tidak menyentuh rollup.
+	Rollups *RollupManager
+}
+
 type sensorRepo struct {
-	db *sql.DB
+	db        *sql.DB
+	chunkSize int
+	maxPacket atomic.Int64
+	rollups   *RollupManager
 }
 
-func NewSensorRepository(db *sql.DB) domain.SensorRepository {
-	return &sensorRepo{db: db}
+func NewSensorRepository(db *sql.DB, cfg SensorRepoConfig) domain.SensorRepository {
+	r := &sensorRepo{db: db, chunkSize: cfg.InsertChunkSize, rollups: cfg.Rollups}
+	// MySQL membatasi 65535 placeholder per statement
+	if r.chunkSize <= 0 || r.chunkSize > maxInsertChunk {
+		r.chunkSize = maxInsertChunk
+	}
+	r.maxPacket.Store(int64(cfg.MaxPacketBytes))
+	return r
 }
 
+const (
+	insertSensorColumns = 8
+	maxInsertChunk      = 65535 / insertSensorColumns
+	// dipakai kalau @@max_allowed_packet tidak bisa dibaca (default MySQL 5.7)
+	defaultMaxPacket = 4 << 20
+)
+
+// insertSensorQuery memakai ON DUPLICATE KEY UPDATE supaya data yang dikirim
+// ulang tidak jadi error. Dedup key-nya idx_producer_seq (producer_id, seq,
+// ts); per baris affected rows 1 berarti baru dan 0 berarti duplikat (tanpa
+// clientFoundRows, lihat OpenDB), baris mana yang duplikat dicari insertRows.
+const (
+	insertSensorPrefix = "INSERT INTO sensor_data (sensor_value, sensor_type, id1, id2, ts, producer_id, seq, ingested_by) VALUES "
+	insertSensorRow    = "(?, ?, ?, ?, ?, ?, ?, ?)"
+	insertSensorSuffix = " ON DUPLICATE KEY UPDATE id = id"
+	insertSensorQuery  = insertSensorPrefix + insertSensorRow + insertSensorSuffix
+)
+
 func (r *sensorRepo) Store(sensor *domain.SensorData) error {
-	query := `INSERT INTO sensor_data (sensor_value, sensor_type, id1, id2, ts) VALUES (?, ?, ?, ?, ?)`
-	_, err := r.db.Exec(query, sensor.SensorValue, sensor.SensorType, sensor.ID1, sensor.ID2, sensor.TS)
+	if r.rollups != nil {
+		_, err := r.StoreBatch([]*domain.SensorData{sensor})
+		return err
+	}
+	_, err := r.db.Exec(insertSensorQuery, sensor.SensorValue, sensor.SensorType, sensor.ID1, sensor.ID2, sensor.TS, sensor.ProducerID, sensor.Seq, sensor.IngestedBy)
 	return err
 }
 
-func (r *sensorRepo) StoreBatch(sensors []*domain.SensorData) error {
-	tx, err := r.db.Begin()
-	if err != nil {
-		return err
+// StoreBatch menulis semua data dalam satu transaksi memakai INSERT
+// multi-row, dipotong per InsertChunkSize baris atau lebih cepat kalau
+// statement-nya akan melewati max_allowed_packet
+func (r *sensorRepo) StoreBatch(sensors []*domain.SensorData) (domain.BatchResult, error) {
+	var result domain.BatchResult
+	if len(sensors) == 0 {
+		return result, nil
 	}
-	stmt, err := tx.Prepare(`INSERT INTO sensor_data (sensor_value, sensor_type, id1, id2, ts) VALUES (?, ?, ?, ?, ?)`)
+	maxPacket := r.packetLimit()
+
+	tx, err := r.db.Begin()
 	if err != nil {
-		return err
+		return result, err
 	}
-	defer stmt.Close()
+	dup := make([]bool, len(sensors))
+	// statement untuk chunk penuh di-prepare sekali per batch
+	var fullStmt *sql.Stmt
+	defer func() {
+		if fullStmt != nil {
+			fullStmt.Close()
+		}
+	}()
+
+	for start := 0; start < len(sensors); {
+		end, size := start, len(insertSensorQuery)
+		for end < len(sensors) && end-start < r.chunkSize {
+			rowSize := estimateRowSize(sensors[end])
+			if end > start && size+rowSize > maxPacket {
+				break
+			}
+			size += rowSize
+			end++
+		}
+		chunk := sensors[start:end]
 
-	for _, s := range sensors {
-		log.Printf("Inserting: value=%f type=%s id1=%s id2=%d ts=%v",
-			s.SensorValue, s.SensorType, s.ID1, s.ID2, s.TS)
-		_, err := stmt.Exec(s.SensorValue, s.SensorType, s.ID1, s.ID2, s.TS)
+		var stmt *sql.Stmt
+		if len(chunk) == r.chunkSize {
+			if fullStmt == nil {
+				query, _ := buildInsert(chunk)
+				if fullStmt, err = tx.Prepare(query); err != nil {
+					tx.Rollback()
+					return domain.BatchResult{}, err
+				}
+			}
+			stmt = fullStmt
+		}
+		n, err := insertRows(tx, stmt, chunk, dup[start:end], 0)
 		if err != nil {
 			tx.Rollback()
-			return err
+			return domain.BatchResult{}, err
 		}
+		result.Inserted += n
+		result.Duplicates += len(chunk) - n
+		start = end
 	}
-	return tx.Commit()
+	if r.rollups != nil {
+		fresh := sensors
+		if result.Duplicates > 0 {
+			fresh = make([]*domain.SensorData, 0, result.Inserted)
+			for i, s := range sensors {
+				if !dup[i] {
+					fresh = append(fresh, s)
+				}
+			}
+		}
+		if err := r.rollups.add(tx, fresh); err != nil {
+			tx.Rollback()
+			return domain.BatchResult{}, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return domain.BatchResult{}, err
+	}
+	if result.Duplicates > 0 {
+		result.Duplicate = dup
+	}
+	return result, nil
+}
+
+// insertRows menulis rows dan menandai baris yang sudah ada di dup.
+// Affected rows hanya memberi jumlah duplikat, jadi rows yang berisi
+// campuran baru dan duplikat di-rollback ke savepoint lalu dibelah dua
+// sampai ketahuan baris mana yang duplikat. Biasanya duplikat jarang
+// (data yang dikirim ulang), jadi statement tambahannya sedikit.
+func insertRows(tx *sql.Tx, stmt *sql.Stmt, rows []*domain.SensorData, dup []bool, depth int) (int, error) {
+	savepoint := "insert_" + strconv.Itoa(depth)
+	if len(rows) > 1 {
+		if _, err := tx.Exec("SAVEPOINT " + savepoint); err != nil {
+			return 0, err
+		}
+	}
+	query, args := buildInsert(rows)
+	var res sql.Result
+	var err error
+	if stmt != nil {
+		res, err = stmt.Exec(args...)
+	} else {
+		res, err = tx.Exec(query, args...)
+	}
+	if err != nil {
+		return 0, err
+	}
+	// baris baru dihitung 1, duplikat (id = id tidak mengubah apa-apa) 0
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case int(n) == len(rows):
+		return len(rows), nil
+	case n == 0:
+		for i := range dup {
+			dup[i] = true
+		}
+		return 0, nil
+	}
+
+	if _, err := tx.Exec("ROLLBACK TO SAVEPOINT " + savepoint); err != nil {
+		return 0, err
+	}
+	mid := len(rows) / 2
+	left, err := insertRows(tx, nil, rows[:mid], dup[:mid], depth+1)
+	if err != nil {
+		return 0, err
+	}
+	right, err := insertRows(tx, nil, rows[mid:], dup[mid:], depth+1)
+	if err != nil {
+		return 0, err
+	}
+	return left + right, nil
+}
+
+// packetLimit batas ukuran statement; @@max_allowed_packet dibaca sekali dan
+// disimpan, kalau gagal dipakai default dan dicoba lagi di batch berikutnya
+func (r *sensorRepo) packetLimit() int {
+	if n := r.maxPacket.Load(); n > 0 {
+		return int(n)
+	}
+	var n int64
+	if err := r.db.QueryRow("SELECT @@max_allowed_packet").Scan(&n); err != nil || n <= 0 {
+		log.Printf("Cannot read max_allowed_packet, assuming %d bytes: %v", defaultMaxPacket, err)
+		return defaultMaxPacket
+	}
+	r.maxPacket.Store(n)
+	return int(n)
+}
+
+func buildInsert(chunk []*domain.SensorData) (string, []any) {
+	var sb strings.Builder
+	sb.Grow(len(insertSensorQuery) + len(chunk)*(len(insertSensorRow)+1))
+	sb.WriteString(insertSensorPrefix)
+	args := make([]any, 0, len(chunk)*insertSensorColumns)
+	for i, s := range chunk {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(insertSensorRow)
+		args = append(args, s.SensorValue, s.SensorType, s.ID1, s.ID2, s.TS, s.ProducerID, s.Seq, s.IngestedBy)
+	}
+	sb.WriteString(insertSensorSuffix)
+	return sb.String(), args
+}
+
+// estimateRowSize perkiraan (dibulatkan ke atas) byte satu baris di statement:
+// placeholder, nilai numerik/waktu dan string
+func estimateRowSize(s *domain.SensorData) int {
+	size := len(insertSensorRow) + 1 + 64 + len(s.SensorType) + len(s.ID1)
+	if s.ProducerID != nil {
+		size += len(*s.ProducerID)
+	}
+	if s.IngestedBy != nil {
+		size += len(*s.IngestedBy)
+	}
+	return size
 }
 
 func (r *sensorRepo) FindByFilter(id1 string, id2 *int, from, to *time.Time, limit, offset int) ([]*domain.SensorData, int, error) {
-	query := `SELECT id, sensor_value, sensor_type, id1, id2, ts, created_at, updated_at FROM sensor_data WHERE 1=1`
+	query := `SELECT id, sensor_value, sensor_type, id1, id2, ts, producer_id, seq, ingested_by, created_at, updated_at FROM sensor_data WHERE 1=1`
 	args := []interface{}{}
 
 	if id1 != "" {
@@ -87,10 +308,21 @@ func (r *sensorRepo) FindByFilter(id1 string, id2 *int, from, to *time.Time, lim
 	for rows.Next() {
 		var s domain.SensorData
 		var updatedAt sql.NullTime
-		err := rows.Scan(&s.ID, &s.SensorValue, &s.SensorType, &s.ID1, &s.ID2, &s.TS, &s.CreatedAt, &updatedAt)
+		var producerID, ingestedBy sql.NullString
+		var seq sql.Null[uint64]
+		err := rows.Scan(&s.ID, &s.SensorValue, &s.SensorType, &s.ID1, &s.ID2, &s.TS, &producerID, &seq, &ingestedBy, &s.CreatedAt, &updatedAt)
 		if err != nil {
 			return nil, 0, err
 		}
+		if producerID.Valid {
+			s.ProducerID = &producerID.String
+		}
+		if seq.Valid {
+			s.Seq = &seq.V
+		}
+		if ingestedBy.Valid {
+			s.IngestedBy = &ingestedBy.String
+		}
 		if updatedAt.Valid {
 			s.UpdatedAt = &updatedAt.Time
 		}
@@ -101,57 +333,188 @@ func (r *sensorRepo) FindByFilter(id1 string, id2 *int, from, to *time.Time, lim
 }
 
 func (r *sensorRepo) UpdateByFilter(id1 string, id2 *int, from, to *time.Time, newValue float64) (int64, error) {
-	query := "UPDATE sensor_data SET sensor_value = ?, updated_at = NOW() WHERE 1=1"
-	args := []interface{}{newValue}
+	where, args := filterWhere(id1, id2, from, to)
+	return r.execFilter("UPDATE sensor_data SET sensor_value = ?, updated_at = NOW() WHERE "+where, append([]any{newValue}, args...), where, args)
+}
 
-	if id1 != "" {
-		query += " AND id1 = ?"
-		args = append(args, id1)
+func (r *sensorRepo) DeleteByFilter(id1 string, id2 *int, from, to *time.Time) (int64, error) {
+	where, args := filterWhere(id1, id2, from, to)
+	return r.execFilter("DELETE FROM sensor_data WHERE "+where, args, where, args)
+}
+
+// execFilter menjalankan update/delete; kalau rollup aktif, rentang data
+// yang kena dicatat dulu lalu bucket-nya dihitung ulang di transaksi yang sama
+func (r *sensorRepo) execFilter(query string, args []any, where string, whereArgs []any) (int64, error) {
+	if r.rollups == nil {
+		res, err := r.db.Exec(query, args...)
+		if err != nil {
+			return 0, err
+		}
+		return res.RowsAffected()
 	}
-	if id2 != nil {
-		query += " AND id2 = ?"
-		args = append(args, *id2)
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
 	}
-	if from != nil {
-		query += " AND ts >= ?"
-		args = append(args, *from)
+	defer tx.Rollback()
+	ranges, err := queryRanges(tx, where, whereArgs)
+	if err != nil {
+		return 0, err
 	}
-	if to != nil {
-		query += " AND ts <= ?"
-		args = append(args, *to)
+	res, err := tx.Exec(query, args...)
+	if err != nil {
+		return 0, err
 	}
-
-	res, err := r.db.Exec(query, args...)
+	n, err := res.RowsAffected()
 	if err != nil {
 		return 0, err
 	}
-	return res.RowsAffected()
+	if err := r.rollups.recompute(tx, ranges); err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
 }
 
-func (r *sensorRepo) DeleteByFilter(id1 string, id2 *int, from, to *time.Time) (int64, error) {
-	query := "DELETE FROM sensor_data WHERE 1=1"
-	args := []interface{}{}
+func filterWhere(id1 string, id2 *int, from, to *time.Time) (string, []any) {
+	where := "1=1"
+	args := []any{}
 
 	if id1 != "" {
-		query += " AND id1 = ?"
+		where += " AND id1 = ?"
 		args = append(args, id1)
 	}
 	if id2 != nil {
-		query += " AND id2 = ?"
+		where += " AND id2 = ?"
 		args = append(args, *id2)
 	}
 	if from != nil {
-		query += " AND ts >= ?"
+		where += " AND ts >= ?"
 		args = append(args, *from)
 	}
 	if to != nil {
-		query += " AND ts <= ?"
+		where += " AND ts <= ?"
 		args = append(args, *to)
 	}
+	return where, args
+}
 
-	res, err := r.db.Exec(query, args...)
+// CountExpired dan DeleteExpired hanya menyentuh data mentah, rollup punya
+// retention sendiri
+func (r *sensorRepo) CountExpired(f domain.RetentionFilter) (int64, *time.Time, error) {
+	where, args := retentionWhere(f)
+	var count int64
+	var oldest sql.NullTime
+	err := r.db.QueryRow("SELECT COUNT(*), MIN(ts) FROM sensor_data WHERE "+where, args...).Scan(&count, &oldest)
+	if err != nil {
+		return 0, nil, err
+	}
+	if !oldest.Valid {
+		return count, nil, nil
+	}
+	return count, &oldest.Time, nil
+}
+
+// DeleteExpired tanpa ORDER BY supaya tidak perlu sort; urutan tidak penting
+// karena semua data yang cocok memang akan dihapus
+func (r *sensorRepo) DeleteExpired(f domain.RetentionFilter, limit int) (int64, error) {
+	where, args := retentionWhere(f)
+	res, err := r.db.Exec("DELETE FROM sensor_data WHERE "+where+" LIMIT ?", append(args, limit)...)
 	if err != nil {
 		return 0, err
 	}
 	return res.RowsAffected()
 }
+
+// retentionWhere kondisi ts < Before selalu ada, jadi partisi yang lebih
+// baru tidak ikut dibaca
+func retentionWhere(f domain.RetentionFilter) (string, []any) {
+	conds, args := scopeConds(f.Scope)
+	conds = append([]string{"ts < ?"}, conds...)
+	args = append([]any{f.Before}, args...)
+	for _, ex := range f.Exclude {
+		exConds, exArgs := scopeConds(ex)
+		if len(exConds) == 0 {
+			// policy tanpa scope mencakup semua data
+			exConds = []string{"1=1"}
+		}
+		conds = append(conds, "NOT ("+strings.Join(exConds, " AND ")+")")
+		args = append(args, exArgs...)
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+func scopeConds(s domain.RetentionScope) ([]string, []any) {
+	var conds []string
+	var args []any
+	if s.SensorType != "" {
+		conds = append(conds, "sensor_type = ?")
+		args = append(args, s.SensorType)
+	}
+	if s.ID1Pattern != "" {
+		conds = append(conds, "id1 LIKE ? ESCAPE '!'")
+		args = append(args, globToLike(s.ID1Pattern))
+	}
+	return conds, args
+}
+
+// globToLike mengubah "*" jadi "%"; % dan _ di pattern dianggap karakter biasa.
+// Escape pakai "!" bukan backslash supaya tidak tergantung NO_BACKSLASH_ESCAPES
+func globToLike(pattern string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_", "*", "%").Replace(pattern)
+}
+
+func (r *sensorRepo) Aggregate(q domain.AggregateQuery) ([]*domain.SensorRollup, string, error) {
+	source := rawSource
+	if r.rollups != nil {
+		if l, ok := r.rollups.levelFor(q.Interval, q.From, q.To); ok {
+			source = l
+		}
+	}
+	where := "1=1"
+	args := []any{}
+	if q.ID1 != "" {
+		where += " AND id1 = ?"
+		args = append(args, q.ID1)
+	}
+	if q.ID2 != nil {
+		where += " AND id2 = ?"
+		args = append(args, *q.ID2)
+	}
+	if len(q.SensorTypes) > 0 {
+		where += " AND sensor_type IN (?" + strings.Repeat(", ?", len(q.SensorTypes)-1) + ")"
+		for _, t := range q.SensorTypes {
+			args = append(args, t)
+		}
+	}
+	args = append(args, q.From, q.To)
+
+	// nomor bucket sejak epoch; tidak memakai UNIX_TIMESTAMP karena hasilnya
+	// tergantung time_zone session
+	secs := int64(q.Interval / time.Second)
+	bucketExpr := func(col string) string {
+		return "FLOOR(TIMESTAMPDIFF(SECOND, '1970-01-01 00:00:00', " + col + ") / " + strconv.FormatInt(secs, 10) + ")"
+	}
+	rows, err := r.db.Query(aggregateSelect(source, bucketExpr, where)+" ORDER BY id1, id2, sensor_type, bkt", args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	var result []*domain.SensorRollup
+	for rows.Next() {
+		var a domain.SensorRollup
+		var bucket int64
+		err := rows.Scan(&a.ID1, &a.ID2, &a.SensorType, &bucket, &a.Samples, &a.MinValue, &a.MaxValue,
+			&a.SumValue, &a.SumSq, &a.FirstTS, &a.FirstVal, &a.LastTS, &a.LastVal)
+		if err != nil {
+			return nil, "", err
+		}
+		a.Bucket = time.Unix(bucket*secs, 0).UTC()
+		result = append(result, &a)
+	}
+	if source == rawSource {
+		return result, "raw", rows.Err()
+	}
+	return result, string(source), rows.Err()
+}
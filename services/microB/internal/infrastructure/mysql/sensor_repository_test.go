@@ -0,0 +1,541 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	gormmysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/domain"
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/usecase"
+)
+
+// testDB koneksi ke MySQL dari MICROB_TEST_DSN (misalnya
+// root:root@tcp(localhost:3306)/datastream_test?parseTime=true); test
+// di-skip kalau tidak diisi. Tabel dibuat seperti saat MicroB start.
+func testDB(tb testing.TB) *sql.DB {
+	tb.Helper()
+	dsn := os.Getenv("MICROB_TEST_DSN")
+	if dsn == "" {
+		tb.Skip("MICROB_TEST_DSN not set")
+	}
+	g, err := gorm.Open(gormmysql.Open(dsn), &gorm.Config{})
+	if err != nil {
+		tb.Fatalf("connect: %v", err)
+	}
+	if err := g.AutoMigrate(&domain.SensorData{}); err != nil {
+		tb.Fatalf("migrate: %v", err)
+	}
+	for _, l := range domain.RollupLevels {
+		if err := g.Table(RollupTable(l)).AutoMigrate(&domain.SensorRollup{}); err != nil {
+			tb.Fatalf("migrate %s: %v", RollupTable(l), err)
+		}
+	}
+	db, err := g.DB()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { db.Close() })
+	return db
+}
+
+// testSeries id1 unik per test supaya data test lain tidak ikut terhitung;
+// datanya dihapus lagi setelah test selesai
+func testSeries(tb testing.TB, db *sql.DB) string {
+	tb.Helper()
+	id1 := fmt.Sprintf("T%d", time.Now().UnixNano()%1e15)
+	tb.Cleanup(func() {
+		db.Exec("DELETE FROM sensor_data WHERE id1 = ?", id1)
+		for _, l := range domain.RollupLevels {
+			db.Exec("DELETE FROM "+RollupTable(l)+" WHERE id1 = ?", id1)
+		}
+	})
+	return id1
+}
+
+func row(id1 string, seq uint64, ts time.Time, value float64) *domain.SensorData {
+	producer := "test-" + id1
+	return &domain.SensorData{SensorValue: value, SensorType: "temperature", ID1: id1, ID2: 1, TS: ts, ProducerID: &producer, Seq: &seq}
+}
+
+func TestStoreBatchDuplicates(t *testing.T) {
+	db := testDB(t)
+	base := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		existing []uint64 // seq yang sudah tersimpan sebelum batch
+		batch    []uint64
+		wantDup  []bool // nil artinya tidak ada duplikat
+	}{
+		{name: "all fresh", batch: []uint64{1, 2, 3, 4, 5, 6, 7}},
+		{name: "all duplicate", existing: []uint64{1, 2, 3}, batch: []uint64{1, 2, 3}, wantDup: []bool{true, true, true}},
+		{
+			name:     "mixed across chunks",
+			existing: []uint64{2, 6, 7},
+			batch:    []uint64{1, 2, 3, 4, 5, 6, 7, 8},
+			wantDup:  []bool{false, true, false, false, false, true, true, false},
+		},
+		{
+			name:    "repeated inside batch",
+			batch:   []uint64{1, 2, 1, 3, 2},
+			wantDup: []bool{false, false, true, false, true},
+		},
+		{
+			name:     "single row chunk",
+			existing: []uint64{9},
+			batch:    []uint64{9},
+			wantDup:  []bool{true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id1 := testSeries(t, db)
+			// chunk 3 baris: chunk penuh lewat prepared statement, sisanya tidak
+			repo := NewSensorRepository(db, SensorRepoConfig{InsertChunkSize: 3})
+			seed := make([]*domain.SensorData, len(tt.existing))
+			for i, seq := range tt.existing {
+				seed[i] = row(id1, seq, base.Add(time.Duration(seq)*time.Second), 1)
+			}
+			if _, err := repo.StoreBatch(seed); err != nil {
+				t.Fatalf("seed: %v", err)
+			}
+
+			batch := make([]*domain.SensorData, len(tt.batch))
+			for i, seq := range tt.batch {
+				batch[i] = row(id1, seq, base.Add(time.Duration(seq)*time.Second), 2)
+			}
+			res, err := repo.StoreBatch(batch)
+			if err != nil {
+				t.Fatalf("StoreBatch: %v", err)
+			}
+			if !slices.Equal(res.Duplicate, tt.wantDup) {
+				t.Fatalf("Duplicate %v, want %v", res.Duplicate, tt.wantDup)
+			}
+			dups := 0
+			for _, d := range tt.wantDup {
+				if d {
+					dups++
+				}
+			}
+			if res.Duplicates != dups || res.Inserted != len(tt.batch)-dups {
+				t.Fatalf("inserted %d duplicates %d, want %d and %d", res.Inserted, res.Duplicates, len(tt.batch)-dups, dups)
+			}
+
+			var count int
+			if err := db.QueryRow("SELECT COUNT(*) FROM sensor_data WHERE id1 = ?", id1).Scan(&count); err != nil {
+				t.Fatal(err)
+			}
+			if want := len(tt.existing) + res.Inserted; count != want {
+				t.Fatalf("%d rows in sensor_data, want %d", count, want)
+			}
+		})
+	}
+}
+
+func TestOpenDBClientFoundRows(t *testing.T) {
+	id1 := testSeries(t, testDB(t))
+	dsn := os.Getenv("MICROB_TEST_DSN")
+	if strings.Contains(dsn, "?") {
+		dsn += "&clientFoundRows=true"
+	} else {
+		dsn += "?clientFoundRows=true"
+	}
+	db, err := OpenDB(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	repo := NewSensorRepository(db, SensorRepoConfig{})
+	ts := time.Now().UTC().Truncate(time.Second)
+	if _, err := repo.StoreBatch([]*domain.SensorData{row(id1, 1, ts, 1)}); err != nil {
+		t.Fatal(err)
+	}
+	res, err := repo.StoreBatch([]*domain.SensorData{row(id1, 1, ts, 1), row(id1, 2, ts, 2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Inserted != 1 || res.Duplicates != 1 || !slices.Equal(res.Duplicate, []bool{true, false}) {
+		t.Fatalf("result %+v, want the resent row reported as duplicate", res)
+	}
+
+	if _, err := OpenDB("not a dsn"); err == nil {
+		t.Fatal("OpenDB accepted an invalid DSN")
+	}
+}
+
+func TestStoreBatchRollupSkipsDuplicates(t *testing.T) {
+	db := testDB(t)
+	id1 := testSeries(t, db)
+	rollups, err := NewRollupManager(db, RollupConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := NewSensorRepository(db, SensorRepoConfig{InsertChunkSize: 4, Rollups: rollups})
+	base := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	if _, err := repo.StoreBatch([]*domain.SensorData{row(id1, 1, base, 10), row(id1, 2, base.Add(time.Second), 20)}); err != nil {
+		t.Fatal(err)
+	}
+	// seq 2 dikirim ulang bersama data baru; nilainya tidak boleh dihitung dua kali
+	_, err = repo.StoreBatch([]*domain.SensorData{
+		row(id1, 2, base.Add(time.Second), 20),
+		row(id1, 3, base.Add(2*time.Second), 30),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var samples int64
+	var sum float64
+	err = db.QueryRow("SELECT samples, sum_value FROM "+RollupTable(domain.RollupMinute)+" WHERE id1 = ?", id1).Scan(&samples, &sum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if samples != 3 || sum != 60 {
+		t.Fatalf("rollup samples %d sum %v, want 3 and 60", samples, sum)
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	db := testDB(t)
+	id1 := testSeries(t, db)
+	rollups, err := NewRollupManager(db, RollupConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := NewSensorRepository(db, SensorRepoConfig{Rollups: rollups})
+	base := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) time.Time { return base.Add(d) }
+	_, err = repo.StoreBatch([]*domain.SensorData{
+		row(id1, 1, at(-time.Second), -1),
+		row(id1, 2, at(0), 1),
+		row(id1, 3, at(time.Hour-time.Second), 3),
+		row(id1, 4, at(time.Hour), 5),
+		row(id1, 5, at(90*time.Minute), 7),
+		row(id1, 6, at(2*time.Hour), 100),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// menit sudah dihapus retention untuk data Maret 2026
+	expired, err := NewRollupManager(db, RollupConfig{Retention: map[domain.RollupLevel]time.Duration{domain.RollupMinute: time.Hour}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type bucket struct {
+		start       time.Time
+		n           int64
+		sum         float64
+		first, last float64
+	}
+	hourly := []bucket{{at(0), 2, 4, 1, 3}, {at(time.Hour), 2, 12, 5, 7}}
+	ninety := []bucket{{at(-time.Hour), 2, 0, -1, 1}, {at(30 * time.Minute), 3, 15, 3, 7}}
+	tests := []struct {
+		name       string
+		rollups    *RollupManager
+		interval   time.Duration
+		from, to   time.Time
+		wantSource string
+		want       []bucket
+	}{
+		{"hourly from hour rollup", rollups, time.Hour, at(0), at(2 * time.Hour), "1h", hourly},
+		{"hourly without rollups", nil, time.Hour, at(0), at(2 * time.Hour), "raw", hourly},
+		{"2h from hour rollup", rollups, 2 * time.Hour, at(0), at(2 * time.Hour), "1h", []bucket{{at(0), 4, 16, 1, 7}}},
+		// bucket tetap dihitung dari epoch, bukan dari from
+		{"from inside an hour", rollups, time.Hour, at(30 * time.Minute), at(2 * time.Hour), "1m", []bucket{{at(0), 1, 3, 3, 3}, {at(time.Hour), 2, 12, 5, 7}}},
+		{"from inside a minute", rollups, time.Hour, at(59*time.Minute + 30*time.Second), at(2 * time.Hour), "raw", []bucket{{at(0), 1, 3, 3, 3}, {at(time.Hour), 2, 12, 5, 7}}},
+		{"90m from minute rollup", rollups, 90 * time.Minute, at(-time.Hour), at(2 * time.Hour), "1m", ninety},
+		{"90m with minute rollup expired", expired, 90 * time.Minute, at(-time.Hour), at(2 * time.Hour), "raw", ninety},
+		{"daily from day rollup", rollups, 24 * time.Hour, at(-10 * time.Hour), at(14 * time.Hour), "1d", []bucket{{at(-10 * time.Hour), 6, 115, -1, 100}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := NewSensorRepository(db, SensorRepoConfig{Rollups: tt.rollups})
+			rows, source, err := repo.Aggregate(domain.AggregateQuery{ID1: id1, From: tt.from, To: tt.to, Interval: tt.interval})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if source != tt.wantSource {
+				t.Fatalf("source %q, want %q", source, tt.wantSource)
+			}
+			var got []bucket
+			for _, r := range rows {
+				got = append(got, bucket{r.Bucket, r.Samples, r.SumValue, r.FirstVal, r.LastVal})
+			}
+			if !slices.EqualFunc(got, tt.want, func(a, b bucket) bool {
+				return a.start.Equal(b.start) && a.n == b.n && a.sum == b.sum && a.first == b.first && a.last == b.last
+			}) {
+				t.Fatalf("buckets %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestAggregateDefaultWindow query default handler (24 jam terakhir, interval
+// 1h): setelah dibulatkan ke batas jam, datanya dari sensor_rollup_1h
+func TestAggregateDefaultWindow(t *testing.T) {
+	db := testDB(t)
+	id1 := testSeries(t, db)
+	rollups, err := NewRollupManager(db, RollupConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := NewSensorRepository(db, SensorRepoConfig{Rollups: rollups})
+	now := time.Now().UTC()
+	hour := now.Truncate(time.Hour)
+	_, err = repo.StoreBatch([]*domain.SensorData{
+		row(id1, 1, hour.Add(-90*time.Minute), 1),
+		row(id1, 2, hour.Add(-80*time.Minute), 3),
+		row(id1, 3, now, 5),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := domain.AggregateQuery{ID1: id1, From: now.Add(-24 * time.Hour), To: now, Interval: time.Hour}.Aligned()
+	rows, source, err := repo.Aggregate(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source != "1h" {
+		t.Fatalf("source %q, want 1h", source)
+	}
+	if len(rows) != 2 || rows[0].Samples != 2 || rows[0].SumValue != 4 || !rows[1].Bucket.Equal(hour) || rows[1].Samples != 1 {
+		t.Fatalf("buckets %+v, want 2 readings at %v and 1 at %v", rows, hour.Add(-2*time.Hour), hour)
+	}
+}
+
+func TestGlobToLike(t *testing.T) {
+	tests := []struct{ glob, want string }{
+		{"LAB-*", "LAB-%"},
+		{"*", "%"},
+		{"a*b*", "a%b%"},
+		{"100%", "100!%"},
+		{"room_1*", "room!_1%"},
+		{"hey!*", "hey!!%"},
+		{`back\slash`, `back\slash`},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := globToLike(tt.glob); got != tt.want {
+			t.Errorf("globToLike(%q) = %q, want %q", tt.glob, got, tt.want)
+		}
+	}
+}
+
+// policyRepo daftar policy tetap untuk RetentionUsecase
+type policyRepo struct {
+	domain.RetentionPolicyRepository
+	policies []*domain.RetentionPolicy
+}
+
+func (r policyRepo) FindAll() ([]*domain.RetentionPolicy, error) { return r.policies, nil }
+
+func TestRetentionRun(t *testing.T) {
+	db := testDB(t)
+	repo := NewSensorRepository(db, SensorRepoConfig{})
+	now := time.Now().UTC()
+	day := int64(24 * 60 * 60)
+
+	type reading struct {
+		id1, sensorType string // ditambah prefix unik per test
+		ageDays         int
+		deleted         bool
+	}
+	tests := []struct {
+		name     string
+		policies func(prefix string) []*domain.RetentionPolicy
+		readings []reading
+	}{
+		{
+			// motion 30 hari, tapi semua data LAB 90 hari; temperature 365 hari dimatikan
+			name: "longest max age wins",
+			policies: func(prefix string) []*domain.RetentionPolicy {
+				return []*domain.RetentionPolicy{
+					{ID: 1, SensorType: prefix + "motion", MaxAgeSeconds: 30 * day, Enabled: true},
+					{ID: 2, ID1Pattern: prefix + "LAB*", MaxAgeSeconds: 90 * day, Enabled: true},
+					{ID: 3, SensorType: prefix + "temp", MaxAgeSeconds: 365 * day, Enabled: false},
+				}
+			},
+			readings: []reading{
+				{"LAB1", "motion", 20, false},
+				{"LAB1", "motion", 60, false},
+				{"LAB1", "motion", 100, true},
+				{"LAB1", "temp", 60, false},
+				{"LAB1", "temp", 100, true},
+				{"OFF1", "motion", 20, false},
+				{"OFF1", "motion", 60, true},
+				{"OFF1", "temp", 400, false},
+			},
+		},
+		{
+			// % dan _ di pattern bukan wildcard
+			name: "glob with percent and underscore",
+			policies: func(prefix string) []*domain.RetentionPolicy {
+				return []*domain.RetentionPolicy{
+					{ID: 1, ID1Pattern: prefix + "_*", MaxAgeSeconds: 10 * day, Enabled: true},
+					{ID: 2, ID1Pattern: prefix + "%*", MaxAgeSeconds: 10 * day, Enabled: true},
+				}
+			},
+			readings: []reading{
+				{"_a", "temp", 50, true},
+				{"Za", "temp", 50, false},
+				{"%b", "temp", 50, true},
+				{"Zb", "temp", 50, false},
+				{"_c", "temp", 5, false},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefix := fmt.Sprintf("R%d", time.Now().UnixNano()%1e12)
+			t.Cleanup(func() { db.Exec("DELETE FROM sensor_data WHERE id1 LIKE ?", prefix+"%") })
+
+			var sensors []*domain.SensorData
+			wantDeleted := 0
+			for i, r := range tt.readings {
+				producer, seq := "test-"+prefix, uint64(i+1)
+				sensors = append(sensors, &domain.SensorData{
+					SensorValue: 1, SensorType: prefix + r.sensorType, ID1: prefix + r.id1, ID2: 1,
+					TS: now.Add(-time.Duration(r.ageDays) * 24 * time.Hour), ProducerID: &producer, Seq: &seq,
+				})
+				if r.deleted {
+					wantDeleted++
+				}
+			}
+			if _, err := repo.StoreBatch(sensors); err != nil {
+				t.Fatal(err)
+			}
+
+			uc := usecase.NewRetentionUsecase(policyRepo{policies: tt.policies(prefix)}, repo, usecase.RetentionConfig{ChunkSize: 2})
+			dry, err := uc.RunNow(context.Background(), true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			run, err := uc.RunNow(context.Background(), false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dry.Deleted != int64(wantDeleted) || run.Deleted != int64(wantDeleted) {
+				t.Fatalf("dry run %d, deleted %d, want %d", dry.Deleted, run.Deleted, wantDeleted)
+			}
+			for i, p := range dry.Policies {
+				if p.Deleted != run.Policies[i].Deleted {
+					t.Errorf("policy %d: dry run %d, deleted %d", p.PolicyID, p.Deleted, run.Policies[i].Deleted)
+				}
+			}
+
+			for i, r := range tt.readings {
+				var n int
+				err := db.QueryRow("SELECT COUNT(*) FROM sensor_data WHERE producer_id = ? AND seq = ?", "test-"+prefix, i+1).Scan(&n)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if (n == 0) != r.deleted {
+					t.Errorf("%s %s %dd: deleted %v, want %v", r.id1, r.sensorType, r.ageDays, n == 0, r.deleted)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkStoreBatch membandingkan INSERT multi-row StoreBatch dengan cara
+// lama (satu prepared statement per baris). Satu op = satu batch 500 baris,
+// sama dengan INGEST_MAX_BATCH_SIZE default:
+//
+//	MICROB_TEST_DSN='root@tcp(127.0.0.1:3306)/datastream_test?parseTime=true' \
+//	  go test -run '^$' -bench StoreBatch ./services/microB/internal/infrastructure/mysql
+func BenchmarkStoreBatch(b *testing.B) {
+	const batchSize = 500
+	db := testDB(b)
+
+	benchmarks := []struct {
+		name  string
+		store func(db *sql.DB) func([]*domain.SensorData) (domain.BatchResult, error)
+		dups  int // baris per batch yang sudah tersimpan sebelumnya
+	}{
+		{name: "row-by-row", store: func(db *sql.DB) func([]*domain.SensorData) (domain.BatchResult, error) { return storeRowByRow(db) }},
+		{name: "chunk=100", store: repoStore(100)},
+		{name: "chunk=500", store: repoStore(500)},
+		{name: "chunk=1000", store: repoStore(1000)},
+		// 5 duplikat tersebar di batch: chunk dibelah sampai ketemu barisnya
+		{name: "chunk=500/1%dup", store: repoStore(500), dups: 5},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			id1 := testSeries(b, db)
+			store := bm.store(db)
+			base := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+			seq := uint64(0)
+			for range b.N {
+				b.StopTimer()
+				batch := make([]*domain.SensorData, batchSize)
+				for i := range batch {
+					seq++
+					batch[i] = row(id1, seq, base.Add(time.Duration(seq)*time.Millisecond), float64(i%1000)/10)
+				}
+				if bm.dups > 0 {
+					old := make([]*domain.SensorData, 0, bm.dups)
+					for i := 0; i < bm.dups; i++ {
+						old = append(old, batch[(i*batchSize)/bm.dups+batchSize/(2*bm.dups)])
+					}
+					if _, err := store(old); err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.StartTimer()
+				if _, err := store(batch); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(b.N*batchSize)/b.Elapsed().Seconds(), "rows/s")
+		})
+	}
+}
+
+func repoStore(chunk int) func(db *sql.DB) func([]*domain.SensorData) (domain.BatchResult, error) {
+	return func(db *sql.DB) func([]*domain.SensorData) (domain.BatchResult, error) {
+		return NewSensorRepository(db, SensorRepoConfig{InsertChunkSize: chunk}).StoreBatch
+	}
+}
+
+// storeRowByRow cara lama StoreBatch sebelum INSERT multi-row, sebagai pembanding
+func storeRowByRow(db *sql.DB) func([]*domain.SensorData) (domain.BatchResult, error) {
+	return func(sensors []*domain.SensorData) (domain.BatchResult, error) {
+		var result domain.BatchResult
+		tx, err := db.Begin()
+		if err != nil {
+			return result, err
+		}
+		stmt, err := tx.Prepare(insertSensorQuery)
+		if err != nil {
+			tx.Rollback()
+			return result, err
+		}
+		defer stmt.Close()
+		for _, s := range sensors {
+			res, err := stmt.Exec(s.SensorValue, s.SensorType, s.ID1, s.ID2, s.TS, s.ProducerID, s.Seq, s.IngestedBy)
+			if err != nil {
+				tx.Rollback()
+				return domain.BatchResult{}, err
+			}
+			if n, _ := res.RowsAffected(); n == 0 {
+				result.Duplicates++
+			} else {
+				result.Inserted++
+			}
+		}
+		return result, tx.Commit()
+	}
+}
@@ -0,0 +1,95 @@
+package mysql
+
+import (
+	"database/sql"
+	"errors"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/domain"
+)
+
+type sensorTypeRepo struct {
+	db *sql.DB
+}
+
+func NewSensorTypeRepository(db *sql.DB) domain.SensorTypeRepository {
+	return &sensorTypeRepo{db: db}
+}
+
+const selectSensorTypeQuery = `SELECT name, unit, description, min_value, max_value, display_precision, created_at, updated_at FROM sensor_types`
+
+func (r *sensorTypeRepo) Create(st *domain.SensorType) error {
+	query := `INSERT INTO sensor_types (name, unit, description, min_value, max_value, display_precision, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, NOW(), NOW())`
+	_, err := r.db.Exec(query, st.Name, st.Unit, st.Description, st.MinValue, st.MaxValue, st.Precision)
+	var myErr *mysqldriver.MySQLError
+	if errors.As(err, &myErr) && myErr.Number == 1062 { // ER_DUP_ENTRY
+		return domain.ErrSensorTypeExists
+	}
+	return err
+}
+
+func (r *sensorTypeRepo) Update(st *domain.SensorType) error {
+	query := `UPDATE sensor_types SET unit = ?, description = ?, min_value = ?, max_value = ?, display_precision = ?, updated_at = NOW()
+		WHERE name = ?`
+	res, err := r.db.Exec(query, st.Unit, st.Description, st.MinValue, st.MaxValue, st.Precision, st.Name)
+	if err != nil {
+		return err
+	}
+	// affected rows 0 juga terjadi kalau isinya tidak berubah, jadi cek dulu
+	if n, _ := res.RowsAffected(); n == 0 {
+		if _, err := r.FindByName(st.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *sensorTypeRepo) Delete(name string) error {
+	res, err := r.db.Exec(`DELETE FROM sensor_types WHERE name = ?`, name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return domain.ErrSensorTypeNotFound
+	}
+	return nil
+}
+
+func (r *sensorTypeRepo) FindByName(name string) (*domain.SensorType, error) {
+	row := r.db.QueryRow(selectSensorTypeQuery+` WHERE name = ?`, name)
+	st, err := scanSensorType(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrSensorTypeNotFound
+	}
+	return st, err
+}
+
+func (r *sensorTypeRepo) FindAll() ([]*domain.SensorType, error) {
+	rows, err := r.db.Query(selectSensorTypeQuery + ` ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*domain.SensorType
+	for rows.Next() {
+		st, err := scanSensorType(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, st)
+	}
+	return result, rows.Err()
+}
+
+func scanSensorType(row interface{ Scan(...any) error }) (*domain.SensorType, error) {
+	var st domain.SensorType
+	var description sql.NullString
+	err := row.Scan(&st.Name, &st.Unit, &description, &st.MinValue, &st.MaxValue, &st.Precision, &st.CreatedAt, &st.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	st.Description = description.String
+	return &st, nil
+}
@@ -0,0 +1,312 @@
+package wal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/domain"
+)
+
+const segmentSuffix = ".wal"
+
+// header tiap record: panjang payload (4 byte) + crc32 payload (4 byte)
+const headerSize = 8
+
+// Log write-ahead log untuk record yang sudah diterima MicroB tapi belum
+// ter-commit ke MySQL. Record ditulis ke segment aktif dan di-fsync sebelum
+// Append kembali. Segment baru dibuka kalau segment aktif sudah penuh;
+// segment yang sudah ditutup dihapus setelah semua record di dalamnya
+// di-Release.
+type Log struct {
+	dir             string
+	maxSegmentBytes int64
+
+	mu          sync.Mutex
+	active      *os.File
+	activeID    uint64
+	activeSize  int64
+	outstanding map[uint64]int // segment id → jumlah record yang belum di-Release
+	pending     int
+	replayIDs   []uint64            // segment sisa proses sebelumnya, menunggu Replay
+	kept        map[uint64]struct{} // segment yang replay-nya gagal, tidak dihapus sampai start berikutnya
+
+	syncMu sync.Mutex // group commit: satu fsync bisa menutup banyak Append
+}
+
+func Open(dir string, maxSegmentBytes int64) (*Log, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	ids, err := listSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Log{
+		dir:             dir,
+		maxSegmentBytes: maxSegmentBytes,
+		outstanding:     map[uint64]int{},
+		replayIDs:       ids,
+		kept:            map[uint64]struct{}{},
+	}
+	next := uint64(1)
+	if len(ids) > 0 {
+		next = ids[len(ids)-1] + 1
+	}
+	if err := l.openSegment(next); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func listSegments(dir string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var ids []uint64
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), segmentSuffix)
+		if !ok {
+			continue
+		}
+		id, err := strconv.ParseUint(name, 10, 64)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+func (l *Log) segmentPath(id uint64) string {
+	return filepath.Join(l.dir, fmt.Sprintf("%020d%s", id, segmentSuffix))
+}
+
+// openSegment harus dipanggil dengan mu terkunci (atau saat Open)
+func (l *Log) openSegment(id uint64) error {
+	f, err := os.OpenFile(l.segmentPath(id), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	l.active, l.activeID, l.activeSize = f, id, 0
+	return nil
+}
+
+// rotate menutup segment aktif yang sudah penuh dan membuka segment baru;
+// segment lama langsung dihapus kalau semua record-nya sudah di-Release
+func (l *Log) rotate() error {
+	old, oldID := l.active, l.activeID
+	// Append yang fsync-nya kalah cepat dengan rotate akan dapat ErrClosed,
+	// jadi segment harus di-fsync di sini sebelum ditutup
+	if err := old.Sync(); err != nil {
+		return err
+	}
+	if err := old.Close(); err != nil {
+		return err
+	}
+	if l.outstanding[oldID] == 0 {
+		delete(l.outstanding, oldID)
+		if err := os.Remove(l.segmentPath(oldID)); err != nil {
+			log.Printf("WAL: failed to remove segment %d: %v", oldID, err)
+		}
+	}
+	return l.openSegment(oldID + 1)
+}
+
+// Append menulis record dan baru kembali setelah record itu di-fsync.
+// Nilai yang dikembalikan dipakai untuk Release.
+func (l *Log) Append(sensor *domain.SensorData) (uint64, error) {
+	payload, err := json.Marshal(sensor)
+	if err != nil {
+		return 0, err
+	}
+	buf := make([]byte, headerSize+len(payload))
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(payload))
+	copy(buf[headerSize:], payload)
+
+	l.mu.Lock()
+	if l.activeSize > 0 && l.activeSize+int64(len(buf)) > l.maxSegmentBytes {
+		if err := l.rotate(); err != nil {
+			l.mu.Unlock()
+			return 0, err
+		}
+	}
+	f, id := l.active, l.activeID
+	if _, err := f.Write(buf); err != nil {
+		l.mu.Unlock()
+		return 0, err
+	}
+	l.activeSize += int64(len(buf))
+	l.outstanding[id]++
+	l.pending++
+	l.mu.Unlock()
+
+	// Append lain yang menunggu di syncMu ikut ter-fsync oleh Sync ini
+	l.syncMu.Lock()
+	err = f.Sync()
+	l.syncMu.Unlock()
+	if err != nil && !errors.Is(err, os.ErrClosed) {
+		l.Release(id)
+		return 0, err
+	}
+	// os.ErrClosed: segment sudah di-rotate, dan rotate sudah fsync duluan
+	return id, nil
+}
+
+// Release menandai satu record di segment id sudah ter-commit (atau sudah
+// aman di dead letter)
+func (l *Log) Release(id uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.pending--
+	l.unref(id)
+}
+
+// unref harus dipanggil dengan mu terkunci. Segment aktif tetap dipakai
+// walau sudah kosong dan baru dibuang saat rotate (penuh) atau Close, supaya
+// ingest yang pelan tidak membuat satu file per record.
+func (l *Log) unref(id uint64) {
+	l.outstanding[id]--
+	if l.outstanding[id] > 0 || id == l.activeID {
+		return
+	}
+	delete(l.outstanding, id)
+	if _, ok := l.kept[id]; ok {
+		return
+	}
+	if err := os.Remove(l.segmentPath(id)); err != nil && !os.IsNotExist(err) {
+		log.Printf("WAL: failed to remove segment %d: %v", id, err)
+	}
+}
+
+// Pending jumlah record yang sudah ditulis tapi belum di-Release
+func (l *Log) Pending() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.pending
+}
+
+// Replay membaca segment sisa proses sebelumnya dan memanggil fn untuk tiap
+// record secara berurutan. Record tidak ditulis ulang: fn meneruskan segment
+// ke Release seperti record hasil Append, dan segment dihapus setelah semua
+// record-nya di-Release. Kalau fn error, segment itu dan sisanya dibiarkan
+// untuk start berikutnya; record yang sudah diteruskan akan di-replay lagi
+// dan dibuang sebagai duplikat.
+func (l *Log) Replay(fn func(sensor *domain.SensorData, segment uint64) error) (int, error) {
+	l.mu.Lock()
+	ids := l.replayIDs
+	l.replayIDs = nil
+	l.mu.Unlock()
+
+	total := 0
+	for i, id := range ids {
+		n, err := l.replaySegment(id, fn)
+		total += n
+		if err != nil {
+			l.mu.Lock()
+			for _, keep := range ids[i:] {
+				l.kept[keep] = struct{}{}
+			}
+			l.mu.Unlock()
+			return total, fmt.Errorf("replay segment %d: %w", id, err)
+		}
+	}
+	return total, nil
+}
+
+func (l *Log) replaySegment(id uint64, fn func(*domain.SensorData, uint64) error) (int, error) {
+	records, err := l.readSegment(id)
+	if err != nil {
+		return 0, err
+	}
+
+	// satu referensi tambahan supaya segment tidak terhapus di tengah replay
+	// kalau record awal sudah di-Release sebelum record berikutnya diteruskan
+	l.mu.Lock()
+	l.outstanding[id] += len(records) + 1
+	l.pending += len(records)
+	l.mu.Unlock()
+
+	n := 0
+	for _, sensor := range records {
+		if err := fn(sensor, id); err != nil {
+			// record yang belum diteruskan tetap pending sampai proses berhenti
+			l.mu.Lock()
+			l.kept[id] = struct{}{}
+			l.unref(id)
+			l.mu.Unlock()
+			return n, err
+		}
+		n++
+	}
+	l.mu.Lock()
+	l.unref(id)
+	l.mu.Unlock()
+	return n, nil
+}
+
+// readSegment semua record utuh di segment; record terakhir yang terpotong
+// atau rusak (crash saat menulis, belum pernah di-ack) dilewati
+func (l *Log) readSegment(id uint64) ([]*domain.SensorData, error) {
+	f, err := os.Open(l.segmentPath(id))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	header := make([]byte, headerSize)
+	var records []*domain.SensorData
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err != io.EOF {
+				log.Printf("WAL: segment %d truncated after %d records", id, len(records))
+			}
+			return records, nil
+		}
+		size := binary.LittleEndian.Uint32(header[0:4])
+		sum := binary.LittleEndian.Uint32(header[4:8])
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(r, payload); err != nil || crc32.ChecksumIEEE(payload) != sum {
+			log.Printf("WAL: segment %d has a torn or corrupt record after %d records, skipping rest", id, len(records))
+			return records, nil
+		}
+
+		var sensor domain.SensorData
+		if err := json.Unmarshal(payload, &sensor); err != nil {
+			log.Printf("WAL: segment %d: cannot decode record %d: %v", id, len(records), err)
+			continue
+		}
+		records = append(records, &sensor)
+	}
+}
+
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.active.Sync(); err != nil {
+		return err
+	}
+	if err := l.active.Close(); err != nil {
+		return err
+	}
+	if l.outstanding[l.activeID] == 0 {
+		return os.Remove(l.segmentPath(l.activeID))
+	}
+	return nil
+}
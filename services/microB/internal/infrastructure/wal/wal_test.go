@@ -0,0 +1,282 @@
+package wal
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/domain"
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/usecase"
+)
+
+func reading(seq uint64) *domain.SensorData {
+	producer := "gw-1"
+	return &domain.SensorData{
+		SensorValue: float64(seq),
+		SensorType:  "temperature",
+		ID1:         "A",
+		ID2:         1,
+		TS:          time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC).Add(time.Duration(seq) * time.Second),
+		ProducerID:  &producer,
+		Seq:         &seq,
+	}
+}
+
+func segments(t *testing.T, dir string) []uint64 {
+	t.Helper()
+	ids, err := listSegments(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ids
+}
+
+func open(t *testing.T, dir string, maxBytes int64) *Log {
+	t.Helper()
+	l, err := Open(dir, maxBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func appendN(t *testing.T, l *Log, from, to uint64) []uint64 {
+	t.Helper()
+	var ids []uint64
+	for seq := from; seq <= to; seq++ {
+		id, err := l.Append(reading(seq))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// collect Replay yang menyimpan seq dan segment tiap record
+func collect(l *Log, failAt uint64) (seqs []uint64, segs []uint64, err error) {
+	_, err = l.Replay(func(s *domain.SensorData, segment uint64) error {
+		if *s.Seq == failAt {
+			return errors.New("pipeline closed")
+		}
+		seqs = append(seqs, *s.Seq)
+		segs = append(segs, segment)
+		return nil
+	})
+	return seqs, segs, err
+}
+
+func TestRotateOnlyWhenFull(t *testing.T) {
+	dir := t.TempDir()
+	l := open(t, dir, 1<<20)
+	defer l.Close()
+
+	// segment aktif yang sudah kosong tetap dipakai
+	for seq := uint64(1); seq <= 5; seq++ {
+		id := appendN(t, l, seq, seq)[0]
+		l.Release(id)
+	}
+	if got := segments(t, dir); !slices.Equal(got, []uint64{1}) {
+		t.Fatalf("segments %v after append/release, want [1]", got)
+	}
+
+	// segment kecil: tiap record membuka segment baru, yang lama dihapus
+	// begitu semua record-nya di-Release
+	small := t.TempDir()
+	ls := open(t, small, 1)
+	defer ls.Close()
+	ids := appendN(t, ls, 1, 3)
+	if !slices.Equal(ids, []uint64{1, 2, 3}) {
+		t.Fatalf("segment ids %v, want [1 2 3]", ids)
+	}
+	ls.Release(ids[1])
+	if got := segments(t, small); !slices.Equal(got, []uint64{1, 3}) {
+		t.Fatalf("segments %v, want [1 3]", got)
+	}
+	ls.Release(ids[2])
+	if got := segments(t, small); !slices.Equal(got, []uint64{1, 3}) {
+		t.Fatalf("active segment removed on release: %v", got)
+	}
+	if ls.Pending() != 1 {
+		t.Fatalf("pending %d, want 1", ls.Pending())
+	}
+}
+
+func TestReplayDoesNotRewrite(t *testing.T) {
+	dir := t.TempDir()
+	l := open(t, dir, 1<<20)
+	appendN(t, l, 1, 3)
+	l.Close() // record belum di-Release: sama dengan crash
+
+	l = open(t, dir, 1<<20)
+	defer l.Close()
+	seqs, segs, err := collect(l, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(seqs, []uint64{1, 2, 3}) {
+		t.Fatalf("replayed %v, want [1 2 3]", seqs)
+	}
+	if l.activeSize != 0 {
+		t.Fatalf("replay wrote %d bytes to the active segment", l.activeSize)
+	}
+	if l.Pending() != 3 {
+		t.Fatalf("pending %d, want 3", l.Pending())
+	}
+
+	for i, seg := range segs {
+		if got := segments(t, dir); !slices.Contains(got, 1) {
+			t.Fatalf("old segment removed after %d of 3 releases", i)
+		}
+		l.Release(seg)
+	}
+	if got := segments(t, dir); !slices.Equal(got, []uint64{2}) {
+		t.Fatalf("segments %v after releasing replayed records, want only the active one", got)
+	}
+}
+
+func TestReplayFailureKeepsSegments(t *testing.T) {
+	dir := t.TempDir()
+	l := open(t, dir, 1)
+	appendN(t, l, 1, 4) // satu record per segment
+	l.Close()
+
+	l = open(t, dir, 1)
+	seqs, segs, err := collect(l, 2)
+	if err == nil {
+		t.Fatal("want replay error")
+	}
+	if !slices.Equal(seqs, []uint64{1}) {
+		t.Fatalf("replayed %v before the failure, want [1]", seqs)
+	}
+	// record yang sudah diteruskan ter-commit; segment-nya tetap dibiarkan
+	// karena replay berikutnya mulai dari segment itu lagi
+	l.Release(segs[0])
+	if got := segments(t, dir); !slices.Equal(got, []uint64{2, 3, 4, 5}) {
+		t.Fatalf("segments %v, want the unreplayed ones and the active one", got)
+	}
+	l.Close()
+
+	l = open(t, dir, 1)
+	defer l.Close()
+	seqs, _, err = collect(l, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(seqs, []uint64{2, 3, 4}) {
+		t.Fatalf("second replay %v, want [2 3 4] without copies", seqs)
+	}
+}
+
+func TestReplayTornTail(t *testing.T) {
+	dir := t.TempDir()
+	l := open(t, dir, 1<<20)
+	appendN(t, l, 1, 2)
+	l.Close()
+
+	// crash di tengah menulis record ketiga
+	f, err := os.OpenFile(filepath.Join(dir, "00000000000000000001.wal"), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{200, 0, 0, 0, 1, 2, 3, 4, '{', '"'})
+	f.Close()
+
+	l = open(t, dir, 1<<20)
+	defer l.Close()
+	seqs, _, err := collect(l, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(seqs, []uint64{1, 2}) {
+		t.Fatalf("replayed %v, want [1 2]", seqs)
+	}
+}
+
+// storeRepo StoreBatch yang bisa dibuat gagal, seperti MySQL mati
+type storeRepo struct {
+	domain.SensorRepository
+	fail bool
+
+	mu     sync.Mutex
+	stored []uint64
+}
+
+func (r *storeRepo) StoreBatch(sensors []*domain.SensorData) (domain.BatchResult, error) {
+	if r.fail {
+		return domain.BatchResult{}, errors.New("database down")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range sensors {
+		r.stored = append(r.stored, *s.Seq)
+	}
+	return domain.BatchResult{Inserted: len(sensors)}, nil
+}
+
+func newPipeline(repo domain.SensorRepository, l *Log) usecase.IngestPipeline {
+	return usecase.NewIngestPipeline(repo, usecase.NewDeduplicator(100), nil, nil, l, nil, usecase.IngestConfig{
+		BatchSize:     10,
+		FlushInterval: 10 * time.Millisecond,
+		RetryBackoff:  time.Millisecond,
+	})
+}
+
+func TestPipelineCrashReplay(t *testing.T) {
+	dir := t.TempDir()
+
+	// database mati: record diterima dan aman di WAL tapi tidak ter-commit
+	l := open(t, dir, 256)
+	p := newPipeline(&storeRepo{fail: true}, l)
+	for seq := uint64(1); seq <= 20; seq++ {
+		if err := p.Enqueue(context.Background(), usecase.IngestRecord{Data: reading(seq)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p.Close()
+	l.Close()
+
+	// start berikutnya juga gagal replay di tengah jalan
+	l = open(t, dir, 256)
+	p = newPipeline(&storeRepo{}, l)
+	p.Close()
+	if _, err := p.ReplayWAL(context.Background()); !errors.Is(err, usecase.ErrPipelineClosed) {
+		t.Fatalf("replay into closed pipeline: %v", err)
+	}
+	l.Close()
+
+	l = open(t, dir, 256)
+	repo := &storeRepo{}
+	p = newPipeline(repo, l)
+	n, err := p.ReplayWAL(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Close()
+	if n != 20 {
+		t.Fatalf("replayed %d records, want 20", n)
+	}
+	slices.Sort(repo.stored)
+	want := make([]uint64, 20)
+	for i := range want {
+		want[i] = uint64(i + 1)
+	}
+	if !slices.Equal(repo.stored, want) {
+		t.Fatalf("stored %v, want seq 1..20 once each", repo.stored)
+	}
+	if l.Pending() != 0 {
+		t.Fatalf("pending %d after replay", l.Pending())
+	}
+	if got := segments(t, dir); len(got) != 1 || got[0] != l.activeID {
+		t.Fatalf("segments %v left after replay, want only the active one", got)
+	}
+	l.Close()
+	if got := segments(t, dir); len(got) != 0 {
+		t.Fatalf("segments %v left after close", got)
+	}
+}
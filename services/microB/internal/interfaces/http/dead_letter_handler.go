@@ -0,0 +1,128 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/domain"
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/usecase"
+)
+
+type DeadLetterHandler struct {
+	usecase usecase.DeadLetterUsecase
+}
+
+// NewDeadLetterHandler mendaftarkan route dead letter; mw dipasang per route
+// (misalnya JWTAuth khusus admin)
+func NewDeadLetterHandler(g *echo.Group, uc usecase.DeadLetterUsecase, mw ...echo.MiddlewareFunc) {
+	handler := &DeadLetterHandler{usecase: uc}
+
+	g.GET("/deadletters", handler.List, mw...)               // GET /api/deadletters
+	g.GET("/deadletters/:id", handler.Get, mw...)            // GET /api/deadletters/:id
+	g.POST("/deadletters/:id/replay", handler.Replay, mw...) // POST /api/deadletters/:id/replay
+	g.DELETE("/deadletters/:id", handler.Purge, mw...)       // DELETE /api/deadletters/:id
+	g.DELETE("/deadletters", handler.PurgeAll, mw...)        // DELETE /api/deadletters
+}
+
+// List godoc
+// @Summary List dead-lettered batches
+// @Description List batches that failed to persist after all retries (without records)
+// @Tags deadletters
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 500 {object} map[string]string
+// @Router /deadletters [get]
+func (h *DeadLetterHandler) List(c echo.Context) error {
+	items, err := h.usecase.List()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if items == nil {
+		items = []*domain.DeadLetter{}
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"total": len(items),
+		"data":  items,
+	})
+}
+
+// Get godoc
+// @Summary Inspect a dead-lettered batch
+// @Description Get a dead-lettered batch including its records
+// @Tags deadletters
+// @Produce json
+// @Param id path string true "Dead letter ID"
+// @Success 200 {object} domain.DeadLetter
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /deadletters/{id} [get]
+func (h *DeadLetterHandler) Get(c echo.Context) error {
+	dl, err := h.usecase.Get(c.Param("id"))
+	if err != nil {
+		return deadLetterError(c, err)
+	}
+	return c.JSON(http.StatusOK, dl)
+}
+
+// Replay godoc
+// @Summary Replay a dead-lettered batch
+// @Description Store the batch again through the ingest pipeline (validation, dedup, live feed); on success the dead letter is removed and records rejected by validation are listed
+// @Tags deadletters
+// @Produce json
+// @Param id path string true "Dead letter ID"
+// @Success 200 {object} usecase.ReplayResult
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /deadletters/{id}/replay [post]
+func (h *DeadLetterHandler) Replay(c echo.Context) error {
+	res, err := h.usecase.Replay(c.Param("id"))
+	if err != nil {
+		return deadLetterError(c, err)
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// Purge godoc
+// @Summary Purge a dead-lettered batch
+// @Description Permanently delete a dead-lettered batch without storing it
+// @Tags deadletters
+// @Produce json
+// @Param id path string true "Dead letter ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /deadletters/{id} [delete]
+func (h *DeadLetterHandler) Purge(c echo.Context) error {
+	if err := h.usecase.Purge(c.Param("id")); err != nil {
+		return deadLetterError(c, err)
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"deleted": 1,
+	})
+}
+
+// PurgeAll godoc
+// @Summary Purge all dead-lettered batches
+// @Description Permanently delete every dead-lettered batch
+// @Tags deadletters
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 500 {object} map[string]string
+// @Router /deadletters [delete]
+func (h *DeadLetterHandler) PurgeAll(c echo.Context) error {
+	deleted, err := h.usecase.PurgeAll()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"deleted": deleted,
+	})
+}
+
+func deadLetterError(c echo.Context, err error) error {
+	if errors.Is(err, domain.ErrDeadLetterNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,29 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/usecase"
+)
+
+type IngestHandler struct {
+	pipeline usecase.IngestPipeline
+}
+
+func NewIngestHandler(g *echo.Group, pipeline usecase.IngestPipeline) {
+	handler := &IngestHandler{pipeline: pipeline}
+
+	g.GET("/ingest/stats", handler.Stats) // GET /api/ingest/stats
+}
+
+// Stats godoc
+// @Summary Get ingest pipeline stats
+// @Description Queue depth, batch counters and write latency of the shared ingest pipeline
+// @Tags ingest
+// @Produce json
+// @Success 200 {object} usecase.IngestStats
+// @Router /ingest/stats [get]
+func (h *IngestHandler) Stats(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.pipeline.Stats())
+}
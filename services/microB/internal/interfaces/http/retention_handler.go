@@ -0,0 +1,255 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/domain"
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/dto"
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/usecase"
+)
+
+type RetentionHandler struct {
+	usecase usecase.RetentionUsecase
+}
+
+// NewRetentionHandler mendaftarkan route retention policy; mw dipasang per
+// route (misalnya JWTAuth khusus admin)
+func NewRetentionHandler(g *echo.Group, uc usecase.RetentionUsecase, mw ...echo.MiddlewareFunc) {
+	handler := &RetentionHandler{usecase: uc}
+
+	g.GET("/retention/policies", handler.List, mw...)          // GET /api/retention/policies
+	g.GET("/retention/policies/:id", handler.Get, mw...)       // GET /api/retention/policies/:id
+	g.POST("/retention/policies", handler.Create, mw...)       // POST /api/retention/policies
+	g.PUT("/retention/policies/:id", handler.Update, mw...)    // PUT /api/retention/policies/:id
+	g.DELETE("/retention/policies/:id", handler.Delete, mw...) // DELETE /api/retention/policies/:id
+	g.POST("/retention/run", handler.Run, mw...)               // POST /api/retention/run?dry_run=true
+	g.GET("/retention/runs", handler.Runs, mw...)              // GET /api/retention/runs
+}
+
+// List godoc
+// @Summary List retention policies
+// @Description List data retention policies (admin only)
+// @Tags retention
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 500 {object} map[string]string
+// @Router /retention/policies [get]
+func (h *RetentionHandler) List(c echo.Context) error {
+	items, err := h.usecase.List()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if items == nil {
+		items = []*domain.RetentionPolicy{}
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"total": len(items),
+		"data":  items,
+	})
+}
+
+// Get godoc
+// @Summary Get a retention policy
+// @Description Get one data retention policy (admin only)
+// @Tags retention
+// @Produce json
+// @Param id path int true "Policy ID"
+// @Success 200 {object} domain.RetentionPolicy
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /retention/policies/{id} [get]
+func (h *RetentionHandler) Get(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+	}
+	p, err := h.usecase.Get(id)
+	if err != nil {
+		return retentionError(c, err)
+	}
+	return c.JSON(http.StatusOK, p)
+}
+
+// Create godoc
+// @Summary Create a retention policy
+// @Description Delete readings of a sensor type and/or id1 pattern once they are older than max_age (admin only).
+// @Description When a reading matches several policies the longest max_age applies.
+// @Tags retention
+// @Accept json
+// @Produce json
+// @Param request body dto.RetentionPolicyRequest true "Retention policy"
+// @Success 201 {object} domain.RetentionPolicy
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /retention/policies [post]
+func (h *RetentionHandler) Create(c echo.Context) error {
+	var req dto.RetentionPolicyRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid body"})
+	}
+	p, err := toRetentionPolicy(req)
+	if err != nil {
+		return retentionError(c, err)
+	}
+	if err := h.usecase.Create(p); err != nil {
+		return retentionError(c, err)
+	}
+	if p, err = h.usecase.Get(p.ID); err != nil {
+		return retentionError(c, err)
+	}
+	return c.JSON(http.StatusCreated, p)
+}
+
+// Update godoc
+// @Summary Update a retention policy
+// @Description Replace scope, max age and enabled flag of a retention policy (admin only)
+// @Tags retention
+// @Accept json
+// @Produce json
+// @Param id path int true "Policy ID"
+// @Param request body dto.RetentionPolicyRequest true "Retention policy"
+// @Success 200 {object} domain.RetentionPolicy
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /retention/policies/{id} [put]
+func (h *RetentionHandler) Update(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+	}
+	var req dto.RetentionPolicyRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid body"})
+	}
+	p, err := toRetentionPolicy(req)
+	if err != nil {
+		return retentionError(c, err)
+	}
+	p.ID = id
+	if err := h.usecase.Update(p); err != nil {
+		return retentionError(c, err)
+	}
+	if p, err = h.usecase.Get(id); err != nil {
+		return retentionError(c, err)
+	}
+	return c.JSON(http.StatusOK, p)
+}
+
+// Delete godoc
+// @Summary Delete a retention policy
+// @Description Remove a retention policy; matching readings are kept from now on (admin only)
+// @Tags retention
+// @Produce json
+// @Param id path int true "Policy ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /retention/policies/{id} [delete]
+func (h *RetentionHandler) Delete(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+	}
+	if err := h.usecase.Delete(id); err != nil {
+		return retentionError(c, err)
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"deleted": 1,
+	})
+}
+
+// Run godoc
+// @Summary Run retention now
+// @Description Apply all enabled retention policies once and report what was purged per policy (admin only).
+// @Description With dry_run=true nothing is deleted and the report shows how many readings would be.
+// @Tags retention
+// @Produce json
+// @Param dry_run query bool false "Only count matching readings"
+// @Success 200 {object} usecase.RetentionRun
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /retention/run [post]
+func (h *RetentionHandler) Run(c echo.Context) error {
+	dryRun := false
+	if v := c.QueryParam("dry_run"); v != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid dry_run"})
+		}
+	}
+	run, err := h.usecase.RunNow(c.Request().Context(), dryRun)
+	if err != nil {
+		return retentionError(c, err)
+	}
+	return c.JSON(http.StatusOK, run)
+}
+
+// Runs godoc
+// @Summary List recent retention runs
+// @Description Reports of the most recent retention runs, newest first (admin only)
+// @Tags retention
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /retention/runs [get]
+func (h *RetentionHandler) Runs(c echo.Context) error {
+	runs := h.usecase.Runs()
+	if runs == nil {
+		runs = []*usecase.RetentionRun{}
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"total": len(runs),
+		"data":  runs,
+	})
+}
+
+func toRetentionPolicy(req dto.RetentionPolicyRequest) (*domain.RetentionPolicy, error) {
+	maxAge, ok := parseDuration(req.MaxAge)
+	if !ok {
+		return nil, fmt.Errorf("%w: invalid max_age %q (e.g. 30d or 720h)", usecase.ErrInvalidRetentionPolicy, req.MaxAge)
+	}
+	p := &domain.RetentionPolicy{
+		SensorType:    strings.TrimSpace(req.SensorType),
+		ID1Pattern:    strings.TrimSpace(req.ID1Pattern),
+		MaxAgeSeconds: int64(maxAge / time.Second),
+		Enabled:       true,
+	}
+	if req.Enabled != nil {
+		p.Enabled = *req.Enabled
+	}
+	return p, nil
+}
+
+// parseDuration durasi Go ("720h") atau jumlah hari ("30d"); dipakai juga
+// untuk interval /sensors/aggregate
+func parseDuration(s string) (time.Duration, bool) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		return time.Duration(n) * 24 * time.Hour, err == nil && n > 0
+	}
+	d, err := time.ParseDuration(s)
+	return d, err == nil
+}
+
+func retentionError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrRetentionPolicyNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, domain.ErrRetentionPolicyExists), errors.Is(err, usecase.ErrRetentionRunning):
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	case errors.Is(err, usecase.ErrInvalidRetentionPolicy):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+}
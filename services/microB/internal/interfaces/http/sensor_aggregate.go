@@ -0,0 +1,89 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/domain"
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/usecase"
+)
+
+// Aggregate godoc
+// @Summary Aggregate sensor data per time bucket
+// @Description One row per series (id1, id2, sensor type) per bucket that has data, with the requested functions.
+// @Description Buckets start at multiples of the interval since the Unix epoch in UTC (1d buckets at 00:00 UTC, 7d buckets on Thursdays; no time zone parameter).
+// @Description from is rounded down and to is rounded up to a bucket boundary, so the first and last buckets are always complete; the response carries the rounded range.
+// @Description Served from the 1m/1h/1d rollup tables when the interval is a multiple of a rollup level still within its retention, otherwise from raw data.
+// @Tags sensors
+// @Produce json
+// @Param id1 query string false "ID1 filter"
+// @Param id2 query int false "ID2 filter"
+// @Param type query string false "Sensor types, comma separated"
+// @Param from query string false "Start timestamp, inclusive (RFC3339); default 24h before to"
+// @Param to query string false "End timestamp, exclusive (RFC3339); default now"
+// @Param interval query string false "Bucket size, e.g. 1m, 15m, 1h, 1d" default(1h)
+// @Param fns query string false "Functions, comma separated: avg, min, max, sum, count, first, last, stddev" default(avg)
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /sensors/aggregate [get]
+func (h *SensorHandler) Aggregate(c echo.Context) error {
+	q := domain.AggregateQuery{ID1: c.QueryParam("id1"), To: time.Now().UTC()}
+	if id2Str := c.QueryParam("id2"); id2Str != "" {
+		val, err := strconv.Atoi(id2Str)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id2"})
+		}
+		q.ID2 = &val
+	}
+	if types := c.QueryParam("type"); types != "" {
+		q.SensorTypes = strings.Split(types, ",")
+	}
+	if toStr := c.QueryParam("to"); toStr != "" {
+		t, err := time.Parse(time.RFC3339, toStr)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid to"})
+		}
+		q.To = t.UTC()
+	}
+	q.From = q.To.Add(-24 * time.Hour)
+	if fromStr := c.QueryParam("from"); fromStr != "" {
+		t, err := time.Parse(time.RFC3339, fromStr)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid from"})
+		}
+		q.From = t.UTC()
+	}
+	interval := c.QueryParam("interval")
+	if interval == "" {
+		interval = "1h"
+	}
+	var ok bool
+	if q.Interval, ok = parseDuration(interval); !ok {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid interval"})
+	}
+	fns, err := usecase.ParseAggregateFuncs(c.QueryParam("fns"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	res, err := h.usecase.Aggregate(q, fns)
+	if errors.Is(err, usecase.ErrInvalidAggregate) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"interval": interval,
+		"from":     res.From,
+		"to":       res.To,
+		"source":   res.Source,
+		"total":    len(res.Rows),
+		"data":     res.Rows,
+	})
+}
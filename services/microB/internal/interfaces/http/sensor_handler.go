@@ -11,14 +11,19 @@ import (
 
 type SensorHandler struct {
 	usecase usecase.SensorUsecase
+	hub     *usecase.SensorHub
+	stream  StreamConfig
 }
 
-func NewSensorHandler(g *echo.Group, uc usecase.SensorUsecase) {
-	handler := &SensorHandler{usecase: uc}
+// hub sumber live feed /sensors/stream
+func NewSensorHandler(g *echo.Group, uc usecase.SensorUsecase, hub *usecase.SensorHub, stream StreamConfig) {
+	handler := &SensorHandler{usecase: uc, hub: hub, stream: stream}
 
-	g.GET("/sensors", handler.GetByFilter)       // GET /api/sensors
-	g.PUT("/sensors", handler.UpdateByFilter)    // PUT /api/sensors
-	g.DELETE("/sensors", handler.DeleteByFilter) // DELETE /api/sensors
+	g.GET("/sensors", handler.GetByFilter)         // GET /api/sensors
+	g.GET("/sensors/stream", handler.Stream)       // GET /api/sensors/stream (SSE / WebSocket)
+	g.GET("/sensors/aggregate", handler.Aggregate) // GET /api/sensors/aggregate
+	g.PUT("/sensors", handler.UpdateByFilter)      // PUT /api/sensors
+	g.DELETE("/sensors", handler.DeleteByFilter)   // DELETE /api/sensors
 }
 
 // GetByFilter godoc
@@ -0,0 +1,396 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/domain"
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/dto"
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/usecase"
+)
+
+// record yang sudah dibaca disimpan per chunk, jadi body besar tidak perlu
+// ditampung seluruhnya di memori
+const ingestChunkSize = 500
+
+type SensorIngestHandler struct {
+	pipeline     usecase.IngestPipeline
+	maxBodyBytes int64
+}
+
+// NewSensorIngestHandler mendaftarkan POST /api/sensors untuk perangkat yang
+// tidak bisa memakai gRPC. mw dipasang per route (bukan lewat group) karena
+// role yang boleh mengirim data berbeda dengan role yang boleh membaca.
+// maxBodyBytes 0 artinya tanpa batas.
+func NewSensorIngestHandler(g *echo.Group, pipeline usecase.IngestPipeline, maxBodyBytes int64, mw ...echo.MiddlewareFunc) {
+	handler := &SensorIngestHandler{pipeline: pipeline, maxBodyBytes: maxBodyBytes}
+
+	g.POST("/sensors", handler.Ingest, mw...)       // POST /api/sensors
+	g.POST("/sensors/batch", handler.Ingest, mw...) // POST /api/sensors/batch
+}
+
+// Ingest godoc
+// @Summary Ingest sensor readings
+// @Description Store readings sent over HTTP. The body is a single JSON reading, a JSON array, NDJSON (`application/x-ndjson`) or CSV (`text/csv`, header row with sensor_value, sensor_type, id1, id2, timestamp and optional producer_id, seq). Each reading is validated like gRPC ingest; invalid ones are reported per line and do not stop the rest. Readings with producer_id and seq are deduplicated, so a failed request can safely be resent. Also available as POST /sensors/batch.
+// @Tags sensors
+// @Accept json
+// @Accept application/x-ndjson
+// @Accept text/csv
+// @Produce json
+// @Param readings body dto.SensorReading true "Reading, array of readings, NDJSON or CSV"
+// @Success 200 {object} dto.IngestSummary
+// @Failure 400 {object} dto.IngestSummary
+// @Failure 413 {object} dto.IngestSummary
+// @Failure 415 {object} map[string]string
+// @Failure 503 {object} dto.IngestSummary
+// @Router /sensors [post]
+func (h *SensorIngestHandler) Ingest(c echo.Context) error {
+	body := c.Request().Body
+	if h.maxBodyBytes > 0 {
+		body = http.MaxBytesReader(c.Response(), body, h.maxBodyBytes)
+	}
+	next, err := newReadingDecoder(c.Request().Header.Get(echo.HeaderContentType), body)
+	if err != nil {
+		var uerr *unsupportedTypeError
+		if errors.As(err, &uerr) {
+			return c.JSON(http.StatusUnsupportedMediaType, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	username, _ := c.Get("username").(string)
+	ingestedBy := "http:" + username
+	if len(ingestedBy) > 96 {
+		ingestedBy = ingestedBy[:96]
+	}
+
+	summary := dto.IngestSummary{Results: []dto.IngestResult{}}
+	var (
+		chunk []*domain.SensorData
+		lines []int
+	)
+	flush := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		defer func() { chunk, lines = chunk[:0], lines[:0] }()
+		itemErrs, res, err := h.pipeline.IngestBatch(chunk)
+		if err != nil {
+			// transaksi di-rollback, semua data di chunk ini belum tersimpan
+			for _, line := range lines {
+				summary.Results = append(summary.Results, dto.IngestResult{Line: line, Status: "failed", Message: "not stored"})
+			}
+			summary.Failed += len(lines)
+			return err
+		}
+		// duplikat dihitung per baris dari itemErrs
+		summary.Stored += res.Inserted
+		for i, line := range lines {
+			r := dto.IngestResult{Line: line, Status: "stored"}
+			switch err := itemErrs[i]; {
+			case err == nil:
+			case errors.Is(err, usecase.ErrDuplicate):
+				r.Status = "duplicate"
+				summary.Duplicates++
+			default:
+				r.Status, r.Message = "rejected", err.Error()
+				summary.Rejected++
+			}
+			summary.Results = append(summary.Results, r)
+		}
+		return nil
+	}
+	respond := func(code int) error {
+		slices.SortFunc(summary.Results, func(a, b dto.IngestResult) int { return a.Line - b.Line })
+		log.Printf("HTTP ingest from %q: %d stored, %d duplicates, %d rejected, %d failed", username, summary.Stored, summary.Duplicates, summary.Rejected, summary.Failed)
+		return c.JSON(code, summary)
+	}
+
+	for {
+		line, rec, err := next()
+		if err == io.EOF {
+			break
+		}
+		var lerr *lineError
+		if errors.As(err, &lerr) {
+			summary.Results = append(summary.Results, dto.IngestResult{Line: line, Status: "rejected", Message: lerr.Error()})
+			summary.Rejected++
+			continue
+		}
+		if err != nil {
+			// body tidak bisa dibaca lagi; data yang sudah terbaca tetap disimpan
+			code := http.StatusBadRequest
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				code = http.StatusRequestEntityTooLarge
+				err = fmt.Errorf("body larger than %d bytes", maxErr.Limit)
+			}
+			summary.Error = fmt.Sprintf("stopped after line %d: %v", line, err)
+			if serr := flush(); serr != nil {
+				summary.Error += fmt.Sprintf("; readings not stored: %v", serr)
+			}
+			return respond(code)
+		}
+
+		chunk = append(chunk, readingToDomain(rec, ingestedBy))
+		lines = append(lines, line)
+		if len(chunk) >= ingestChunkSize {
+			if err := flush(); err != nil {
+				summary.Error = fmt.Sprintf("readings not stored: %v", err)
+				return respond(http.StatusServiceUnavailable)
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		summary.Error = fmt.Sprintf("readings not stored: %v", err)
+		return respond(http.StatusServiceUnavailable)
+	}
+	if len(summary.Results) == 0 {
+		summary.Error = "no readings in body"
+		return respond(http.StatusBadRequest)
+	}
+	return respond(http.StatusOK)
+}
+
+// readingToDomain sama dengan dto.SensorDataFromProto; timestamp yang tidak
+// bisa di-parse dibiarkan kosong supaya ditolak validator
+func readingToDomain(rec *dto.SensorReading, ingestedBy string) *domain.SensorData {
+	t, _ := time.Parse(time.RFC3339, rec.Timestamp)
+	sensor := &domain.SensorData{
+		SensorValue: rec.SensorValue,
+		SensorType:  rec.SensorType,
+		ID1:         rec.ID1,
+		ID2:         rec.ID2,
+		TS:          t,
+		CreatedAt:   time.Now(),
+		IngestedBy:  &ingestedBy,
+	}
+	if rec.ProducerID != "" {
+		producerID, seq := rec.ProducerID, rec.Seq
+		sensor.ProducerID = &producerID
+		sensor.Seq = &seq
+	}
+	return sensor
+}
+
+// readingDecoder membaca satu record per panggilan sampai io.EOF. Error
+// *lineError hanya mengenai record di baris itu; error lain artinya body
+// tidak bisa dibaca lebih lanjut.
+type readingDecoder func() (line int, rec *dto.SensorReading, err error)
+
+type lineError struct {
+	msg string
+}
+
+func (e *lineError) Error() string { return e.msg }
+
+type unsupportedTypeError struct {
+	contentType string
+}
+
+func (e *unsupportedTypeError) Error() string {
+	return fmt.Sprintf("unsupported content type %q (use application/json, application/x-ndjson or text/csv)", e.contentType)
+}
+
+func newReadingDecoder(contentType string, body io.Reader) (readingDecoder, error) {
+	mediaType := "application/json"
+	if contentType != "" {
+		var err error
+		if mediaType, _, err = mime.ParseMediaType(contentType); err != nil {
+			return nil, &unsupportedTypeError{contentType: contentType}
+		}
+	}
+	switch mediaType {
+	case "application/json":
+		return jsonDecoder(body), nil
+	case "application/x-ndjson", "application/ndjson", "application/jsonl", "application/x-jsonlines":
+		return ndjsonDecoder(body), nil
+	case "text/csv":
+		return csvDecoder(body)
+	default:
+		return nil, &unsupportedTypeError{contentType: contentType}
+	}
+}
+
+// jsonDecoder untuk satu object, array of object, atau beberapa object
+// berurutan. Array dibaca per elemen, tidak di-decode sekaligus.
+func jsonDecoder(body io.Reader) readingDecoder {
+	br := bufio.NewReader(body)
+	dec := json.NewDecoder(br)
+	var started, inArray bool
+	n := 0
+	return func() (int, *dto.SensorReading, error) {
+		if !started {
+			started = true
+			first, err := peekNonSpace(br)
+			if err != nil {
+				return n, nil, err
+			}
+			if first == '[' {
+				if _, err := dec.Token(); err != nil {
+					return n, nil, err
+				}
+				inArray = true
+			}
+		}
+		if inArray && !dec.More() {
+			if _, err := dec.Token(); err != nil { // ']'
+				return n, nil, err
+			}
+			return n, nil, io.EOF
+		}
+
+		n++
+		var rec dto.SensorReading
+		if err := dec.Decode(&rec); err != nil {
+			if err == io.EOF && !inArray {
+				return n - 1, nil, io.EOF
+			}
+			// tipe field salah tidak merusak sisa stream
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &typeErr) {
+				return n, nil, &lineError{msg: fmt.Sprintf("invalid JSON: %v", err)}
+			}
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return n - 1, nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+		return n, &rec, nil
+	}
+}
+
+// ndjsonDecoder satu object JSON per baris, baris kosong dan BOM di awal body dilewati
+func ndjsonDecoder(body io.Reader) readingDecoder {
+	br := bufio.NewReader(body)
+	n := 0
+	return func() (int, *dto.SensorReading, error) {
+		for {
+			raw, err := br.ReadBytes('\n')
+			if err != nil && err != io.EOF {
+				return n, nil, err
+			}
+			if len(raw) == 0 && err == io.EOF {
+				return n, nil, io.EOF
+			}
+			n++
+			if n == 1 {
+				raw = bytes.TrimPrefix(raw, []byte("\ufeff"))
+			}
+			raw = bytes.TrimSpace(raw)
+			if len(raw) == 0 {
+				continue
+			}
+			var rec dto.SensorReading
+			if err := json.Unmarshal(raw, &rec); err != nil {
+				return n, nil, &lineError{msg: fmt.Sprintf("invalid JSON: %v", err)}
+			}
+			return n, &rec, nil
+		}
+	}
+}
+
+// csvDecoder CSV dengan baris header; urutan kolom bebas, producer_id dan
+// seq opsional
+func csvDecoder(body io.Reader) (readingDecoder, error) {
+	cr := csv.NewReader(body)
+	cr.TrimLeadingSpace = true
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, errors.New("CSV body is empty")
+		}
+		return nil, fmt.Errorf("invalid CSV header: %w", err)
+	}
+	cols := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		switch name {
+		case "sensor_value", "sensor_type", "id1", "id2", "timestamp", "producer_id", "seq":
+			cols[name] = i
+		default:
+			return nil, fmt.Errorf("unknown CSV column %q", name)
+		}
+	}
+	for _, name := range []string{"sensor_value", "sensor_type", "id1", "id2", "timestamp"} {
+		if _, ok := cols[name]; !ok {
+			return nil, fmt.Errorf("CSV header is missing column %q", name)
+		}
+	}
+	field := func(row []string, name string) string {
+		if i, ok := cols[name]; ok {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	lastLine := 1
+	return func() (int, *dto.SensorReading, error) {
+		row, err := cr.Read()
+		if err != nil {
+			var perr *csv.ParseError
+			if errors.As(err, &perr) {
+				return perr.StartLine, nil, &lineError{msg: perr.Err.Error()}
+			}
+			return lastLine, nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		lastLine = line
+
+		rec := &dto.SensorReading{
+			SensorType: field(row, "sensor_type"),
+			ID1:        field(row, "id1"),
+			Timestamp:  field(row, "timestamp"),
+			ProducerID: field(row, "producer_id"),
+		}
+		if rec.SensorValue, err = strconv.ParseFloat(field(row, "sensor_value"), 64); err != nil {
+			return line, nil, &lineError{msg: "invalid sensor_value"}
+		}
+		if rec.ID2, err = strconv.Atoi(field(row, "id2")); err != nil {
+			return line, nil, &lineError{msg: "invalid id2"}
+		}
+		if seq := field(row, "seq"); seq != "" {
+			if rec.Seq, err = strconv.ParseUint(seq, 10, 64); err != nil {
+				return line, nil, &lineError{msg: "invalid seq"}
+			}
+		}
+		return line, rec, nil
+	}, nil
+}
+
+// peekNonSpace melewati whitespace (dan BOM) lalu mengintip byte pertama tanpa membacanya
+func peekNonSpace(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			br.ReadByte()
+		case 0xEF: // BOM UTF-8
+			if bom, _ := br.Peek(3); bytes.Equal(bom, []byte("\ufeff")) {
+				br.Discard(3)
+				continue
+			}
+			return b[0], nil
+		default:
+			return b[0], nil
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/domain"
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/dto"
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/usecase"
+)
+
+// decoded satu hasil decoder: id1 record, atau pesan *lineError
+type decoded struct {
+	line    int
+	id1     string
+	lineErr bool
+}
+
+// decodeAll membaca sampai io.EOF atau error yang menghentikan body
+func decodeAll(next readingDecoder) ([]decoded, error) {
+	var out []decoded
+	for {
+		line, rec, err := next()
+		if err == io.EOF {
+			return out, nil
+		}
+		var lerr *lineError
+		if errors.As(err, &lerr) {
+			out = append(out, decoded{line: line, lineErr: true})
+			continue
+		}
+		if err != nil {
+			return out, err
+		}
+		out = append(out, decoded{line: line, id1: rec.ID1})
+	}
+}
+
+func TestReadingDecoder(t *testing.T) {
+	const (
+		jsonType   = "application/json"
+		ndjsonType = "application/x-ndjson"
+		csvType    = "text/csv"
+		csvHeader  = "sensor_value,sensor_type,id1,id2,timestamp,producer_id,seq\n"
+	)
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        []decoded
+		wantErr     bool // body berhenti dibaca di tengah jalan
+		wantInitErr bool // decoder tidak bisa dibuat
+	}{
+		{name: "json object", contentType: jsonType, body: `{"id1":"A"}`, want: []decoded{{line: 1, id1: "A"}}},
+		{name: "json default content type", body: `{"id1":"A"}`, want: []decoded{{line: 1, id1: "A"}}},
+		{name: "json array", contentType: jsonType, body: ` [{"id1":"A"}, {"id1":"B"}] `, want: []decoded{{line: 1, id1: "A"}, {line: 2, id1: "B"}}},
+		{name: "json array with BOM", contentType: jsonType, body: "\ufeff[{\"id1\":\"A\"}]", want: []decoded{{line: 1, id1: "A"}}},
+		{name: "json concatenated objects", contentType: jsonType, body: "{\"id1\":\"A\"}\n{\"id1\":\"B\"}\n", want: []decoded{{line: 1, id1: "A"}, {line: 2, id1: "B"}}},
+		{name: "json empty array", contentType: jsonType, body: `[]`},
+		{name: "json empty body", contentType: jsonType, body: "  \n"},
+		{
+			name:        "json wrong field type skips one element",
+			contentType: jsonType,
+			body:        `[{"id1":"A"},{"id2":"two"},{"id1":"C"}]`,
+			want:        []decoded{{line: 1, id1: "A"}, {line: 2, lineErr: true}, {line: 3, id1: "C"}},
+		},
+		{
+			name:        "json torn array",
+			contentType: jsonType,
+			body:        `[{"id1":"A"},{"id1":"B"`,
+			want:        []decoded{{line: 1, id1: "A"}},
+			wantErr:     true,
+		},
+		{name: "json syntax error", contentType: jsonType, body: `[{"id1":"A"} {"id1":"B"}]`, want: []decoded{{line: 1, id1: "A"}}, wantErr: true},
+		{
+			name:        "ndjson skips blank lines",
+			contentType: ndjsonType,
+			body:        "{\"id1\":\"A\"}\r\n\r\n{\"id1\":\"B\"}",
+			want:        []decoded{{line: 1, id1: "A"}, {line: 3, id1: "B"}},
+		},
+		{
+			name:        "ndjson with BOM",
+			contentType: "application/x-ndjson; charset=utf-8",
+			body:        "\ufeff{\"id1\":\"A\"}\n{\"id1\":\"B\"}\n",
+			want:        []decoded{{line: 1, id1: "A"}, {line: 2, id1: "B"}},
+		},
+		{
+			name:        "ndjson bad line",
+			contentType: ndjsonType,
+			body:        "{\"id1\":\"A\"}\nnot json\n{\"id1\":\"C\"",
+			want:        []decoded{{line: 1, id1: "A"}, {line: 2, lineErr: true}, {line: 3, lineErr: true}},
+		},
+		{
+			name:        "csv",
+			contentType: csvType,
+			body:        csvHeader + "21.5,temperature,A,1,2026-01-02T03:04:05Z,gw-1,1\n22,temperature,B,2,2026-01-02T03:04:06Z,,\n",
+			want:        []decoded{{line: 2, id1: "A"}, {line: 3, id1: "B"}},
+		},
+		{
+			name:        "csv with BOM and reordered columns",
+			contentType: csvType,
+			body:        "\ufeffID1,timestamp,id2,sensor_type,sensor_value\nA,2026-01-02T03:04:05Z,1,temperature,21.5\n",
+			want:        []decoded{{line: 2, id1: "A"}},
+		},
+		{
+			name:        "csv bad rows",
+			contentType: csvType,
+			body: csvHeader +
+				"21.5,temperature,A,1,2026-01-02T03:04:05Z,gw-1,1\n" +
+				"warm,temperature,B,1,2026-01-02T03:04:05Z,gw-1,2\n" +
+				"21.5,temperature,C\n" +
+				"21.5,temperature,D\"x,1,2026-01-02T03:04:05Z,gw-1,3\n" +
+				"21.5,temperature,E,1,2026-01-02T03:04:05Z,gw-1,-4\n" +
+				"21.5,temperature,F,1,2026-01-02T03:04:05Z,gw-1,5\n",
+			want: []decoded{
+				{line: 2, id1: "A"},
+				{line: 3, lineErr: true},
+				{line: 4, lineErr: true},
+				{line: 5, lineErr: true},
+				{line: 6, lineErr: true},
+				{line: 7, id1: "F"},
+			},
+		},
+		{name: "csv empty body", contentType: csvType, body: "", wantInitErr: true},
+		{name: "csv missing column", contentType: csvType, body: "sensor_value,sensor_type,id1,id2\n", wantInitErr: true},
+		{name: "csv unknown column", contentType: csvType, body: "sensor_value,sensor_type,id1,id2,timestamp,site\n", wantInitErr: true},
+		{name: "unsupported type", contentType: "application/xml", body: "<a/>", wantInitErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next, err := newReadingDecoder(tt.contentType, strings.NewReader(tt.body))
+			if tt.wantInitErr {
+				if err == nil {
+					t.Fatal("want decoder error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decoder: %v", err)
+			}
+			got, err := decodeAll(next)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, wantErr %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// ingestRepo meniru idx_producer_seq; producer dan ts di test ini selalu sama,
+// jadi cukup seq: yang sudah ada di seen, termasuk dari baris sebelumnya di
+// batch yang sama, duplikat
+type ingestRepo struct {
+	domain.SensorRepository
+	seen   map[uint64]bool
+	stored int
+}
+
+func (r *ingestRepo) StoreBatch(sensors []*domain.SensorData) (domain.BatchResult, error) {
+	if r.seen == nil {
+		r.seen = map[uint64]bool{}
+	}
+	var res domain.BatchResult
+	flags := make([]bool, len(sensors))
+	for i, s := range sensors {
+		if r.seen[*s.Seq] {
+			flags[i] = true
+			res.Duplicates++
+			continue
+		}
+		r.seen[*s.Seq] = true
+		res.Inserted++
+	}
+	if res.Duplicates > 0 {
+		res.Duplicate = flags
+	}
+	r.stored += res.Inserted
+	return res, nil
+}
+
+func postReadings(t *testing.T, repo *ingestRepo, maxBody int64, contentType, body string) (int, dto.IngestSummary) {
+	t.Helper()
+	pipeline := usecase.NewIngestPipeline(repo, usecase.NewDeduplicator(100), nil, nil, nil, nil, usecase.IngestConfig{})
+	defer pipeline.Close()
+	e := echo.New()
+	NewSensorIngestHandler(e.Group("/api"), pipeline, maxBody)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/sensors", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, contentType)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	var summary dto.IngestSummary
+	if err := json.Unmarshal(rec.Body.Bytes(), &summary); err != nil {
+		t.Fatalf("decode response %q: %v", rec.Body.String(), err)
+	}
+	return rec.Code, summary
+}
+
+func ndjsonLine(id1 string, seq int) string {
+	return `{"sensor_value":21.5,"sensor_type":"temperature","id1":"` + id1 + `","id2":1,"timestamp":"2026-01-02T03:04:05Z","producer_id":"gw-1","seq":` + strconv.Itoa(seq) + "}\n"
+}
+
+func TestIngestDuplicateStatusPerRow(t *testing.T) {
+	// seq 2 sudah ada di database, seq 1 terkirim dua kali di body yang sama
+	repo := &ingestRepo{seen: map[uint64]bool{2: true}}
+	body := ndjsonLine("A", 1) + ndjsonLine("A", 2) + ndjsonLine("A", 3) + ndjsonLine("A", 1)
+	code, summary := postReadings(t, repo, 0, "application/x-ndjson", body)
+	if code != http.StatusOK {
+		t.Fatalf("status %d: %+v", code, summary)
+	}
+
+	want := []string{"stored", "duplicate", "stored", "duplicate"}
+	for i, r := range summary.Results {
+		if r.Line != i+1 || r.Status != want[i] {
+			t.Errorf("line %d: %s, want line %d %s", r.Line, r.Status, i+1, want[i])
+		}
+	}
+	if summary.Stored != 2 || summary.Duplicates != 2 || repo.stored != 2 {
+		t.Fatalf("stored %d duplicates %d (repo stored %d), want 2, 2 and 2", summary.Stored, summary.Duplicates, repo.stored)
+	}
+}
+
+func TestIngestMaxBytes(t *testing.T) {
+	line := ndjsonLine("A", 1)
+	body := line + ndjsonLine("A", 2) + ndjsonLine("A", 3) + ndjsonLine("A", 4)
+	repo := &ingestRepo{}
+	// cukup untuk dua baris pertama dan sebagian baris ketiga
+	code, summary := postReadings(t, repo, int64(2*len(line)+10), "application/x-ndjson", body)
+	if code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status %d, want 413: %+v", code, summary)
+	}
+	if summary.Stored != 2 || repo.stored != 2 {
+		t.Fatalf("stored %d (repo %d), want the 2 readings before the limit", summary.Stored, repo.stored)
+	}
+	if !strings.Contains(summary.Error, "stopped after line 2") || !strings.Contains(summary.Error, "larger than") {
+		t.Fatalf("error %q", summary.Error)
+	}
+}
@@ -0,0 +1,211 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/net/websocket"
+
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/domain"
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/usecase"
+)
+
+// StreamConfig pengaturan live feed /sensors/stream
+type StreamConfig struct {
+	// Heartbeat interval pesan heartbeat selama tidak ada data baru
+	Heartbeat time.Duration
+	// AllowedOrigins Origin browser yang boleh membuka WebSocket, "*" untuk
+	// semua. Kosong artinya hanya same-origin; client tanpa header Origin
+	// (bukan browser) selalu boleh.
+	AllowedOrigins []string
+}
+
+// streamMessage satu pesan live feed. Type "reading" membawa data dan ID
+// event; "reset" artinya sebagian data sejak last event ID sudah tidak bisa
+// dikirim ulang (ambil lewat GET /api/sensors); "heartbeat" menjaga koneksi;
+// "disconnect" dikirim sebelum server memutus koneksi.
+type streamMessage struct {
+	ID      uint64             `json:"id,omitempty"`
+	Type    string             `json:"type"`
+	Data    *domain.SensorData `json:"data,omitempty"`
+	Time    *time.Time         `json:"time,omitempty"`
+	Message string             `json:"message,omitempty"`
+}
+
+// Stream godoc
+// @Summary Live sensor feed
+// @Description Push every newly stored reading matching the filters. Uses Server-Sent Events, or WebSocket when the request is a WebSocket upgrade (one JSON message per event). Resume after a reconnect with the Last-Event-ID header or the last_event_id query param. Browsers that cannot set the Authorization header may pass the JWT as the access_token query param.
+// @Tags sensors
+// @Produce text/event-stream
+// @Param id1 query string false "ID1 filter"
+// @Param id2 query int false "ID2 filter"
+// @Param type query string false "Sensor type filter, comma separated"
+// @Param last_event_id query int false "Resume after this event ID"
+// @Success 200 {string} string "event stream"
+// @Failure 400 {object} map[string]string
+// @Failure 503 {object} map[string]string
+// @Router /sensors/stream [get]
+func (h *SensorHandler) Stream(c echo.Context) error {
+	filter := usecase.SubscriptionFilter{ID1: c.QueryParam("id1")}
+	if id2Str := c.QueryParam("id2"); id2Str != "" {
+		val, err := strconv.Atoi(id2Str)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id2"})
+		}
+		filter.ID2 = val
+	}
+	if types := c.QueryParam("type"); types != "" {
+		filter.SensorTypes = strings.Split(types, ",")
+	}
+
+	lastIDStr := c.Request().Header.Get("Last-Event-ID")
+	if lastIDStr == "" {
+		lastIDStr = c.QueryParam("last_event_id")
+	}
+	var lastID uint64
+	if lastIDStr != "" {
+		val, err := strconv.ParseUint(lastIDStr, 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid last event id"})
+		}
+		lastID = val
+	}
+
+	sub := h.hub.Subscribe(filter, lastID)
+	defer sub.Close()
+	if err := sub.Err(); err != nil {
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
+	}
+
+	username, _ := c.Get("username").(string)
+	log.Printf("Live feed for %q started (filter %+v, websocket %v)", username, filter, c.IsWebSocket())
+
+	// response sudah terkirim, error hanya di-log
+	var err error
+	if c.IsWebSocket() {
+		err = h.streamWebSocket(c, sub)
+	} else {
+		err = h.streamSSE(c, sub)
+	}
+	log.Printf("Live feed for %q stopped (%d dropped): %v", username, sub.Dropped(), err)
+	return nil
+}
+
+func (h *SensorHandler) streamSSE(c echo.Context, sub *usecase.Subscription) error {
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set(echo.HeaderConnection, "keep-alive")
+	res.Header().Set("X-Accel-Buffering", "no") // jangan di-buffer reverse proxy
+	res.WriteHeader(http.StatusOK)
+	res.Flush()
+
+	send := func(msg streamMessage) error {
+		var payload any = msg
+		if msg.Type == "reading" {
+			payload = msg.Data
+		}
+		body, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		if msg.ID > 0 {
+			fmt.Fprintf(res, "id: %d\n", msg.ID)
+		}
+		if _, err := fmt.Fprintf(res, "event: %s\ndata: %s\n\n", msg.Type, body); err != nil {
+			return err
+		}
+		res.Flush()
+		return nil
+	}
+	return h.pump(sub, c.Request().Context().Done(), send)
+}
+
+func (h *SensorHandler) streamWebSocket(c echo.Context, sub *usecase.Subscription) error {
+	var err error
+	// Handshake yang gagal dijawab 403 oleh websocket.Server
+	websocket.Server{Handshake: h.checkOrigin, Handler: func(ws *websocket.Conn) {
+		defer ws.Close()
+
+		// pesan dari client tidak dipakai, dibaca hanya untuk tahu kapan ditutup
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			var discard []byte
+			for websocket.Message.Receive(ws, &discard) == nil {
+			}
+		}()
+
+		send := func(msg streamMessage) error {
+			return websocket.JSON.Send(ws, msg)
+		}
+		err = h.pump(sub, closed, send)
+	}}.ServeHTTP(c.Response(), c.Request())
+	return err
+}
+
+// checkOrigin mencegah halaman dari domain lain membuka WebSocket atas nama
+// user yang sedang login
+func (h *SensorHandler) checkOrigin(_ *websocket.Config, r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	for _, allowed := range h.stream.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return nil
+		}
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return nil
+	}
+	return fmt.Errorf("origin %q not allowed", origin)
+}
+
+// pump mengirim event dari history lalu event baru sampai client pergi atau
+// subscriber diputus hub, dengan heartbeat selama tidak ada data
+func (h *SensorHandler) pump(sub *usecase.Subscription, clientGone <-chan struct{}, send func(streamMessage) error) error {
+	if sub.Missed {
+		if err := send(streamMessage{Type: "reset", Message: "some readings since the last event ID are no longer available"}); err != nil {
+			return err
+		}
+	}
+	for _, ev := range sub.Replay {
+		if err := send(streamMessage{ID: ev.ID, Type: "reading", Data: ev.Data}); err != nil {
+			return err
+		}
+	}
+
+	heartbeat := time.NewTicker(h.stream.Heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case ev := <-sub.C():
+			if err := send(streamMessage{ID: ev.ID, Type: "reading", Data: ev.Data}); err != nil {
+				return err
+			}
+		case now := <-heartbeat.C:
+			if err := send(streamMessage{Type: "heartbeat", Time: &now}); err != nil {
+				return err
+			}
+		case <-sub.Done():
+			err := sub.Err()
+			if err == nil {
+				err = errors.New("subscription closed")
+			}
+			// client boleh reconnect dengan last event ID untuk melanjutkan
+			_ = send(streamMessage{Type: "disconnect", Message: err.Error()})
+			return err
+		case <-clientGone:
+			return errors.New("client disconnected")
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package http
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/net/websocket"
+
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/domain"
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/usecase"
+)
+
+func streamReading(id1 string, seq uint64) *domain.SensorData {
+	return &domain.SensorData{SensorValue: float64(seq), SensorType: "temperature", ID1: id1, ID2: 1, TS: time.Unix(int64(seq), 0).UTC(), Seq: &seq}
+}
+
+// streamServer hub dengan history 2 yang sudah berisi data A, B, A; ids ID
+// event ketiga data itu
+func streamServer(t *testing.T, cfg StreamConfig) (srv *httptest.Server, hub *usecase.SensorHub, ids []uint64) {
+	t.Helper()
+	hub, err := usecase.NewSensorHub(10, 2, usecase.SlowConsumerDrop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := hub.Subscribe(usecase.SubscriptionFilter{}, 0)
+	hub.Publish([]*domain.SensorData{streamReading("A", 1), streamReading("B", 2), streamReading("A", 3)})
+	for range 3 {
+		ids = append(ids, (<-sub.C()).ID)
+	}
+	sub.Close()
+
+	h := &SensorHandler{hub: hub, stream: cfg}
+	e := echo.New()
+	e.GET("/stream", h.Stream)
+	srv = httptest.NewServer(e)
+	t.Cleanup(srv.Close)
+	return srv, hub, ids
+}
+
+// sseFrame satu event SSE, key nama field ("id", "event", "data")
+type sseFrame map[string]string
+
+func readFrame(t *testing.T, r *bufio.Reader) sseFrame {
+	t.Helper()
+	frame := sseFrame{}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read frame: %v (got %v)", err, frame)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return frame
+		}
+		field, value, _ := strings.Cut(line, ": ")
+		frame[field] = value
+	}
+}
+
+func openSSE(t *testing.T, url, lastEventID string) *bufio.Reader {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set(echo.HeaderAccept, "text/event-stream")
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { res.Body.Close() })
+	if res.StatusCode != http.StatusOK || res.Header.Get(echo.HeaderContentType) != "text/event-stream" {
+		t.Fatalf("status %d, content type %q", res.StatusCode, res.Header.Get(echo.HeaderContentType))
+	}
+	return bufio.NewReader(res.Body)
+}
+
+func wantReading(t *testing.T, f sseFrame, id uint64, id1 string) {
+	t.Helper()
+	if f["event"] != "reading" || f["id"] != fmt.Sprint(id) {
+		t.Fatalf("frame %v, want reading with id %d", f, id)
+	}
+	var data domain.SensorData
+	if err := json.Unmarshal([]byte(f["data"]), &data); err != nil {
+		t.Fatal(err)
+	}
+	if data.ID1 != id1 {
+		t.Fatalf("data id1 %q, want %q", data.ID1, id1)
+	}
+}
+
+func TestStreamSSE(t *testing.T) {
+	srv, hub, ids := streamServer(t, StreamConfig{Heartbeat: 50 * time.Millisecond})
+
+	// resume setelah ids[0]: ids[1] (B) tidak cocok filter, jadi hanya ids[2]
+	r := openSSE(t, srv.URL+"/stream?id1=A", fmt.Sprint(ids[0]))
+	wantReading(t, readFrame(t, r), ids[2], "A")
+
+	if f := readFrame(t, r); f["event"] != "heartbeat" || f["id"] != "" {
+		t.Fatalf("frame %v, want heartbeat without id", f)
+	}
+
+	hub.Publish([]*domain.SensorData{streamReading("B", 4), streamReading("A", 5)})
+	f := readFrame(t, r)
+	for f["event"] == "heartbeat" {
+		f = readFrame(t, r)
+	}
+	wantReading(t, f, ids[2]+2, "A")
+
+	hub.Close()
+	f = readFrame(t, r)
+	for f["event"] == "heartbeat" {
+		f = readFrame(t, r)
+	}
+	if f["event"] != "disconnect" || !strings.Contains(f["data"], usecase.ErrHubClosed.Error()) {
+		t.Fatalf("frame %v, want disconnect", f)
+	}
+}
+
+func TestStreamSSEReset(t *testing.T) {
+	srv, _, ids := streamServer(t, StreamConfig{Heartbeat: time.Minute})
+
+	// ids[0] sudah keluar dari history 2 kalau client terakhir menerima ids[0]-1
+	r := openSSE(t, srv.URL+"/stream", fmt.Sprint(ids[0]-1))
+	if f := readFrame(t, r); f["event"] != "reset" || f["id"] != "" {
+		t.Fatalf("frame %v, want reset without id", f)
+	}
+	wantReading(t, readFrame(t, r), ids[1], "B")
+	wantReading(t, readFrame(t, r), ids[2], "A")
+}
+
+func TestStreamBadRequest(t *testing.T) {
+	srv, _, _ := streamServer(t, StreamConfig{Heartbeat: time.Minute})
+	for _, query := range []string{"id2=x", "last_event_id=-1"} {
+		res, err := http.Get(srv.URL + "/stream?" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", query, res.StatusCode)
+		}
+	}
+}
+
+func TestStreamWebSocket(t *testing.T) {
+	srv, hub, ids := streamServer(t, StreamConfig{Heartbeat: 50 * time.Millisecond, AllowedOrigins: []string{"https://dashboard.example"}})
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + "/stream?type=temperature&last_event_id=" + fmt.Sprint(ids[1])
+
+	cfg, err := websocket.NewConfig(wsURL, "https://evil.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ws, err := websocket.DialConfig(cfg); err == nil {
+		ws.Close()
+		t.Fatal("websocket from a foreign origin accepted")
+	}
+
+	ws, err := websocket.Dial(wsURL, "", "https://dashboard.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	receive := func() streamMessage {
+		t.Helper()
+		ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+		var msg streamMessage
+		if err := websocket.JSON.Receive(ws, &msg); err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+
+	if msg := receive(); msg.Type != "reading" || msg.ID != ids[2] || msg.Data.ID1 != "A" {
+		t.Fatalf("message %+v, want replay of event %d", msg, ids[2])
+	}
+	if msg := receive(); msg.Type != "heartbeat" || msg.Time == nil {
+		t.Fatalf("message %+v, want heartbeat", msg)
+	}
+	hub.Close()
+	msg := receive()
+	for msg.Type == "heartbeat" {
+		msg = receive()
+	}
+	if msg.Type != "disconnect" {
+		t.Fatalf("message %+v, want disconnect", msg)
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		origin  string
+		wantOK  bool
+	}{
+		{"no origin", nil, "", true},
+		{"same origin", nil, "http://api.example:8080", true},
+		{"foreign origin", nil, "https://evil.example", false},
+		{"listed origin", []string{"https://dashboard.example"}, "https://dashboard.example", true},
+		{"listed origin ignores case", []string{"https://Dashboard.example"}, "https://dashboard.example", true},
+		{"other port", []string{"https://dashboard.example"}, "https://dashboard.example:8443", false},
+		{"wildcard", []string{"*"}, "https://evil.example", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &SensorHandler{stream: StreamConfig{AllowedOrigins: tt.allowed}}
+			req := httptest.NewRequest(http.MethodGet, "http://api.example:8080/api/sensors/stream", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if err := h.checkOrigin(nil, req); (err == nil) != tt.wantOK {
+				t.Fatalf("checkOrigin = %v, want ok %v", err, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/domain"
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/dto"
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/usecase"
+)
+
+type SensorTypeHandler struct {
+	usecase usecase.SensorTypeUsecase
+}
+
+// NewSensorTypeHandler mendaftarkan route katalog sensor type; semua user
+// boleh membaca, adminMw dipasang di route yang mengubah katalog
+func NewSensorTypeHandler(g *echo.Group, uc usecase.SensorTypeUsecase, adminMw ...echo.MiddlewareFunc) {
+	handler := &SensorTypeHandler{usecase: uc}
+
+	g.GET("/sensor-types", handler.List)                        // GET /api/sensor-types
+	g.GET("/sensor-types/:name", handler.Get)                   // GET /api/sensor-types/:name
+	g.POST("/sensor-types", handler.Create, adminMw...)         // POST /api/sensor-types
+	g.PUT("/sensor-types/:name", handler.Update, adminMw...)    // PUT /api/sensor-types/:name
+	g.DELETE("/sensor-types/:name", handler.Delete, adminMw...) // DELETE /api/sensor-types/:name
+}
+
+// List godoc
+// @Summary List sensor types
+// @Description List the sensor type catalog with units and valid ranges
+// @Tags sensor-types
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 500 {object} map[string]string
+// @Router /sensor-types [get]
+func (h *SensorTypeHandler) List(c echo.Context) error {
+	items, err := h.usecase.List()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if items == nil {
+		items = []*domain.SensorType{}
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"total": len(items),
+		"data":  items,
+	})
+}
+
+// Get godoc
+// @Summary Get a sensor type
+// @Description Get one sensor type from the catalog
+// @Tags sensor-types
+// @Produce json
+// @Param name path string true "Sensor type name"
+// @Success 200 {object} domain.SensorType
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /sensor-types/{name} [get]
+func (h *SensorTypeHandler) Get(c echo.Context) error {
+	st, err := h.usecase.Get(c.Param("name"))
+	if err != nil {
+		return sensorTypeError(c, err)
+	}
+	return c.JSON(http.StatusOK, st)
+}
+
+// Create godoc
+// @Summary Create a sensor type
+// @Description Add a sensor type to the catalog (admin only)
+// @Tags sensor-types
+// @Accept json
+// @Produce json
+// @Param request body dto.SensorTypeRequest true "Sensor type"
+// @Success 201 {object} domain.SensorType
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /sensor-types [post]
+func (h *SensorTypeHandler) Create(c echo.Context) error {
+	var req dto.SensorTypeRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid body"})
+	}
+	st := toSensorType(req)
+	if err := h.usecase.Create(st); err != nil {
+		return sensorTypeError(c, err)
+	}
+	return c.JSON(http.StatusCreated, st)
+}
+
+// Update godoc
+// @Summary Update a sensor type
+// @Description Replace unit, description, range and precision of a sensor type (admin only)
+// @Tags sensor-types
+// @Accept json
+// @Produce json
+// @Param name path string true "Sensor type name"
+// @Param request body dto.SensorTypeRequest true "Sensor type (name is taken from the path)"
+// @Success 200 {object} domain.SensorType
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /sensor-types/{name} [put]
+func (h *SensorTypeHandler) Update(c echo.Context) error {
+	var req dto.SensorTypeRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid body"})
+	}
+	req.Name = c.Param("name")
+	if err := h.usecase.Update(toSensorType(req)); err != nil {
+		return sensorTypeError(c, err)
+	}
+	st, err := h.usecase.Get(req.Name)
+	if err != nil {
+		return sensorTypeError(c, err)
+	}
+	return c.JSON(http.StatusOK, st)
+}
+
+// Delete godoc
+// @Summary Delete a sensor type
+// @Description Remove a sensor type from the catalog; new readings of this type will be rejected (admin only)
+// @Tags sensor-types
+// @Produce json
+// @Param name path string true "Sensor type name"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /sensor-types/{name} [delete]
+func (h *SensorTypeHandler) Delete(c echo.Context) error {
+	if err := h.usecase.Delete(c.Param("name")); err != nil {
+		return sensorTypeError(c, err)
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"deleted": 1,
+	})
+}
+
+func toSensorType(req dto.SensorTypeRequest) *domain.SensorType {
+	return &domain.SensorType{
+		Name:        req.Name,
+		Unit:        req.Unit,
+		Description: req.Description,
+		MinValue:    req.MinValue,
+		MaxValue:    req.MaxValue,
+		Precision:   req.Precision,
+	}
+}
+
+func sensorTypeError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrSensorTypeNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, domain.ErrSensorTypeExists):
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	case errors.Is(err, usecase.ErrInvalidSensorType):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+}
@@ -1,6 +1,7 @@
 package http
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/labstack/echo/v4"
@@ -21,9 +22,17 @@ func NewUserHandler(e *echo.Echo, uc usecase.UserUsecase, jwt *auth.JWTManager)
 	e.POST("/login", handler.Login)
 }
 
+// NewUserAdminHandler mendaftarkan route pembuatan akun oleh admin; mw
+// dipasang per route (JWTAuth khusus admin)
+func NewUserAdminHandler(g *echo.Group, uc usecase.UserUsecase, mw ...echo.MiddlewareFunc) {
+	handler := &UserHandler{uc: uc}
+
+	g.POST("/users", handler.Create, mw...) // POST /api/users
+}
+
 // Register godoc
 // @Summary Register new user
-// @Description Create a new user account
+// @Description Create a new account with role user. Producer and admin accounts are created by an admin via POST /api/users.
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -37,12 +46,37 @@ func (h *UserHandler) Register(c echo.Context) error {
 	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid body"})
 	}
-	if err := h.uc.Register(req.Username, req.Password, req.Role); err != nil {
+	if err := h.uc.Register(req.Username, req.Password, usecase.RoleUser); err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
 	return c.JSON(http.StatusOK, map[string]string{"status": "registered"})
 }
 
+// Create godoc
+// @Summary Create user
+// @Description Create an account with any role (user, producer or admin); admin only
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body dto.CreateUserRequest true "Create User Request"
+// @Success 201 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users [post]
+func (h *UserHandler) Create(c echo.Context) error {
+	var req dto.CreateUserRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid body"})
+	}
+	if err := h.uc.Register(req.Username, req.Password, req.Role); err != nil {
+		if errors.Is(err, usecase.ErrInvalidRole) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusCreated, map[string]string{"status": "created", "role": req.Role})
+}
+
 // Login godoc
 // @Summary Login user
 // @Description Authenticate user with username and password
@@ -12,6 +12,12 @@ func JWTAuth(jwtManager *auth.JWTManager, allowedRoles ...string) echo.Middlewar
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			authHeader := c.Request().Header.Get("Authorization")
+			if authHeader == "" && isStreamRequest(c) {
+				// EventSource dan WebSocket di browser tidak bisa mengirim header Authorization
+				if token := c.QueryParam("access_token"); token != "" {
+					authHeader = "Bearer " + token
+				}
+			}
 			if authHeader == "" {
 				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "missing token"})
 			}
@@ -48,3 +54,9 @@ func JWTAuth(jwtManager *auth.JWTManager, allowedRoles ...string) echo.Middlewar
 		}
 	}
 }
+
+// isStreamRequest request SSE atau WebSocket, satu-satunya yang boleh membawa
+// token lewat query param
+func isStreamRequest(c echo.Context) bool {
+	return c.IsWebSocket() || strings.Contains(c.Request().Header.Get(echo.HeaderAccept), "text/event-stream")
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/infrastructure/auth"
+)
+
+func TestJWTAuth(t *testing.T) {
+	jwt := auth.NewJWTManager("secret", time.Hour)
+	userToken, _ := jwt.Generate("alice", "user")
+	producerToken, _ := jwt.Generate("gw", "producer")
+
+	e := echo.New()
+	e.GET("/x", func(c echo.Context) error {
+		return c.String(http.StatusOK, c.Get("username").(string))
+	}, JWTAuth(jwt, "admin", "user"))
+
+	tests := []struct {
+		name   string
+		query  string
+		header map[string]string
+		want   int
+	}{
+		{"bearer header", "", map[string]string{"Authorization": "Bearer " + userToken}, http.StatusOK},
+		{"missing token", "", nil, http.StatusUnauthorized},
+		{"not bearer", "", map[string]string{"Authorization": "Basic " + userToken}, http.StatusUnauthorized},
+		{"invalid token", "", map[string]string{"Authorization": "Bearer nope"}, http.StatusUnauthorized},
+		{"role not allowed", "", map[string]string{"Authorization": "Bearer " + producerToken}, http.StatusForbidden},
+		// access_token hanya untuk SSE dan WebSocket, tidak untuk request biasa
+		{"query token on plain request", "?access_token=" + userToken, nil, http.StatusUnauthorized},
+		{"query token on json request", "?access_token=" + userToken, map[string]string{"Accept": "application/json"}, http.StatusUnauthorized},
+		{"query token on sse", "?access_token=" + userToken, map[string]string{"Accept": "text/event-stream"}, http.StatusOK},
+		{"query token on websocket", "?access_token=" + userToken, map[string]string{"Upgrade": "websocket", "Connection": "Upgrade"}, http.StatusOK},
+		{"query token still role checked", "?access_token=" + producerToken, map[string]string{"Accept": "text/event-stream"}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/x"+tt.query, nil)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Fatalf("status %d, want %d (%s)", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
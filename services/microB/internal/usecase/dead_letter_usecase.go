@@ -0,0 +1,91 @@
+package usecase
+
+import (
+	"errors"
+
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/domain"
+)
+
+type DeadLetterUsecase interface {
+	List() ([]*domain.DeadLetter, error)
+	Get(id string) (*domain.DeadLetter, error)
+	// Replay menyimpan ulang batch lewat pipeline ingest (validasi, dedup,
+	// live feed). Kalau transaksi berhasil dead letter dihapus; record yang
+	// ditolak validator tidak akan pernah lolos jadi ikut dibuang dan
+	// dilaporkan di Rejected.
+	Replay(id string) (ReplayResult, error)
+	Purge(id string) error
+	PurgeAll() (int, error)
+}
+
+// ReplayResult hasil replay satu dead letter
+type ReplayResult struct {
+	Inserted   int            `json:"inserted"`
+	Duplicates int            `json:"duplicates"`
+	Rejected   []ReplayReject `json:"rejected"`
+}
+
+// ReplayReject record dead letter yang ditolak validator saat replay
+type ReplayReject struct {
+	Index   int     `json:"index"` // posisi di Records dead letter
+	Seq     *uint64 `json:"seq,omitempty"`
+	Reason  string  `json:"reason"`
+	Message string  `json:"message"`
+}
+
+type deadLetterUsecase struct {
+	repo     domain.DeadLetterRepository
+	pipeline IngestPipeline
+}
+
+func NewDeadLetterUsecase(repo domain.DeadLetterRepository, pipeline IngestPipeline) DeadLetterUsecase {
+	return &deadLetterUsecase{repo: repo, pipeline: pipeline}
+}
+
+func (u *deadLetterUsecase) List() ([]*domain.DeadLetter, error) {
+	return u.repo.List()
+}
+
+func (u *deadLetterUsecase) Get(id string) (*domain.DeadLetter, error) {
+	return u.repo.Get(id)
+}
+
+func (u *deadLetterUsecase) Replay(id string) (ReplayResult, error) {
+	dl, err := u.repo.Get(id)
+	if err != nil {
+		return ReplayResult{}, err
+	}
+	// data yang sempat dikirim ulang producer akan terhitung duplikat
+	itemErrs, res, err := u.pipeline.IngestBatch(dl.Records)
+	if err != nil {
+		return ReplayResult{}, err
+	}
+
+	out := ReplayResult{Inserted: res.Inserted, Rejected: []ReplayReject{}}
+	for i, itemErr := range itemErrs {
+		var verr *ValidationError
+		switch {
+		case itemErr == nil:
+		case errors.Is(itemErr, ErrDuplicate):
+			out.Duplicates++
+		case errors.As(itemErr, &verr):
+			out.Rejected = append(out.Rejected, ReplayReject{
+				Index:   i,
+				Seq:     dl.Records[i].Seq,
+				Reason:  verr.Reason,
+				Message: verr.Message,
+			})
+		default:
+			out.Rejected = append(out.Rejected, ReplayReject{Index: i, Seq: dl.Records[i].Seq, Message: itemErr.Error()})
+		}
+	}
+	return out, u.repo.Delete(id)
+}
+
+func (u *deadLetterUsecase) Purge(id string) error {
+	return u.repo.Delete(id)
+}
+
+func (u *deadLetterUsecase) PurgeAll() (int, error) {
+	return u.repo.DeleteAll()
+}
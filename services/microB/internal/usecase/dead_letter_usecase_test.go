@@ -0,0 +1,119 @@
+package usecase
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/domain"
+)
+
+type fakeDeadLetters struct {
+	domain.DeadLetterRepository
+	items map[string]*domain.DeadLetter
+}
+
+func (r *fakeDeadLetters) Get(id string) (*domain.DeadLetter, error) {
+	dl, ok := r.items[id]
+	if !ok {
+		return nil, domain.ErrDeadLetterNotFound
+	}
+	return dl, nil
+}
+
+func (r *fakeDeadLetters) Delete(id string) error {
+	if _, ok := r.items[id]; !ok {
+		return domain.ErrDeadLetterNotFound
+	}
+	delete(r.items, id)
+	return nil
+}
+
+type fakeTypes map[string]*domain.SensorType
+
+func (t fakeTypes) Lookup(name string) (*domain.SensorType, bool) {
+	st, ok := t[name]
+	return st, ok
+}
+
+func TestDeadLetterReplay(t *testing.T) {
+	validator, err := NewValidator(ValidationConfig{ID2Min: 0, ID2Max: 100, MaxFuture: 24 * time.Hour}, fakeTypes{
+		"temperature": {Name: "temperature", MinValue: -50, MaxValue: 150},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	good, invalid, dup := reading(1), reading(2), reading(3)
+	invalid.ID1 = "bad id"
+
+	tests := []struct {
+		name        string
+		storeErr    error
+		wantErr     bool
+		wantKept    bool
+		wantResult  ReplayResult
+		wantStored  int
+		wantRejects []int // index record yang ditolak
+	}{
+		{
+			name:        "stored through pipeline",
+			wantResult:  ReplayResult{Inserted: 1, Duplicates: 1},
+			wantStored:  1,
+			wantRejects: []int{1},
+		},
+		{
+			name:     "store fails",
+			storeErr: errors.New("database down"),
+			wantErr:  true,
+			wantKept: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeSensorRepo{err: tt.storeErr}
+			dedup := NewDeduplicator(100)
+			dedup.MarkCommitted([]*domain.SensorData{dup})
+			p := NewIngestPipeline(repo, dedup, validator, nil, nil, nil, IngestConfig{})
+			defer p.Close()
+			dls := &fakeDeadLetters{items: map[string]*domain.DeadLetter{
+				"dl-1": {ID: "dl-1", Records: []*domain.SensorData{good, invalid, dup}},
+			}}
+
+			res, err := NewDeadLetterUsecase(dls, p).Replay("dl-1")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Replay error %v, wantErr %v", err, tt.wantErr)
+			}
+			if _, kept := dls.items["dl-1"]; kept != tt.wantKept {
+				t.Fatalf("dead letter kept %v, want %v", kept, tt.wantKept)
+			}
+			if got := repo.Stored(); got != tt.wantStored {
+				t.Fatalf("stored %d records, want %d", got, tt.wantStored)
+			}
+			if tt.wantErr {
+				return
+			}
+			if res.Inserted != tt.wantResult.Inserted || res.Duplicates != tt.wantResult.Duplicates {
+				t.Fatalf("result %+v, want %+v", res, tt.wantResult)
+			}
+			if len(res.Rejected) != len(tt.wantRejects) {
+				t.Fatalf("rejected %+v, want indexes %v", res.Rejected, tt.wantRejects)
+			}
+			for i, idx := range tt.wantRejects {
+				r := res.Rejected[i]
+				if r.Index != idx || r.Reason != RejectInvalidID1 || r.Seq == nil || *r.Seq != 2 {
+					t.Fatalf("reject %+v, want index %d reason %s seq 2", r, idx, RejectInvalidID1)
+				}
+			}
+		})
+	}
+}
+
+func TestDeadLetterReplayNotFound(t *testing.T) {
+	p := NewIngestPipeline(&fakeSensorRepo{}, NewDeduplicator(10), nil, nil, nil, nil, IngestConfig{})
+	defer p.Close()
+	_, err := NewDeadLetterUsecase(&fakeDeadLetters{items: map[string]*domain.DeadLetter{}}, p).Replay("missing")
+	if !errors.Is(err, domain.ErrDeadLetterNotFound) {
+		t.Fatalf("Replay error %v, want ErrDeadLetterNotFound", err)
+	}
+}
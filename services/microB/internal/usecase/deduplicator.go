@@ -0,0 +1,83 @@
+package usecase
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/domain"
+)
+
+// Deduplicator mengingat (producer_id, seq, ts) yang sudah ter-commit supaya
+// pesan yang dikirim ulang producer setelah reconnect bisa dibuang sebelum
+// masuk buffer. Kapasitasnya terbatas (FIFO); duplikat yang lolos dari
+// cache tetap ditangkap oleh unique key di database.
+type Deduplicator struct {
+	mu       sync.Mutex
+	seen     map[domain.DedupKey]struct{}
+	order    []domain.DedupKey
+	next     int
+	capacity int
+
+	duplicates atomic.Uint64
+}
+
+func NewDeduplicator(capacity int) *Deduplicator {
+	return &Deduplicator{
+		seen:     make(map[domain.DedupKey]struct{}, capacity),
+		order:    make([]domain.DedupKey, 0, capacity),
+		capacity: capacity,
+	}
+}
+
+// IsDuplicate mengembalikan true (dan menambah counter) kalau data ini
+// sudah pernah ter-commit
+func (d *Deduplicator) IsDuplicate(s *domain.SensorData) bool {
+	key, ok := s.DedupKey()
+	if !ok || d.capacity <= 0 {
+		return false
+	}
+	d.mu.Lock()
+	_, dup := d.seen[key]
+	d.mu.Unlock()
+	if dup {
+		d.duplicates.Add(1)
+	}
+	return dup
+}
+
+// MarkCommitted dipanggil setelah StoreBatch sukses
+func (d *Deduplicator) MarkCommitted(sensors []*domain.SensorData) {
+	if d.capacity <= 0 {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, s := range sensors {
+		key, ok := s.DedupKey()
+		if !ok {
+			continue
+		}
+		if _, exists := d.seen[key]; exists {
+			continue
+		}
+		if len(d.order) < d.capacity {
+			d.order = append(d.order, key)
+		} else {
+			// buang key paling lama
+			delete(d.seen, d.order[d.next])
+			d.order[d.next] = key
+			d.next = (d.next + 1) % d.capacity
+		}
+		d.seen[key] = struct{}{}
+	}
+}
+
+// AddDuplicates menambah counter untuk duplikat yang ditemukan di database
+func (d *Deduplicator) AddDuplicates(n int) {
+	d.duplicates.Add(uint64(n))
+}
+
+// Duplicates total duplikat yang dibuang sejak start
+func (d *Deduplicator) Duplicates() uint64 {
+	return d.duplicates.Load()
+}
@@ -0,0 +1,54 @@
+package usecase
+
+import (
+	"testing"
+	"time"
+
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/domain"
+)
+
+func TestDeduplicatorKey(t *testing.T) {
+	committed := reading(1)
+	d := NewDeduplicator(10)
+	d.MarkCommitted([]*domain.SensorData{committed})
+
+	sameInstant := reading(1)
+	sameInstant.TS = committed.TS.In(time.FixedZone("WIB", 7*60*60))
+	otherTS := reading(1)
+	otherTS.TS = committed.TS.Add(time.Second)
+	otherProducer := reading(1)
+	producer := "gw-2"
+	otherProducer.ProducerID = &producer
+	noProducer := reading(1)
+	noProducer.ProducerID = nil
+
+	tests := []struct {
+		name string
+		data *domain.SensorData
+		want bool
+	}{
+		{"resend", reading(1), true},
+		{"same instant in another zone", sameInstant, true},
+		{"same seq with another ts", otherTS, false},
+		{"same seq from another producer", otherProducer, false},
+		{"without producer_id", noProducer, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := d.IsDuplicate(tt.data); got != tt.want {
+				t.Fatalf("IsDuplicate %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeduplicatorEvictsOldest(t *testing.T) {
+	d := NewDeduplicator(2)
+	d.MarkCommitted([]*domain.SensorData{reading(1), reading(2), reading(3)})
+	if d.IsDuplicate(reading(1)) {
+		t.Fatal("oldest key still cached")
+	}
+	if !d.IsDuplicate(reading(2)) || !d.IsDuplicate(reading(3)) {
+		t.Fatal("recent keys evicted")
+	}
+}
@@ -0,0 +1,516 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/domain"
+)
+
+var (
+	ErrDuplicate      = errors.New("duplicate record")
+	ErrQueueFull      = errors.New("ingest queue full, try again later")
+	ErrPipelineClosed = errors.New("ingest pipeline closed")
+)
+
+// IngestConfig pengaturan pipeline ingest
+type IngestConfig struct {
+	QueueSize     int           // kapasitas antrian record untuk semua stream
+	BatchSize     int           // maksimum record per StoreBatch
+	FlushInterval time.Duration // batch yang belum penuh ditulis setelah interval ini
+	Workers       int           // jumlah writer yang memanggil StoreBatch secara paralel
+	MaxRetries    int           // retry StoreBatch sebelum batch dinyatakan gagal
+	RetryBackoff  time.Duration // jeda retry pertama, dikali dua tiap retry
+	// EnqueueTimeout berapa lama Enqueue boleh menunggu antrian penuh
+	// sebelum mengembalikan ErrQueueFull; 0 artinya tunggu terus
+	EnqueueTimeout time.Duration
+}
+
+// IngestRecord satu record di antrian. Done dipanggil sekali setelah batch
+// yang memuat record ini selesai: err nil kalau sudah ter-commit, dibungkus
+// ErrDeadLettered kalau gagal tapi tersimpan di dead letter.
+type IngestRecord struct {
+	Data *domain.SensorData
+	Done func(err error)
+
+	walSegment uint64
+	inWAL      bool
+}
+
+// IngestStats kondisi pipeline untuk monitoring
+type IngestStats struct {
+	QueueDepth    int               `json:"queue_depth"`
+	QueueCapacity int               `json:"queue_capacity"`
+	Workers       int               `json:"workers"`
+	Batches       uint64            `json:"batches"`
+	Records       uint64            `json:"records"`
+	FailedBatches uint64            `json:"failed_batches"`
+	Duplicates    uint64            `json:"duplicates"`
+	Rejected      map[string]uint64 `json:"rejected"` // per alasan penolakan validator
+	DeadLettered  uint64            `json:"dead_lettered"`
+	WALPending    int               `json:"wal_pending"`
+	LastWriteMs   float64           `json:"last_write_ms"`
+	AvgWriteMs    float64           `json:"avg_write_ms"`
+	MaxWriteMs    float64           `json:"max_write_ms"`
+	LastError     string            `json:"last_error,omitempty"`
+}
+
+// Healthy error kalau antrian penuh (producer sedang ditahan) atau batch
+// terakhir gagal ditulis ke database
+func (s IngestStats) Healthy() error {
+	if s.QueueDepth >= s.QueueCapacity {
+		return fmt.Errorf("ingest queue full (%d/%d)", s.QueueDepth, s.QueueCapacity)
+	}
+	if s.LastError != "" {
+		return fmt.Errorf("last batch write failed: %s", s.LastError)
+	}
+	return nil
+}
+
+// IngestPipeline antrian tunggal yang dipakai semua stream gRPC. Record dari
+// banyak producer digabung jadi batch besar sebelum ditulis ke repository
+// oleh sejumlah writer.
+type IngestPipeline interface {
+	// Enqueue memasukkan record ke antrian; menunggu kalau antrian penuh.
+	// Mengembalikan *ValidationError kalau record ditolak validator dan
+	// ErrDuplicate kalau record sudah pernah ter-commit; Done tidak dipanggil
+	// untuk keduanya.
+	Enqueue(ctx context.Context, rec IngestRecord) error
+	// IngestBatch memvalidasi lalu langsung menyimpan data dalam satu
+	// StoreBatch tanpa lewat antrian. itemErrs sejajar dengan data: nil kalau
+	// tersimpan, *ValidationError, atau ErrDuplicate kalau sudah ada di cache
+	// dedup maupun di database; res.Duplicates hanya menghitung yang di
+	// database. err non-nil berarti transaksi gagal dan tidak ada data yang
+	// tersimpan.
+	IngestBatch(data []*domain.SensorData) (itemErrs []error, res domain.BatchResult, err error)
+	// Durable true kalau record sudah aman di write-ahead log begitu Enqueue
+	// berhasil, jadi producer boleh di-ack tanpa menunggu commit ke database
+	Durable() bool
+	// ReplayWAL memasukkan lagi record di write-ahead log yang diterima tapi
+	// belum ter-commit sebelum proses mati. Record yang ditolak validator atau
+	// duplikat dibuang. Tanpa WAL tidak melakukan apa-apa.
+	ReplayWAL(ctx context.Context) (int, error)
+	Stats() IngestStats
+	// Close berhenti menerima record, menulis sisa antrian lalu menunggu writer selesai
+	Close()
+}
+
+type ingestPipeline struct {
+	repo        domain.SensorRepository
+	dedup       *Deduplicator
+	validator   *Validator
+	deadLetters domain.DeadLetterRepository
+	wal         domain.WriteAheadLog
+	hub         *SensorHub
+	cfg         IngestConfig
+
+	mu      sync.RWMutex // melindungi closed dan senders.Add
+	closed  bool
+	done    chan struct{} // ditutup Close, membangunkan Enqueue yang menunggu
+	senders sync.WaitGroup
+	queue   chan IngestRecord
+	batches chan []IngestRecord
+	wg      sync.WaitGroup
+
+	batchCount   atomic.Uint64
+	recordCount  atomic.Uint64
+	failedCount  atomic.Uint64
+	deadCount    atomic.Uint64
+	lastWrite    atomic.Int64 // nanodetik
+	maxWrite     atomic.Int64
+	totalWrite   atomic.Int64
+	lastErr      atomic.Value // string
+	writeSamples atomic.Int64
+}
+
+// validator, deadLetters, wal dan hub boleh nil: tanpa validator semua record
+// diterima, tanpa dead letter batch yang gagal hanya di-log, tanpa wal record
+// di antrian hilang kalau proses mati, tanpa hub data tidak dibagikan ke subscriber
+func NewIngestPipeline(repo domain.SensorRepository, dedup *Deduplicator, validator *Validator, deadLetters domain.DeadLetterRepository, wal domain.WriteAheadLog, hub *SensorHub, cfg IngestConfig) IngestPipeline {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 500
+	}
+	if cfg.QueueSize < cfg.BatchSize {
+		cfg.QueueSize = cfg.BatchSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 5 * time.Second
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = 500 * time.Millisecond
+	}
+
+	p := &ingestPipeline{
+		repo:        repo,
+		dedup:       dedup,
+		validator:   validator,
+		deadLetters: deadLetters,
+		wal:         wal,
+		hub:         hub,
+		cfg:         cfg,
+		done:        make(chan struct{}),
+		queue:       make(chan IngestRecord, cfg.QueueSize),
+		batches:     make(chan []IngestRecord, cfg.Workers),
+	}
+	p.lastErr.Store("")
+
+	p.wg.Add(1)
+	go p.batcher()
+	for range cfg.Workers {
+		p.wg.Add(1)
+		go p.writer()
+	}
+	return p
+}
+
+func (p *ingestPipeline) Enqueue(ctx context.Context, rec IngestRecord) error {
+	if p.validator != nil {
+		if err := p.validator.Validate(rec.Data); err != nil {
+			return err
+		}
+	}
+	if p.dedup.IsDuplicate(rec.Data) {
+		return ErrDuplicate
+	}
+	return p.enqueue(ctx, rec)
+}
+
+// enqueue menulis record ke WAL (kecuali record hasil replay yang sudah ada
+// di sana) lalu memasukkannya ke antrian
+func (p *ingestPipeline) enqueue(ctx context.Context, rec IngestRecord) error {
+	// lock hanya untuk cek closed; menunggu antrian penuh di bawah lock
+	// membuat Close ikut tertahan
+	p.mu.RLock()
+	if p.closed {
+		p.mu.RUnlock()
+		return ErrPipelineClosed
+	}
+	p.senders.Add(1)
+	p.mu.RUnlock()
+	defer p.senders.Done()
+
+	// tulis ke WAL dulu; kalau antrian ternyata penuh, record di-Release lagi
+	appended := false
+	if p.wal != nil && !rec.inWAL {
+		segment, err := p.wal.Append(rec.Data)
+		if err != nil {
+			return err
+		}
+		rec.walSegment, rec.inWAL, appended = segment, true, true
+	}
+	enqueued := false
+	defer func() {
+		if !enqueued && appended {
+			p.wal.Release(rec.walSegment)
+		}
+	}()
+
+	select {
+	case p.queue <- rec:
+		enqueued = true
+		return nil
+	default:
+	}
+
+	// antrian penuh: tahan producer sampai ada tempat (backpressure)
+	var timeout <-chan time.Time
+	if p.cfg.EnqueueTimeout > 0 {
+		timer := time.NewTimer(p.cfg.EnqueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case p.queue <- rec:
+		enqueued = true
+		return nil
+	case <-timeout:
+		return ErrQueueFull
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-p.done:
+		return ErrPipelineClosed
+	}
+}
+
+func (p *ingestPipeline) IngestBatch(data []*domain.SensorData) ([]error, domain.BatchResult, error) {
+	itemErrs := make([]error, len(data))
+	accepted := make([]*domain.SensorData, 0, len(data))
+	acceptedIdx := make([]int, 0, len(data))
+	for i, s := range data {
+		if p.validator != nil {
+			if err := p.validator.Validate(s); err != nil {
+				itemErrs[i] = err
+				continue
+			}
+		}
+		if p.dedup.IsDuplicate(s) {
+			itemErrs[i] = ErrDuplicate
+			continue
+		}
+		accepted = append(accepted, s)
+		acceptedIdx = append(acceptedIdx, i)
+	}
+	if len(accepted) == 0 {
+		return itemErrs, domain.BatchResult{}, nil
+	}
+
+	// tanpa retry: producer menunggu jawaban dan bisa mengirim ulang sendiri
+	start := time.Now()
+	res, err := p.repo.StoreBatch(accepted)
+	if err != nil {
+		log.Printf("Error storing batch of %d records: %v", len(accepted), err)
+		p.lastErr.Store(err.Error())
+		p.failedCount.Add(1)
+		return itemErrs, domain.BatchResult{}, err
+	}
+	p.observeWrite(time.Since(start))
+	p.batchCount.Add(1)
+	p.recordCount.Add(uint64(res.Inserted))
+	p.lastErr.Store("")
+	p.dedup.MarkCommitted(accepted)
+	p.dedup.AddDuplicates(res.Duplicates)
+	for i, dup := range res.Duplicate {
+		if dup {
+			itemErrs[acceptedIdx[i]] = ErrDuplicate
+		}
+	}
+	p.publish(accepted, res)
+	return itemErrs, res, nil
+}
+
+// publish membagikan data yang baru ter-commit ke subscriber; duplikat yang
+// baru ketahuan di database (lolos dari cache dedup) tidak dikirim lagi
+func (p *ingestPipeline) publish(sensors []*domain.SensorData, res domain.BatchResult) {
+	if p.hub == nil {
+		return
+	}
+	if res.Duplicate != nil {
+		fresh := make([]*domain.SensorData, 0, res.Inserted)
+		for i, s := range sensors {
+			if !res.Duplicate[i] {
+				fresh = append(fresh, s)
+			}
+		}
+		sensors = fresh
+	}
+	p.hub.Publish(sensors)
+}
+
+func (p *ingestPipeline) Durable() bool {
+	return p.wal != nil
+}
+
+func (p *ingestPipeline) ReplayWAL(ctx context.Context) (int, error) {
+	if p.wal == nil {
+		return 0, nil
+	}
+	return p.wal.Replay(func(s *domain.SensorData, segment uint64) error {
+		if p.validator != nil {
+			if err := p.validator.Validate(s); err != nil {
+				log.Printf("WAL replay: dropping record rejected by validator: %v", err)
+				p.wal.Release(segment)
+				return nil
+			}
+		}
+		if p.dedup.IsDuplicate(s) {
+			p.wal.Release(segment)
+			return nil
+		}
+		rec := IngestRecord{Data: s, walSegment: segment, inWAL: true}
+		for {
+			// record sudah aman di WAL, jadi antrian penuh cukup ditunggu
+			err := p.enqueue(ctx, rec)
+			if !errors.Is(err, ErrQueueFull) {
+				return err
+			}
+		}
+	})
+}
+
+func (p *ingestPipeline) Close() {
+	p.mu.Lock()
+	first := !p.closed
+	if first {
+		p.closed = true
+		close(p.done)
+	}
+	p.mu.Unlock()
+	if first {
+		// queue baru boleh ditutup setelah tidak ada Enqueue yang masih mengirim
+		p.senders.Wait()
+		close(p.queue)
+	}
+	p.wg.Wait()
+}
+
+// batcher menggabungkan record dari antrian jadi batch sebesar BatchSize,
+// atau lebih kecil kalau FlushInterval sudah lewat sejak record pertama
+func (p *ingestPipeline) batcher() {
+	defer p.wg.Done()
+	defer close(p.batches)
+
+	timer := time.NewTimer(p.cfg.FlushInterval)
+	timer.Stop()
+
+	var batch []IngestRecord
+	flush := func() {
+		if len(batch) > 0 {
+			p.batches <- batch
+			batch = nil
+		}
+		timer.Stop()
+	}
+
+	for {
+		select {
+		case rec, ok := <-p.queue:
+			if !ok {
+				flush()
+				return
+			}
+			if len(batch) == 0 {
+				timer.Reset(p.cfg.FlushInterval)
+			}
+			batch = append(batch, rec)
+			if len(batch) >= p.cfg.BatchSize {
+				flush()
+			}
+		case <-timer.C:
+			flush()
+		}
+	}
+}
+
+func (p *ingestPipeline) writer() {
+	defer p.wg.Done()
+	for batch := range p.batches {
+		err := p.store(batch)
+		for _, rec := range batch {
+			// kalau dead letter juga gagal, record dibiarkan di WAL dan
+			// di-replay saat start berikutnya
+			if rec.inWAL && (err == nil || errors.Is(err, ErrDeadLettered)) {
+				p.wal.Release(rec.walSegment)
+			}
+			if rec.Done != nil {
+				rec.Done(err)
+			}
+		}
+	}
+}
+
+// store menulis satu batch dengan retry + exponential backoff
+func (p *ingestPipeline) store(batch []IngestRecord) error {
+	sensors := make([]*domain.SensorData, len(batch))
+	for i, rec := range batch {
+		sensors[i] = rec.Data
+	}
+
+	var err error
+	backoff := p.cfg.RetryBackoff
+	for attempt := 0; attempt <= p.cfg.MaxRetries; attempt++ {
+		if attempt > 0 {
+			log.Printf("Retrying batch of %d records in %v (attempt %d/%d)", len(batch), backoff, attempt, p.cfg.MaxRetries)
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		start := time.Now()
+		var res domain.BatchResult
+		res, err = p.repo.StoreBatch(sensors)
+		if err == nil {
+			p.observeWrite(time.Since(start))
+			p.batchCount.Add(1)
+			p.recordCount.Add(uint64(res.Inserted))
+			p.lastErr.Store("")
+			p.dedup.MarkCommitted(sensors)
+			p.dedup.AddDuplicates(res.Duplicates)
+			if res.Duplicates > 0 {
+				log.Printf("Skipped %d duplicate records (total duplicates: %d)", res.Duplicates, p.dedup.Duplicates())
+			}
+			p.publish(sensors, res)
+			return nil
+		}
+		log.Printf("Error storing batch of %d records: %v", len(batch), err)
+		p.lastErr.Store(err.Error())
+	}
+
+	p.failedCount.Add(1)
+	if p.deadLetter(sensors, err) {
+		return fmt.Errorf("%w: %v", ErrDeadLettered, err)
+	}
+	return err
+}
+
+// ErrDeadLettered batch gagal disimpan tapi sudah aman di dead letter, jadi
+// pengirim boleh di-ack; data bisa di-replay lewat admin API
+var ErrDeadLettered = errors.New("not stored, batch dead-lettered")
+
+// deadLetter menyimpan batch yang gagal supaya bisa di-replay lewat admin API
+func (p *ingestPipeline) deadLetter(sensors []*domain.SensorData, cause error) bool {
+	if p.deadLetters == nil {
+		return false
+	}
+	dl := &domain.DeadLetter{
+		Error:    cause.Error(),
+		Attempts: p.cfg.MaxRetries + 1,
+		FailedAt: time.Now(),
+		Records:  sensors,
+	}
+	if err := p.deadLetters.Save(dl); err != nil {
+		log.Printf("Error writing dead letter for %d records: %v", len(sensors), err)
+		return false
+	}
+	p.deadCount.Add(1)
+	log.Printf("Batch of %d records dead-lettered as %s", len(sensors), dl.ID)
+	return true
+}
+
+func (p *ingestPipeline) observeWrite(d time.Duration) {
+	p.lastWrite.Store(int64(d))
+	p.totalWrite.Add(int64(d))
+	p.writeSamples.Add(1)
+	for {
+		old := p.maxWrite.Load()
+		if int64(d) <= old || p.maxWrite.CompareAndSwap(old, int64(d)) {
+			break
+		}
+	}
+}
+
+func (p *ingestPipeline) Stats() IngestStats {
+	stats := IngestStats{
+		QueueDepth:    len(p.queue),
+		QueueCapacity: cap(p.queue),
+		Workers:       p.cfg.Workers,
+		Batches:       p.batchCount.Load(),
+		Records:       p.recordCount.Load(),
+		FailedBatches: p.failedCount.Load(),
+		Duplicates:    p.dedup.Duplicates(),
+		DeadLettered:  p.deadCount.Load(),
+		LastWriteMs:   toMs(p.lastWrite.Load()),
+		MaxWriteMs:    toMs(p.maxWrite.Load()),
+		LastError:     p.lastErr.Load().(string),
+	}
+	if p.validator != nil {
+		stats.Rejected = p.validator.Rejected()
+	}
+	if p.wal != nil {
+		stats.WALPending = p.wal.Pending()
+	}
+	if n := p.writeSamples.Load(); n > 0 {
+		stats.AvgWriteMs = toMs(p.totalWrite.Load() / n)
+	}
+	return stats
+}
+
+func toMs(ns int64) float64 {
+	return float64(ns) / float64(time.Millisecond)
+}
@@ -0,0 +1,148 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/domain"
+)
+
+// fakeSensorRepo hanya StoreBatch; method lain tidak dipakai pipeline
+type fakeSensorRepo struct {
+	domain.SensorRepository
+
+	block chan struct{} // kalau tidak nil, StoreBatch menunggu sampai ditutup
+	err   error
+
+	mu     sync.Mutex
+	stored []*domain.SensorData
+}
+
+func (r *fakeSensorRepo) StoreBatch(sensors []*domain.SensorData) (domain.BatchResult, error) {
+	if r.block != nil {
+		<-r.block
+	}
+	if r.err != nil {
+		return domain.BatchResult{}, r.err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stored = append(r.stored, sensors...)
+	return domain.BatchResult{Inserted: len(sensors)}, nil
+}
+
+func (r *fakeSensorRepo) Stored() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.stored)
+}
+
+func reading(seq uint64) *domain.SensorData {
+	producer := "gw-1"
+	return &domain.SensorData{
+		SensorValue: float64(seq),
+		SensorType:  "temperature",
+		ID1:         "A",
+		ID2:         1,
+		TS:          time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC).Add(time.Duration(seq) * time.Second),
+		ProducerID:  &producer,
+		Seq:         &seq,
+	}
+}
+
+func within(t *testing.T, what string, d time.Duration, ch <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(d):
+		t.Fatalf("timed out waiting for %s", what)
+	}
+}
+
+func TestCloseWakesBlockedEnqueue(t *testing.T) {
+	repo := &fakeSensorRepo{block: make(chan struct{})}
+	p := NewIngestPipeline(repo, NewDeduplicator(100), nil, nil, nil, nil, IngestConfig{
+		QueueSize:     1,
+		BatchSize:     1,
+		FlushInterval: time.Hour,
+		Workers:       1,
+		// EnqueueTimeout 0: tunggu terus selama antrian penuh
+	})
+
+	// writer tertahan di StoreBatch, lalu batch, batcher dan antrian terisi
+	var seq uint64
+	for {
+		seq++
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		err := p.Enqueue(ctx, IngestRecord{Data: reading(seq)})
+		cancel()
+		if errors.Is(err, context.DeadlineExceeded) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("enqueue %d: %v", seq, err)
+		}
+		if seq > 10 {
+			t.Fatal("queue never filled up")
+		}
+	}
+
+	blocked := make(chan error, 1)
+	go func() {
+		blocked <- p.Enqueue(context.Background(), IngestRecord{Data: reading(100)})
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	closed := make(chan struct{})
+	go func() {
+		p.Close()
+		close(closed)
+	}()
+	select {
+	case err := <-blocked:
+		if !errors.Is(err, ErrPipelineClosed) {
+			t.Fatalf("blocked Enqueue returned %v, want ErrPipelineClosed", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close did not wake the blocked Enqueue")
+	}
+
+	// sisa antrian tetap ditulis sebelum Close selesai
+	close(repo.block)
+	within(t, "Close", time.Second, closed)
+	if got, want := repo.Stored(), int(seq-1); got != want {
+		t.Fatalf("stored %d readings, want %d", got, want)
+	}
+	if err := p.Enqueue(context.Background(), IngestRecord{Data: reading(200)}); !errors.Is(err, ErrPipelineClosed) {
+		t.Fatalf("Enqueue after Close: %v, want ErrPipelineClosed", err)
+	}
+}
+
+func TestEnqueueTimeoutOnFullQueue(t *testing.T) {
+	repo := &fakeSensorRepo{block: make(chan struct{})}
+	p := NewIngestPipeline(repo, NewDeduplicator(100), nil, nil, nil, nil, IngestConfig{
+		QueueSize:      1,
+		BatchSize:      1,
+		FlushInterval:  time.Hour,
+		Workers:        1,
+		EnqueueTimeout: 50 * time.Millisecond,
+	})
+	defer func() {
+		close(repo.block)
+		p.Close()
+	}()
+
+	for seq := uint64(1); seq <= 10; seq++ {
+		err := p.Enqueue(context.Background(), IngestRecord{Data: reading(seq)})
+		if errors.Is(err, ErrQueueFull) {
+			return
+		}
+		if err != nil {
+			t.Fatalf("enqueue %d: %v", seq, err)
+		}
+	}
+	t.Fatal("never got ErrQueueFull")
+}
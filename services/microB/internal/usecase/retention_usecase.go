@@ -0,0 +1,257 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/domain"
+)
+
+var (
+	ErrInvalidRetentionPolicy = errors.New("invalid retention policy")
+	ErrRetentionRunning       = errors.New("retention run already in progress")
+)
+
+// RetentionConfig pengaturan job retention
+type RetentionConfig struct {
+	// Interval jarak antar run terjadwal, 0 artinya hanya lewat RunNow
+	Interval time.Duration
+	// ChunkSize data per DELETE; kecil supaya lock tidak lama
+	ChunkSize int
+	// ChunkPause jeda antar DELETE supaya ingest tetap dapat giliran
+	ChunkPause time.Duration
+	// History jumlah laporan run terakhir yang disimpan
+	History int
+}
+
+// RetentionRun laporan satu run. Untuk dry run Deleted berarti jumlah data
+// yang akan dihapus; tiap data hanya dihitung policy yang berlaku untuknya.
+type RetentionRun struct {
+	StartedAt  time.Time               `json:"started_at"`
+	FinishedAt time.Time               `json:"finished_at"`
+	Trigger    string                  `json:"trigger"` // "schedule" atau "manual"
+	DryRun     bool                    `json:"dry_run"`
+	Deleted    int64                   `json:"deleted"`
+	Policies   []RetentionPolicyResult `json:"policies"`
+	Error      string                  `json:"error,omitempty"`
+}
+
+type RetentionPolicyResult struct {
+	PolicyID   uint64     `json:"policy_id"`
+	SensorType string     `json:"sensor_type"`
+	ID1Pattern string     `json:"id1_pattern"`
+	Cutoff     time.Time  `json:"cutoff"` // data sebelum ini dihapus
+	Deleted    int64      `json:"deleted"`
+	Oldest     *time.Time `json:"oldest,omitempty"` // hanya untuk dry run
+	Error      string     `json:"error,omitempty"`
+}
+
+type RetentionUsecase interface {
+	List() ([]*domain.RetentionPolicy, error)
+	Get(id uint64) (*domain.RetentionPolicy, error)
+	Create(p *domain.RetentionPolicy) error
+	Update(p *domain.RetentionPolicy) error
+	Delete(id uint64) error
+	// RunNow menjalankan semua policy aktif sekali; dryRun hanya menghitung
+	// data yang akan dihapus. ErrRetentionRunning kalau run lain sedang jalan.
+	RunNow(ctx context.Context, dryRun bool) (*RetentionRun, error)
+	// Runs laporan run terakhir, yang terbaru di depan
+	Runs() []*RetentionRun
+	// Run menjalankan retention setiap Interval sampai ctx selesai
+	Run(ctx context.Context)
+}
+
+type retentionUsecase struct {
+	repo       domain.RetentionPolicyRepository
+	sensorRepo domain.SensorRepository
+	cfg        RetentionConfig
+
+	running sync.Mutex
+	mu      sync.Mutex
+	runs    []*RetentionRun
+}
+
+func NewRetentionUsecase(repo domain.RetentionPolicyRepository, sensorRepo domain.SensorRepository, cfg RetentionConfig) RetentionUsecase {
+	if cfg.ChunkSize <= 0 {
+		cfg.ChunkSize = 1000
+	}
+	if cfg.History <= 0 {
+		cfg.History = 1
+	}
+	return &retentionUsecase{repo: repo, sensorRepo: sensorRepo, cfg: cfg}
+}
+
+func (u *retentionUsecase) List() ([]*domain.RetentionPolicy, error) {
+	return u.repo.FindAll()
+}
+
+func (u *retentionUsecase) Get(id uint64) (*domain.RetentionPolicy, error) {
+	return u.repo.FindByID(id)
+}
+
+func (u *retentionUsecase) Create(p *domain.RetentionPolicy) error {
+	if err := validateRetentionPolicy(p); err != nil {
+		return err
+	}
+	return u.repo.Create(p)
+}
+
+func (u *retentionUsecase) Update(p *domain.RetentionPolicy) error {
+	if err := validateRetentionPolicy(p); err != nil {
+		return err
+	}
+	return u.repo.Update(p)
+}
+
+func (u *retentionUsecase) Delete(id uint64) error {
+	return u.repo.Delete(id)
+}
+
+func (u *retentionUsecase) Runs() []*RetentionRun {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return slices.Clone(u.runs)
+}
+
+func (u *retentionUsecase) Run(ctx context.Context) {
+	if u.cfg.Interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(u.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := u.run(ctx, false, "schedule"); err != nil && !errors.Is(err, ErrRetentionRunning) {
+				log.Printf("Retention run failed: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (u *retentionUsecase) RunNow(ctx context.Context, dryRun bool) (*RetentionRun, error) {
+	return u.run(ctx, dryRun, "manual")
+}
+
+func (u *retentionUsecase) run(ctx context.Context, dryRun bool, trigger string) (*RetentionRun, error) {
+	if !u.running.TryLock() {
+		return nil, ErrRetentionRunning
+	}
+	defer u.running.Unlock()
+
+	policies, err := u.repo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	run := &RetentionRun{StartedAt: now, Trigger: trigger, DryRun: dryRun, Policies: []RetentionPolicyResult{}}
+	for _, f := range retentionFilters(policies, now) {
+		res := RetentionPolicyResult{
+			PolicyID:   f.policy.ID,
+			SensorType: f.policy.SensorType,
+			ID1Pattern: f.policy.ID1Pattern,
+			Cutoff:     f.Before,
+		}
+		if dryRun {
+			res.Deleted, res.Oldest, err = u.sensorRepo.CountExpired(f.RetentionFilter)
+		} else {
+			res.Deleted, err = u.purge(ctx, f.RetentionFilter)
+		}
+		if err != nil {
+			res.Error = err.Error()
+		}
+		run.Deleted += res.Deleted
+		run.Policies = append(run.Policies, res)
+		if ctx.Err() != nil {
+			run.Error = "interrupted: " + ctx.Err().Error()
+			break
+		}
+	}
+	run.FinishedAt = time.Now().UTC()
+
+	if run.Deleted > 0 || trigger == "manual" {
+		log.Printf("Retention run (%s, dry run %v): %d readings matched by %d policies in %v", trigger, dryRun,
+			run.Deleted, len(run.Policies), run.FinishedAt.Sub(run.StartedAt).Round(time.Millisecond))
+	}
+	u.mu.Lock()
+	u.runs = append([]*RetentionRun{run}, u.runs...)
+	if len(u.runs) > u.cfg.History {
+		u.runs = u.runs[:u.cfg.History]
+	}
+	u.mu.Unlock()
+	return run, nil
+}
+
+// purge menghapus per chunk sampai tidak ada lagi yang cocok
+func (u *retentionUsecase) purge(ctx context.Context, f domain.RetentionFilter) (int64, error) {
+	var total int64
+	for ctx.Err() == nil {
+		n, err := u.sensorRepo.DeleteExpired(f, u.cfg.ChunkSize)
+		total += n
+		if err != nil || n < int64(u.cfg.ChunkSize) {
+			return total, err
+		}
+		select {
+		case <-time.After(u.cfg.ChunkPause):
+		case <-ctx.Done():
+		}
+	}
+	return total, nil
+}
+
+type policyFilter struct {
+	domain.RetentionFilter
+	policy *domain.RetentionPolicy
+}
+
+// retentionFilters satu filter per policy aktif. Data yang cocok dengan
+// beberapa policy diurus policy yang umurnya paling panjang (kalau sama, ID
+// terkecil); policy lain mengecualikannya lewat Exclude.
+func retentionFilters(policies []*domain.RetentionPolicy, now time.Time) []policyFilter {
+	var filters []policyFilter
+	for _, p := range policies {
+		if !p.Enabled {
+			continue
+		}
+		f := policyFilter{policy: p, RetentionFilter: domain.RetentionFilter{Scope: p.Scope(), Before: now.Add(-p.MaxAge())}}
+		for _, other := range policies {
+			if other == p || !other.Enabled || !governs(other, p) {
+				continue
+			}
+			// sensor type berbeda tidak mungkin cocok dengan data yang sama
+			if p.SensorType != "" && other.SensorType != "" && p.SensorType != other.SensorType {
+				continue
+			}
+			f.Exclude = append(f.Exclude, other.Scope())
+		}
+		filters = append(filters, f)
+	}
+	return filters
+}
+
+// governs true kalau a yang berlaku untuk data yang cocok dengan a dan b
+func governs(a, b *domain.RetentionPolicy) bool {
+	if a.MaxAgeSeconds != b.MaxAgeSeconds {
+		return a.MaxAgeSeconds > b.MaxAgeSeconds
+	}
+	return a.ID < b.ID
+}
+
+func validateRetentionPolicy(p *domain.RetentionPolicy) error {
+	switch {
+	case len(p.SensorType) > 64:
+		return fmt.Errorf("%w: sensor_type must be at most 64 characters", ErrInvalidRetentionPolicy)
+	case len(p.ID1Pattern) > 64:
+		return fmt.Errorf("%w: id1_pattern must be at most 64 characters", ErrInvalidRetentionPolicy)
+	case p.MaxAge() < time.Hour:
+		return fmt.Errorf("%w: max_age must be at least 1h", ErrInvalidRetentionPolicy)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package usecase
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/domain"
+)
+
+func TestRetentionFilters(t *testing.T) {
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	day := int64(24 * 60 * 60)
+	policy := func(id uint64, sensorType, pattern string, days int64, enabled bool) *domain.RetentionPolicy {
+		return &domain.RetentionPolicy{ID: id, SensorType: sensorType, ID1Pattern: pattern, MaxAgeSeconds: days * day, Enabled: enabled}
+	}
+	scope := func(sensorType, pattern string) domain.RetentionScope {
+		return domain.RetentionScope{SensorType: sensorType, ID1Pattern: pattern}
+	}
+
+	tests := []struct {
+		name     string
+		policies []*domain.RetentionPolicy
+		want     map[uint64][]domain.RetentionScope // policy ID → Exclude; policy yang tidak ada tidak jalan
+	}{
+		{
+			name:     "type and id1 overlap, id1 policy longer",
+			policies: []*domain.RetentionPolicy{policy(1, "motion", "", 30, true), policy(2, "", "LAB-*", 90, true)},
+			want:     map[uint64][]domain.RetentionScope{1: {scope("", "LAB-*")}, 2: nil},
+		},
+		{
+			name:     "type and id1 overlap, type policy longer",
+			policies: []*domain.RetentionPolicy{policy(1, "temperature", "", 365, true), policy(2, "", "LAB-*", 30, true)},
+			want:     map[uint64][]domain.RetentionScope{1: nil, 2: {scope("temperature", "")}},
+		},
+		{
+			name:     "disabled policy protects nothing",
+			policies: []*domain.RetentionPolicy{policy(1, "motion", "", 30, true), policy(2, "", "", 365, false)},
+			want:     map[uint64][]domain.RetentionScope{1: nil},
+		},
+		{
+			name:     "different sensor types never overlap",
+			policies: []*domain.RetentionPolicy{policy(1, "motion", "", 30, true), policy(2, "temperature", "LAB-*", 90, true)},
+			want:     map[uint64][]domain.RetentionScope{1: nil, 2: nil},
+		},
+		{
+			name:     "global policy longer than everything",
+			policies: []*domain.RetentionPolicy{policy(1, "motion", "LAB-*", 30, true), policy(2, "", "", 90, true)},
+			want:     map[uint64][]domain.RetentionScope{1: {scope("", "")}, 2: nil},
+		},
+		{
+			name:     "equal max age, lower ID wins",
+			policies: []*domain.RetentionPolicy{policy(2, "motion", "", 30, true), policy(1, "", "LAB-*", 30, true)},
+			want:     map[uint64][]domain.RetentionScope{1: nil, 2: {scope("", "LAB-*")}},
+		},
+		{
+			name: "chain of three",
+			policies: []*domain.RetentionPolicy{
+				policy(1, "motion", "", 30, true), policy(2, "", "LAB-*", 90, true), policy(3, "", "LAB-1*", 365, true),
+			},
+			want: map[uint64][]domain.RetentionScope{
+				1: {scope("", "LAB-*"), scope("", "LAB-1*")},
+				2: {scope("", "LAB-1*")},
+				3: nil,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filters := retentionFilters(tt.policies, now)
+			if len(filters) != len(tt.want) {
+				t.Fatalf("%d filters, want %d", len(filters), len(tt.want))
+			}
+			for _, f := range filters {
+				want, ok := tt.want[f.policy.ID]
+				if !ok {
+					t.Fatalf("policy %d should not run", f.policy.ID)
+				}
+				if !slices.Equal(f.Exclude, want) {
+					t.Errorf("policy %d excludes %v, want %v", f.policy.ID, f.Exclude, want)
+				}
+				if cutoff := now.Add(-f.policy.MaxAge()); !f.Before.Equal(cutoff) {
+					t.Errorf("policy %d cutoff %v, want %v", f.policy.ID, f.Before, cutoff)
+				}
+			}
+		})
+	}
+}
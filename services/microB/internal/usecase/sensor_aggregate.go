@@ -0,0 +1,124 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/domain"
+)
+
+var ErrInvalidAggregate = errors.New("invalid aggregate query")
+
+// maxAggregateBuckets batas bucket per series dalam satu query
+const maxAggregateBuckets = 10000
+
+// AggregateFunc fungsi agregat yang bisa diminta di /sensors/aggregate
+type AggregateFunc string
+
+const (
+	AggregateAvg    AggregateFunc = "avg"
+	AggregateMin    AggregateFunc = "min"
+	AggregateMax    AggregateFunc = "max"
+	AggregateSum    AggregateFunc = "sum"
+	AggregateCount  AggregateFunc = "count"
+	AggregateFirst  AggregateFunc = "first"
+	AggregateLast   AggregateFunc = "last"
+	AggregateStddev AggregateFunc = "stddev" // standar deviasi populasi, sama dengan STDDEV() MySQL
+)
+
+var aggregateFuncs = []AggregateFunc{AggregateAvg, AggregateMin, AggregateMax, AggregateSum, AggregateCount, AggregateFirst, AggregateLast, AggregateStddev}
+
+// ParseAggregateFuncs daftar fungsi dipisah koma, kosong artinya avg
+func ParseAggregateFuncs(s string) ([]AggregateFunc, error) {
+	if strings.TrimSpace(s) == "" {
+		return []AggregateFunc{AggregateAvg}, nil
+	}
+	var fns []AggregateFunc
+	for _, name := range strings.Split(s, ",") {
+		fn := AggregateFunc(strings.ToLower(strings.TrimSpace(name)))
+		if !slices.Contains(aggregateFuncs, fn) {
+			return nil, fmt.Errorf("%w: unknown function %q (avg, min, max, sum, count, first, last, stddev)", ErrInvalidAggregate, name)
+		}
+		if !slices.Contains(fns, fn) {
+			fns = append(fns, fn)
+		}
+	}
+	return fns, nil
+}
+
+// AggregateRow hasil satu bucket satu series; bucket tanpa data tidak ada
+type AggregateRow struct {
+	ID1        string                    `json:"id1"`
+	ID2        int                       `json:"id2"`
+	SensorType string                    `json:"sensor_type"`
+	Unit       string                    `json:"unit,omitempty"`
+	Bucket     time.Time                 `json:"bucket"` // awal interval
+	Values     map[AggregateFunc]float64 `json:"values"`
+}
+
+// AggregateResult hasil Aggregate. From dan To sudah dibulatkan ke batas
+// bucket; Source "raw" atau level rollup yang dipakai.
+type AggregateResult struct {
+	From, To time.Time
+	Source   string
+	Rows     []*AggregateRow
+}
+
+func (u *sensorUsecase) Aggregate(q domain.AggregateQuery, fns []AggregateFunc) (*AggregateResult, error) {
+	switch {
+	case q.Interval < time.Second || q.Interval%time.Second != 0:
+		return nil, fmt.Errorf("%w: interval must be a whole number of seconds", ErrInvalidAggregate)
+	case !q.To.After(q.From):
+		return nil, fmt.Errorf("%w: to must be after from", ErrInvalidAggregate)
+	case len(fns) == 0:
+		return nil, fmt.Errorf("%w: no functions", ErrInvalidAggregate)
+	}
+	q = q.Aligned()
+	if q.To.Sub(q.From)/q.Interval > maxAggregateBuckets {
+		return nil, fmt.Errorf("%w: more than %d buckets, use a larger interval or a shorter range", ErrInvalidAggregate, maxAggregateBuckets)
+	}
+
+	buckets, source, err := u.repo.Aggregate(q)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]*AggregateRow, 0, len(buckets))
+	for _, b := range buckets {
+		row := &AggregateRow{ID1: b.ID1, ID2: b.ID2, SensorType: b.SensorType, Bucket: b.Bucket, Values: make(map[AggregateFunc]float64, len(fns))}
+		if st, ok := u.types.Lookup(b.SensorType); ok {
+			row.Unit = st.Unit
+		}
+		for _, fn := range fns {
+			row.Values[fn] = aggregateValue(fn, b)
+		}
+		rows = append(rows, row)
+	}
+	return &AggregateResult{From: q.From, To: q.To, Source: source, Rows: rows}, nil
+}
+
+func aggregateValue(fn AggregateFunc, b *domain.SensorRollup) float64 {
+	switch fn {
+	case AggregateMin:
+		return b.MinValue
+	case AggregateMax:
+		return b.MaxValue
+	case AggregateSum:
+		return b.SumValue
+	case AggregateCount:
+		return float64(b.Samples)
+	case AggregateFirst:
+		return b.FirstVal
+	case AggregateLast:
+		return b.LastVal
+	case AggregateStddev:
+		// dari sum dan sum of squares; hasil negatif kecil karena pembulatan
+		// float dianggap 0
+		mean := b.Avg()
+		return math.Sqrt(max(0, b.SumSq/float64(b.Samples)-mean*mean))
+	}
+	return b.Avg()
+}
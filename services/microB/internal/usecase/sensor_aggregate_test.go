@@ -0,0 +1,59 @@
+package usecase
+
+import (
+	"testing"
+	"time"
+
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/domain"
+)
+
+// aggregateRepo mencatat query yang diteruskan ke repository
+type aggregateRepo struct {
+	domain.SensorRepository
+	got domain.AggregateQuery
+}
+
+func (r *aggregateRepo) Aggregate(q domain.AggregateQuery) ([]*domain.SensorRollup, string, error) {
+	r.got = q
+	return nil, "raw", nil
+}
+
+func TestAggregateAlignsRange(t *testing.T) {
+	ts := func(s string) time.Time {
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			panic(err)
+		}
+		return t
+	}
+	tests := []struct {
+		name             string
+		interval         time.Duration
+		from, to         string
+		wantFrom, wantTo string
+	}{
+		{"default window", time.Hour, "2026-03-01T10:17:42.5Z", "2026-03-02T10:17:42.5Z", "2026-03-01T10:00:00Z", "2026-03-02T11:00:00Z"},
+		{"already aligned", time.Hour, "2026-03-01T10:00:00Z", "2026-03-01T12:00:00Z", "2026-03-01T10:00:00Z", "2026-03-01T12:00:00Z"},
+		{"to just past a boundary", time.Minute, "2026-03-01T10:00:00Z", "2026-03-01T10:05:00.000001Z", "2026-03-01T10:00:00Z", "2026-03-01T10:06:00Z"},
+		{"offset converted to UTC", 24 * time.Hour, "2026-03-01T05:00:00+07:00", "2026-03-01T12:00:00+07:00", "2026-02-28T00:00:00Z", "2026-03-02T00:00:00Z"},
+		// 90m dan 7d dihitung dari epoch; 1970-01-01 hari Kamis
+		{"90m from epoch", 90 * time.Minute, "2026-03-01T10:00:00Z", "2026-03-01T11:00:00Z", "2026-03-01T09:00:00Z", "2026-03-01T12:00:00Z"},
+		{"week starts on Thursday", 7 * 24 * time.Hour, "2026-03-01T00:00:00Z", "2026-03-02T00:00:00Z", "2026-02-26T00:00:00Z", "2026-03-05T00:00:00Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &aggregateRepo{}
+			uc := NewSensorUsecase(repo, fakeTypes{})
+			res, err := uc.Aggregate(domain.AggregateQuery{From: ts(tt.from), To: ts(tt.to), Interval: tt.interval}, []AggregateFunc{AggregateAvg})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !repo.got.From.Equal(ts(tt.wantFrom)) || !repo.got.To.Equal(ts(tt.wantTo)) {
+				t.Fatalf("repo got [%v, %v), want [%s, %s)", repo.got.From, repo.got.To, tt.wantFrom, tt.wantTo)
+			}
+			if !res.From.Equal(repo.got.From) || !res.To.Equal(repo.got.To) {
+				t.Fatalf("result range [%v, %v) differs from the query", res.From, res.To)
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/domain"
+)
+
+// SlowConsumerPolicy apa yang dilakukan kalau buffer subscriber penuh
+type SlowConsumerPolicy string
+
+const (
+	SlowConsumerDrop       SlowConsumerPolicy = "drop"       // data baru untuk subscriber itu dibuang
+	SlowConsumerDisconnect SlowConsumerPolicy = "disconnect" // subscriber diputus
+)
+
+var (
+	ErrSlowConsumer = errors.New("subscriber too slow, disconnected")
+	ErrHubClosed    = errors.New("server shutting down")
+)
+
+// SubscriptionFilter field kosong (atau ID2 0) artinya tidak difilter
+type SubscriptionFilter struct {
+	ID1         string
+	ID2         int
+	SensorTypes []string
+}
+
+func (f SubscriptionFilter) Match(s *domain.SensorData) bool {
+	if f.ID1 != "" && f.ID1 != s.ID1 {
+		return false
+	}
+	if f.ID2 != 0 && f.ID2 != s.ID2 {
+		return false
+	}
+	if len(f.SensorTypes) > 0 && !slices.Contains(f.SensorTypes, s.SensorType) {
+		return false
+	}
+	return true
+}
+
+// SensorEvent satu data yang dibagikan hub. ID naik terus, juga setelah
+// restart, dipakai client untuk melanjutkan dari event terakhir yang diterima.
+type SensorEvent struct {
+	ID   uint64
+	Data *domain.SensorData
+}
+
+// SensorHub membagikan data yang baru ter-commit ke semua subscriber yang
+// filternya cocok. Publish tidak pernah menunggu subscriber: tiap subscriber
+// punya buffer sendiri, dan kalau penuh diperlakukan sesuai policy. Event
+// terakhir disimpan di ring buffer supaya subscriber yang reconnect bisa
+// melanjutkan tanpa kehilangan data.
+type SensorHub struct {
+	buffer int
+	policy SlowConsumerPolicy
+
+	mu      sync.RWMutex
+	subs    map[*Subscription]struct{}
+	closed  bool
+	lastID  uint64
+	history []SensorEvent // ring buffer
+	next    int           // posisi tulis berikutnya di history
+}
+
+// history jumlah event terakhir yang disimpan untuk resume, 0 artinya resume tidak didukung
+func NewSensorHub(buffer, history int, policy SlowConsumerPolicy) (*SensorHub, error) {
+	if policy != SlowConsumerDrop && policy != SlowConsumerDisconnect {
+		return nil, fmt.Errorf("unknown slow consumer policy %q", policy)
+	}
+	if buffer <= 0 {
+		buffer = 1
+	}
+	return &SensorHub{
+		buffer:  buffer,
+		policy:  policy,
+		subs:    map[*Subscription]struct{}{},
+		history: make([]SensorEvent, 0, max(history, 0)),
+		// mulai dari waktu start supaya ID tetap naik walaupun MicroB restart
+		lastID: uint64(time.Now().UnixMicro()),
+	}, nil
+}
+
+// Subscribe mendaftarkan subscriber baru; panggil Close kalau sudah selesai.
+// afterID ID event terakhir yang sudah diterima client (0 kalau baru): event
+// setelahnya yang masih ada di history dikirim lebih dulu lewat Replay.
+func (h *SensorHub) Subscribe(filter SubscriptionFilter, afterID uint64) *Subscription {
+	sub := &Subscription{
+		hub:    h,
+		filter: filter,
+		ch:     make(chan SensorEvent, h.buffer),
+		done:   make(chan struct{}),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		sub.err = ErrHubClosed
+		sub.closeOnce.Do(func() { close(sub.done) })
+		return sub
+	}
+	if afterID > 0 && afterID < h.lastID {
+		sub.Replay, sub.Missed = h.since(afterID, filter)
+	}
+	h.subs[sub] = struct{}{}
+	return sub
+}
+
+// since event di history setelah afterID yang cocok dengan filter. missed
+// true kalau sebagian event setelah afterID sudah keluar dari history.
+func (h *SensorHub) since(afterID uint64, filter SubscriptionFilter) (events []SensorEvent, missed bool) {
+	n := len(h.history)
+	if n == 0 {
+		return nil, true
+	}
+	start := 0
+	if n == cap(h.history) {
+		start = h.next // history penuh, event tertua ada di posisi tulis berikutnya
+	}
+	if h.history[start].ID > afterID+1 {
+		missed = true
+	}
+	for i := range n {
+		ev := h.history[(start+i)%n]
+		if ev.ID > afterID && filter.Match(ev.Data) {
+			events = append(events, ev)
+		}
+	}
+	return events, missed
+}
+
+// Publish dipanggil pipeline setelah batch ter-commit
+func (h *SensorHub) Publish(data []*domain.SensorData) {
+	var slow []*Subscription
+	h.mu.Lock()
+	for _, s := range data {
+		h.lastID++
+		ev := SensorEvent{ID: h.lastID, Data: s}
+		if cap(h.history) > 0 {
+			if len(h.history) < cap(h.history) {
+				h.history = append(h.history, ev)
+			} else {
+				h.history[h.next] = ev
+			}
+			h.next = (h.next + 1) % cap(h.history)
+		}
+
+		for sub := range h.subs {
+			if !sub.filter.Match(s) {
+				continue
+			}
+			select {
+			case sub.ch <- ev:
+				continue
+			default:
+			}
+			if h.policy == SlowConsumerDisconnect {
+				slow = append(slow, sub)
+				delete(h.subs, sub)
+				continue
+			}
+			sub.dropped.Add(1)
+		}
+	}
+	h.mu.Unlock()
+
+	for _, sub := range slow {
+		sub.close(ErrSlowConsumer)
+	}
+}
+
+// Subscribers jumlah subscriber yang sedang aktif
+func (h *SensorHub) Subscribers() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subs)
+}
+
+// Close memutus semua subscriber dengan ErrHubClosed dan menolak subscriber
+// baru, dipanggil saat shutdown supaya stream yang terbuka selesai
+func (h *SensorHub) Close() {
+	h.mu.Lock()
+	h.closed = true
+	subs := h.subs
+	h.subs = map[*Subscription]struct{}{}
+	h.mu.Unlock()
+
+	for sub := range subs {
+		sub.close(ErrHubClosed)
+	}
+}
+
+func (h *SensorHub) remove(sub *Subscription) {
+	h.mu.Lock()
+	delete(h.subs, sub)
+	h.mu.Unlock()
+}
+
+// Subscription satu subscriber SensorHub
+type Subscription struct {
+	// Replay event dari history yang harus dikirim sebelum membaca C
+	Replay []SensorEvent
+	// Missed true kalau sebagian event setelah afterID sudah tidak ada di history
+	Missed bool
+
+	hub     *SensorHub
+	filter  SubscriptionFilter
+	ch      chan SensorEvent
+	dropped atomic.Uint64
+
+	closeOnce sync.Once
+	done      chan struct{}
+	err       error
+}
+
+// C event yang cocok dengan filter, urut sesuai commit
+func (s *Subscription) C() <-chan SensorEvent {
+	return s.ch
+}
+
+// Done ditutup kalau subscriber diputus hub (lihat Err) atau Close dipanggil
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err alasan subscriber diputus, nil kalau ditutup lewat Close
+func (s *Subscription) Err() error {
+	select {
+	case <-s.done:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+// Dropped jumlah data yang dibuang karena buffer subscriber penuh
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+func (s *Subscription) Close() {
+	s.close(nil)
+}
+
+func (s *Subscription) close(err error) {
+	s.closeOnce.Do(func() {
+		s.err = err
+		close(s.done)
+		s.hub.remove(s)
+		if err != nil && err != ErrHubClosed {
+			log.Printf("Subscriber %+v disconnected: %v", s.filter, err)
+		}
+	})
+}
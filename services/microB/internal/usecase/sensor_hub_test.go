@@ -0,0 +1,187 @@
+package usecase
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/domain"
+)
+
+// publishAll mengirim data ke hub dan mengembalikan ID event yang dibuat
+func publishAll(t *testing.T, h *SensorHub, data []*domain.SensorData) []uint64 {
+	t.Helper()
+	sub := h.Subscribe(SubscriptionFilter{}, 0)
+	defer sub.Close()
+	h.Publish(data)
+	ids := make([]uint64, 0, len(data))
+	for range data {
+		ids = append(ids, (<-sub.C()).ID)
+	}
+	return ids
+}
+
+func eventIDs(events []SensorEvent) []uint64 {
+	ids := make([]uint64, 0, len(events))
+	for _, ev := range events {
+		ids = append(ids, ev.ID)
+	}
+	return ids
+}
+
+func TestHubResume(t *testing.T) {
+	h, err := NewSensorHub(10, 3, SlowConsumerDrop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data []*domain.SensorData
+	for i := range 5 {
+		d := reading(uint64(i + 1))
+		d.ID2 = i%2 + 1
+		data = append(data, d)
+	}
+	// history 3, jadi setelah 5 event yang tersisa ids[2..4]
+	ids := publishAll(t, h, data)
+
+	tests := []struct {
+		name       string
+		filter     SubscriptionFilter
+		afterID    uint64
+		wantReplay []uint64
+		wantMissed bool
+	}{
+		{"new subscriber", SubscriptionFilter{}, 0, nil, false},
+		{"up to date", SubscriptionFilter{}, ids[4], nil, false},
+		{"one behind", SubscriptionFilter{}, ids[3], ids[4:], false},
+		{"oldest still in history", SubscriptionFilter{}, ids[1], ids[2:], false},
+		{"missed events", SubscriptionFilter{}, ids[0], ids[2:], true},
+		{"from before start", SubscriptionFilter{}, 1, ids[2:], true},
+		{"id from the future", SubscriptionFilter{}, ids[4] + 100, nil, false},
+		{"replay is filtered", SubscriptionFilter{ID2: 1}, ids[1], []uint64{ids[2], ids[4]}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := h.Subscribe(tt.filter, tt.afterID)
+			defer sub.Close()
+			if got := eventIDs(sub.Replay); !slices.Equal(got, tt.wantReplay) {
+				t.Errorf("replay %v, want %v", got, tt.wantReplay)
+			}
+			if sub.Missed != tt.wantMissed {
+				t.Errorf("missed %v, want %v", sub.Missed, tt.wantMissed)
+			}
+		})
+	}
+
+	t.Run("live events continue after replay", func(t *testing.T) {
+		sub := h.Subscribe(SubscriptionFilter{}, ids[3])
+		defer sub.Close()
+		h.Publish([]*domain.SensorData{reading(6)})
+		ev := <-sub.C()
+		if len(sub.Replay) != 1 || ev.ID != sub.Replay[0].ID+1 {
+			t.Fatalf("replay %v then %d, want consecutive ids", eventIDs(sub.Replay), ev.ID)
+		}
+	})
+}
+
+func TestHubResumeWithoutHistory(t *testing.T) {
+	h, err := NewSensorHub(10, 0, SlowConsumerDrop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := publishAll(t, h, []*domain.SensorData{reading(1), reading(2)})
+
+	sub := h.Subscribe(SubscriptionFilter{}, ids[0])
+	defer sub.Close()
+	if !sub.Missed || len(sub.Replay) != 0 {
+		t.Fatalf("missed %v replay %d, want reset without replay", sub.Missed, len(sub.Replay))
+	}
+}
+
+func TestHubSlowConsumer(t *testing.T) {
+	tests := []struct {
+		policy      SlowConsumerPolicy
+		wantErr     error
+		wantDropped uint64
+		wantSubs    int
+	}{
+		{SlowConsumerDrop, nil, 2, 2},
+		{SlowConsumerDisconnect, ErrSlowConsumer, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			h, err := NewSensorHub(1, 0, tt.policy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			slow := h.Subscribe(SubscriptionFilter{}, 0)
+			defer slow.Close()
+			// subscriber yang filternya tidak cocok tidak ikut penuh
+			other := h.Subscribe(SubscriptionFilter{ID1: "B"}, 0)
+			defer other.Close()
+
+			h.Publish([]*domain.SensorData{reading(1), reading(2), reading(3)})
+
+			if err := slow.Err(); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err %v, want %v", err, tt.wantErr)
+			}
+			if got := slow.Dropped(); got != tt.wantDropped {
+				t.Errorf("dropped %d, want %d", got, tt.wantDropped)
+			}
+			if got := h.Subscribers(); got != tt.wantSubs {
+				t.Errorf("subscribers %d, want %d", got, tt.wantSubs)
+			}
+			if ev := <-slow.C(); *ev.Data.Seq != 1 {
+				t.Errorf("buffered seq %d, want the first event", *ev.Data.Seq)
+			}
+			if other.Err() != nil {
+				t.Errorf("unrelated subscriber closed: %v", other.Err())
+			}
+		})
+	}
+}
+
+func TestHubClose(t *testing.T) {
+	h, err := NewSensorHub(1, 0, SlowConsumerDrop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := h.Subscribe(SubscriptionFilter{}, 0)
+	h.Close()
+
+	within(t, "subscriber close", time.Second, sub.Done())
+	if !errors.Is(sub.Err(), ErrHubClosed) {
+		t.Fatalf("err %v, want ErrHubClosed", sub.Err())
+	}
+	late := h.Subscribe(SubscriptionFilter{}, 0)
+	if !errors.Is(late.Err(), ErrHubClosed) {
+		t.Fatalf("subscribe after close: err %v, want ErrHubClosed", late.Err())
+	}
+	if h.Subscribers() != 0 {
+		t.Fatalf("subscribers %d after close", h.Subscribers())
+	}
+}
+
+func TestSubscriptionFilter(t *testing.T) {
+	d := reading(1)
+	tests := []struct {
+		name   string
+		filter SubscriptionFilter
+		want   bool
+	}{
+		{"empty", SubscriptionFilter{}, true},
+		{"id1", SubscriptionFilter{ID1: "A"}, true},
+		{"other id1", SubscriptionFilter{ID1: "B"}, false},
+		{"id2", SubscriptionFilter{ID1: "A", ID2: 1}, true},
+		{"other id2", SubscriptionFilter{ID2: 2}, false},
+		{"sensor type", SubscriptionFilter{SensorTypes: []string{"humidity", "temperature"}}, true},
+		{"other sensor type", SubscriptionFilter{SensorTypes: []string{"humidity"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(d); got != tt.want {
+				t.Fatalf("Match %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/domain"
+)
+
+var ErrInvalidSensorType = errors.New("invalid sensor type")
+
+// SensorTypeLookup mencari sensor type tanpa ke database
+type SensorTypeLookup interface {
+	Lookup(name string) (*domain.SensorType, bool)
+}
+
+type SensorTypeUsecase interface {
+	SensorTypeLookup
+	List() ([]*domain.SensorType, error)
+	Get(name string) (*domain.SensorType, error)
+	Create(st *domain.SensorType) error
+	Update(st *domain.SensorType) error
+	Delete(name string) error
+	// Seed menambahkan sensor type yang belum ada lalu memuat katalog ke cache
+	Seed(defaults []*domain.SensorType) error
+}
+
+// sensorTypeUsecase menyimpan salinan katalog di memori karena Lookup
+// dipanggil untuk setiap data yang masuk
+type sensorTypeUsecase struct {
+	repo domain.SensorTypeRepository
+
+	mu    sync.RWMutex
+	cache map[string]*domain.SensorType
+}
+
+func NewSensorTypeUsecase(repo domain.SensorTypeRepository) SensorTypeUsecase {
+	return &sensorTypeUsecase{repo: repo, cache: map[string]*domain.SensorType{}}
+}
+
+// DefaultSensorTypes sensor type bawaan MicroA
+func DefaultSensorTypes() []*domain.SensorType {
+	return []*domain.SensorType{
+		{Name: "temperature", Unit: "°C", Description: "Room temperature", MinValue: -50, MaxValue: 100, Precision: 1},
+		{Name: "humidity", Unit: "%", Description: "Relative humidity", MinValue: 0, MaxValue: 100, Precision: 1},
+		{Name: "co2", Unit: "ppm", Description: "CO2 concentration", MinValue: 0, MaxValue: 10000, Precision: 0},
+		{Name: "motion", Unit: "bool", Description: "Motion detected (0/1)", MinValue: 0, MaxValue: 1, Precision: 0},
+		{Name: "light", Unit: "lux", Description: "Illuminance", MinValue: 0, MaxValue: 200000, Precision: 0},
+		{Name: "noise", Unit: "dB", Description: "Sound level", MinValue: 0, MaxValue: 200, Precision: 1},
+	}
+}
+
+func (u *sensorTypeUsecase) Lookup(name string) (*domain.SensorType, bool) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	st, ok := u.cache[name]
+	return st, ok
+}
+
+func (u *sensorTypeUsecase) List() ([]*domain.SensorType, error) {
+	return u.repo.FindAll()
+}
+
+func (u *sensorTypeUsecase) Get(name string) (*domain.SensorType, error) {
+	return u.repo.FindByName(name)
+}
+
+func (u *sensorTypeUsecase) Create(st *domain.SensorType) error {
+	if err := validateSensorType(st); err != nil {
+		return err
+	}
+	if err := u.repo.Create(st); err != nil {
+		return err
+	}
+	return u.reload()
+}
+
+func (u *sensorTypeUsecase) Update(st *domain.SensorType) error {
+	if err := validateSensorType(st); err != nil {
+		return err
+	}
+	if err := u.repo.Update(st); err != nil {
+		return err
+	}
+	return u.reload()
+}
+
+func (u *sensorTypeUsecase) Delete(name string) error {
+	if err := u.repo.Delete(name); err != nil {
+		return err
+	}
+	return u.reload()
+}
+
+func (u *sensorTypeUsecase) Seed(defaults []*domain.SensorType) error {
+	for _, st := range defaults {
+		err := u.repo.Create(st)
+		if err != nil && !errors.Is(err, domain.ErrSensorTypeExists) {
+			return err
+		}
+	}
+	return u.reload()
+}
+
+// reload memuat ulang cache dari database setelah katalog berubah
+func (u *sensorTypeUsecase) reload() error {
+	types, err := u.repo.FindAll()
+	if err != nil {
+		return err
+	}
+	cache := make(map[string]*domain.SensorType, len(types))
+	for _, st := range types {
+		cache[st.Name] = st
+	}
+	u.mu.Lock()
+	u.cache = cache
+	u.mu.Unlock()
+	return nil
+}
+
+func validateSensorType(st *domain.SensorType) error {
+	switch {
+	case st.Name == "" || len(st.Name) > 64:
+		return fmt.Errorf("%w: name must be 1-64 characters", ErrInvalidSensorType)
+	case st.Unit == "":
+		return fmt.Errorf("%w: unit required", ErrInvalidSensorType)
+	case st.MinValue > st.MaxValue:
+		return fmt.Errorf("%w: min_value greater than max_value", ErrInvalidSensorType)
+	case st.Precision < 0 || st.Precision > 10:
+		return fmt.Errorf("%w: precision must be 0-10", ErrInvalidSensorType)
+	}
+	return nil
+}
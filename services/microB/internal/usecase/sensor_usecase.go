@@ -8,30 +8,44 @@ import (
 
 type SensorUsecase interface {
 	Store(sensor *domain.SensorData) error
-	StoreBatch(sensors []*domain.SensorData) error
+	StoreBatch(sensors []*domain.SensorData) (domain.BatchResult, error)
 	GetByFilter(id1 string, id2 *int, from, to *time.Time, limit, offset int) ([]*domain.SensorData, int, error)
 	UpdateByFilter(id1 string, id2 *int, from, to *time.Time, newValue float64) (int64, error)
 	DeleteByFilter(id1 string, id2 *int, from, to *time.Time) (int64, error)
+	// Aggregate fungsi fns per bucket per series; [From, To) dibulatkan ke
+	// batas bucket (lihat domain.AggregateQuery.Aligned)
+	Aggregate(q domain.AggregateQuery, fns []AggregateFunc) (*AggregateResult, error)
 }
 
 type sensorUsecase struct {
-	repo domain.SensorRepository
+	repo  domain.SensorRepository
+	types SensorTypeLookup
 }
 
-func NewSensorUsecase(repo domain.SensorRepository) SensorUsecase {
-	return &sensorUsecase{repo: repo}
+func NewSensorUsecase(repo domain.SensorRepository, types SensorTypeLookup) SensorUsecase {
+	return &sensorUsecase{repo: repo, types: types}
 }
 
 func (u *sensorUsecase) Store(sensor *domain.SensorData) error {
 	return u.repo.Store(sensor)
 }
 
-func (u *sensorUsecase) StoreBatch(sensors []*domain.SensorData) error {
+func (u *sensorUsecase) StoreBatch(sensors []*domain.SensorData) (domain.BatchResult, error) {
 	return u.repo.StoreBatch(sensors)
 }
 
 func (u *sensorUsecase) GetByFilter(id1 string, id2 *int, from, to *time.Time, limit, offset int) ([]*domain.SensorData, int, error) {
-	return u.repo.FindByFilter(id1, id2, from, to, limit, offset)
+	data, total, err := u.repo.FindByFilter(id1, id2, from, to, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	// lengkapi dengan satuan dari katalog sensor_types
+	for _, s := range data {
+		if st, ok := u.types.Lookup(s.SensorType); ok {
+			s.Unit = st.Unit
+		}
+	}
+	return data, total, nil
 }
 
 func (u *sensorUsecase) UpdateByFilter(id1 string, id2 *int, from, to *time.Time, newValue float64) (int64, error) {
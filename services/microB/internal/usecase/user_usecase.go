@@ -2,15 +2,33 @@ package usecase
 
 import (
 	"errors"
+	"fmt"
 	"log"
+	"slices"
 
 	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/domain"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// Role user; RoleUser satu-satunya role yang bisa didaftarkan sendiri lewat
+// /register, producer dan admin hanya dibuat oleh admin
+const (
+	RoleUser     = "user"
+	RoleProducer = "producer"
+	RoleAdmin    = "admin"
+)
+
+var (
+	Roles          = []string{RoleUser, RoleProducer, RoleAdmin}
+	ErrInvalidRole = errors.New("invalid role")
+)
+
 type UserUsecase interface {
 	Register(username, password, role string) error
 	Login(username, password string) (*domain.User, error)
+	// EnsureAdmin membuat akun admin kalau username belum ada, dipakai untuk
+	// admin pertama karena /register tidak bisa membuat admin
+	EnsureAdmin(username, password string) error
 }
 
 type userUsecase struct {
@@ -22,6 +40,9 @@ func NewUserUsecase(repo domain.UserRepository) UserUsecase {
 }
 
 func (u *userUsecase) Register(username, password, role string) error {
+	if !slices.Contains(Roles, role) {
+		return fmt.Errorf("%w %q (user, producer or admin)", ErrInvalidRole, role)
+	}
 	// Hash password
 	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
@@ -50,3 +71,14 @@ func (u *userUsecase) Login(username, password string) (*domain.User, error) {
 
 	return user, nil
 }
+
+func (u *userUsecase) EnsureAdmin(username, password string) error {
+	if _, err := u.repo.FindByUsername(username); err == nil {
+		return nil
+	}
+	if err := u.Register(username, password, RoleAdmin); err != nil {
+		return err
+	}
+	log.Printf("Created admin user %q", username)
+	return nil
+}
@@ -0,0 +1,119 @@
+package usecase
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/domain"
+)
+
+// alasan penolakan, dipakai sebagai key statistik dan dikirim ke producer
+const (
+	RejectInvalidID1       = "invalid_id1"
+	RejectInvalidID2       = "invalid_id2"
+	RejectUnknownType      = "unknown_sensor_type"
+	RejectValueOutOfRange  = "value_out_of_range"
+	RejectInvalidTimestamp = "invalid_timestamp"
+	RejectTimestampFuture  = "timestamp_in_future"
+	RejectTimestampPast    = "timestamp_too_old"
+)
+
+// ValidationError data ditolak validator; Reason salah satu konstanta Reject*
+type ValidationError struct {
+	Reason  string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Reason + ": " + e.Message
+}
+
+// ValidationConfig aturan validasi data sensor yang masuk
+type ValidationConfig struct {
+	ID1Pattern string // regex id1, default huruf kapital/angka/strip
+	ID2Min     int
+	ID2Max     int
+	MaxFuture  time.Duration // toleransi timestamp di depan jam server
+	MaxAge     time.Duration // timestamp lebih lama dari ini ditolak; 0 artinya tidak dibatasi
+}
+
+// Validator memeriksa data sebelum masuk pipeline dan menghitung data yang
+// ditolak per alasan. Sensor type dan rentang nilainya diambil dari katalog
+// sensor_types.
+type Validator struct {
+	cfg   ValidationConfig
+	id1Re *regexp.Regexp
+	types SensorTypeLookup
+
+	mu       sync.Mutex
+	rejected map[string]uint64
+}
+
+func NewValidator(cfg ValidationConfig, types SensorTypeLookup) (*Validator, error) {
+	if cfg.ID1Pattern == "" {
+		// kolom id1 char(20)
+		cfg.ID1Pattern = `^[A-Z][A-Z0-9-]{0,19}$`
+	}
+	re, err := regexp.Compile(cfg.ID1Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id1 pattern: %w", err)
+	}
+	if cfg.ID2Max < cfg.ID2Min {
+		return nil, fmt.Errorf("invalid id2 range %d..%d", cfg.ID2Min, cfg.ID2Max)
+	}
+	return &Validator{cfg: cfg, id1Re: re, types: types, rejected: map[string]uint64{}}, nil
+}
+
+// Validate mengembalikan *ValidationError kalau data ditolak
+func (v *Validator) Validate(s *domain.SensorData) error {
+	if err := v.check(s, time.Now()); err != nil {
+		v.mu.Lock()
+		v.rejected[err.Reason]++
+		v.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (v *Validator) check(s *domain.SensorData, now time.Time) *ValidationError {
+	if !v.id1Re.MatchString(s.ID1) {
+		return &ValidationError{RejectInvalidID1, fmt.Sprintf("id1 %q does not match %s", s.ID1, v.id1Re)}
+	}
+	if s.ID2 < v.cfg.ID2Min || s.ID2 > v.cfg.ID2Max {
+		return &ValidationError{RejectInvalidID2, fmt.Sprintf("id2 %d outside %d..%d", s.ID2, v.cfg.ID2Min, v.cfg.ID2Max)}
+	}
+
+	st, ok := v.types.Lookup(s.SensorType)
+	if !ok {
+		return &ValidationError{RejectUnknownType, fmt.Sprintf("unknown sensor type %q", s.SensorType)}
+	}
+	if math.IsNaN(s.SensorValue) || s.SensorValue < st.MinValue || s.SensorValue > st.MaxValue {
+		return &ValidationError{RejectValueOutOfRange, fmt.Sprintf("%s value %v outside %v..%v %s", s.SensorType, s.SensorValue, st.MinValue, st.MaxValue, st.Unit)}
+	}
+
+	// timestamp kosong berarti producer tidak mengirim timestamp yang bisa di-parse
+	if s.TS.IsZero() {
+		return &ValidationError{RejectInvalidTimestamp, "timestamp missing or not RFC3339"}
+	}
+	if s.TS.After(now.Add(v.cfg.MaxFuture)) {
+		return &ValidationError{RejectTimestampFuture, fmt.Sprintf("timestamp %s is more than %v ahead of server time", s.TS.Format(time.RFC3339), v.cfg.MaxFuture)}
+	}
+	if v.cfg.MaxAge > 0 && s.TS.Before(now.Add(-v.cfg.MaxAge)) {
+		return &ValidationError{RejectTimestampPast, fmt.Sprintf("timestamp %s is older than %v", s.TS.Format(time.RFC3339), v.cfg.MaxAge)}
+	}
+	return nil
+}
+
+// Rejected jumlah data yang ditolak per alasan
+func (v *Validator) Rejected() map[string]uint64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	out := make(map[string]uint64, len(v.rejected))
+	for reason, n := range v.rejected {
+		out[reason] = n
+	}
+	return out
+}
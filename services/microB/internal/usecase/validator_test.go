@@ -0,0 +1,151 @@
+package usecase
+
+import (
+	"maps"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thomasdarmawan9/datastream-backend/services/microB/internal/domain"
+)
+
+func testValidator(t *testing.T, cfg ValidationConfig) *Validator {
+	t.Helper()
+	v, err := NewValidator(cfg, fakeTypes{
+		"temperature": {Name: "temperature", MinValue: -50, MaxValue: 150, Unit: "C"},
+		"humidity":    {Name: "humidity", MinValue: 0, MaxValue: 100, Unit: "%"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestValidatorCheck(t *testing.T) {
+	v := testValidator(t, ValidationConfig{ID2Min: 1, ID2Max: 100, MaxFuture: 5 * time.Minute, MaxAge: 7 * 24 * time.Hour})
+	now := reading(1).TS
+
+	tests := []struct {
+		name   string
+		modify func(s *domain.SensorData)
+		want   string // "" artinya lolos
+	}{
+		{"valid", func(s *domain.SensorData) {}, ""},
+
+		// id1 default ^[A-Z][A-Z0-9-]{0,19}$
+		{"id1 single letter", func(s *domain.SensorData) { s.ID1 = "A" }, ""},
+		{"id1 with digits and dash", func(s *domain.SensorData) { s.ID1 = "LAB-01" }, ""},
+		{"id1 20 chars", func(s *domain.SensorData) { s.ID1 = "A" + strings.Repeat("B", 19) }, ""},
+		{"id1 21 chars", func(s *domain.SensorData) { s.ID1 = "A" + strings.Repeat("B", 20) }, RejectInvalidID1},
+		{"id1 empty", func(s *domain.SensorData) { s.ID1 = "" }, RejectInvalidID1},
+		{"id1 lowercase", func(s *domain.SensorData) { s.ID1 = "lab" }, RejectInvalidID1},
+		{"id1 starts with digit", func(s *domain.SensorData) { s.ID1 = "1LAB" }, RejectInvalidID1},
+		{"id1 with space", func(s *domain.SensorData) { s.ID1 = "LAB 1" }, RejectInvalidID1},
+
+		// id2 1..100
+		{"id2 min", func(s *domain.SensorData) { s.ID2 = 1 }, ""},
+		{"id2 max", func(s *domain.SensorData) { s.ID2 = 100 }, ""},
+		{"id2 below", func(s *domain.SensorData) { s.ID2 = 0 }, RejectInvalidID2},
+		{"id2 above", func(s *domain.SensorData) { s.ID2 = 101 }, RejectInvalidID2},
+
+		// rentang nilai dari katalog
+		{"unknown type", func(s *domain.SensorData) { s.SensorType = "pressure" }, RejectUnknownType},
+		{"value at min", func(s *domain.SensorData) { s.SensorValue = -50 }, ""},
+		{"value at max", func(s *domain.SensorData) { s.SensorValue = 150 }, ""},
+		{"value below min", func(s *domain.SensorData) { s.SensorValue = -50.1 }, RejectValueOutOfRange},
+		{"value above max", func(s *domain.SensorData) { s.SensorValue = 150.1 }, RejectValueOutOfRange},
+		{"value NaN", func(s *domain.SensorData) { s.SensorValue = math.NaN() }, RejectValueOutOfRange},
+		{"value +Inf", func(s *domain.SensorData) { s.SensorValue = math.Inf(1) }, RejectValueOutOfRange},
+		{"range per type", func(s *domain.SensorData) { s.SensorType, s.SensorValue = "humidity", 120 }, RejectValueOutOfRange},
+
+		// timestamp
+		{"timestamp missing", func(s *domain.SensorData) { s.TS = time.Time{} }, RejectInvalidTimestamp},
+		{"timestamp within future tolerance", func(s *domain.SensorData) { s.TS = now.Add(5 * time.Minute) }, ""},
+		{"timestamp in future", func(s *domain.SensorData) { s.TS = now.Add(5*time.Minute + time.Second) }, RejectTimestampFuture},
+		{"timestamp at max age", func(s *domain.SensorData) { s.TS = now.Add(-7 * 24 * time.Hour) }, ""},
+		{"timestamp too old", func(s *domain.SensorData) { s.TS = now.Add(-7*24*time.Hour - time.Second) }, RejectTimestampPast},
+
+		// id1 dicek lebih dulu dari alasan lain
+		{"first failing rule wins", func(s *domain.SensorData) { s.ID1, s.ID2, s.TS = "bad", 0, time.Time{} }, RejectInvalidID1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := reading(1)
+			tt.modify(s)
+			err := v.check(s, now)
+			switch {
+			case tt.want == "" && err != nil:
+				t.Fatalf("rejected: %v", err)
+			case tt.want != "" && err == nil:
+				t.Fatalf("accepted, want %s", tt.want)
+			case err != nil && err.Reason != tt.want:
+				t.Fatalf("reason %s, want %s (%v)", err.Reason, tt.want, err)
+			}
+		})
+	}
+}
+
+func TestValidatorConfig(t *testing.T) {
+	// MaxAge 0 tidak membatasi timestamp lama
+	v := testValidator(t, ValidationConfig{ID2Max: 10})
+	old := reading(1)
+	old.TS = old.TS.AddDate(-10, 0, 0)
+	if err := v.check(old, reading(1).TS); err != nil {
+		t.Fatalf("old timestamp without MaxAge: %v", err)
+	}
+
+	// pattern id1 custom
+	v = testValidator(t, ValidationConfig{ID1Pattern: `^dev-[0-9]+$`, ID2Max: 10})
+	for id1, ok := range map[string]bool{"dev-42": true, "A": false} {
+		s := reading(1)
+		s.ID1 = id1
+		if err := v.check(s, s.TS); (err == nil) != ok {
+			t.Errorf("id1 %q: %v, want ok %v", id1, err, ok)
+		}
+	}
+
+	for name, cfg := range map[string]ValidationConfig{
+		"bad regex":      {ID1Pattern: "("},
+		"inverted range": {ID2Min: 10, ID2Max: 1},
+	} {
+		if _, err := NewValidator(cfg, fakeTypes{}); err == nil {
+			t.Errorf("%s: NewValidator accepted %+v", name, cfg)
+		}
+	}
+}
+
+func TestValidatorRejectedCounters(t *testing.T) {
+	v := testValidator(t, ValidationConfig{ID2Min: 1, ID2Max: 100, MaxFuture: time.Minute})
+
+	valid := reading(1)
+	valid.TS = time.Now()
+	badID1, badID2, unknown, future := *valid, *valid, *valid, *valid
+	badID1.ID1 = "bad"
+	badID2.ID2 = 0
+	unknown.SensorType = "pressure"
+	future.TS = time.Now().Add(time.Hour)
+
+	for _, s := range []*domain.SensorData{valid, &badID1, &badID1, &badID2, &unknown, &future, valid} {
+		err := v.Validate(s)
+		if s == valid && err != nil {
+			t.Fatalf("valid reading rejected: %v", err)
+		}
+	}
+
+	want := map[string]uint64{
+		RejectInvalidID1:      2,
+		RejectInvalidID2:      1,
+		RejectUnknownType:     1,
+		RejectTimestampFuture: 1,
+	}
+	got := v.Rejected()
+	if !maps.Equal(got, want) {
+		t.Fatalf("rejected %v, want %v", got, want)
+	}
+	// Rejected mengembalikan salinan
+	got[RejectInvalidID1] = 100
+	if v.Rejected()[RejectInvalidID1] != 2 {
+		t.Fatal("Rejected returned the internal map")
+	}
+}